@@ -0,0 +1,246 @@
+package diag
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/gen2brain/raylib-go/raymath"
+	"math"
+	"proj3/phys"
+	"proj3/pm"
+	"proj3/qtree"
+)
+
+const ExactLimit = 1000    // Largest number of bodies to calculate the potential energy of exactly
+const DefaultInterval = 10 // Default number of time-steps between diagnostics
+
+// Diagnostics holds conserved quantities and other measures of a system's state
+type Diagnostics struct {
+	Step            int        // Time-step the diagnostics were calculated at
+	Bodies          int        // Number of bodies in the system
+	Kinetic         float64    // Total kinetic energy
+	Potential       float64    // Total potential energy
+	Energy          float64    // Kinetic + potential energy
+	Momentum        [2]float64 // Total linear momentum
+	AngularMomentum float64    // Total angular momentum about the centre of mass
+	CentreOfMass    [2]float64 // Mass weighted average position
+	VirialRatio     float64    // 2 * Kinetic / |Potential|. 1 in equilibrium
+	ExactPotential  bool       // Whether the potential was calculated exactly or with a tree
+	MeshPotential   bool       // Whether the potential is that of a pm or treepm solve rather than a Newtonian sum
+}
+
+/*
+ * Calculate the diagnostics of a system of bodies
+ *
+ * step: Integer - time-step of the bodies
+ * bodies: slice of physics bodies
+ * box: Rectangle the boundary condition applies to
+ * mode: boundary condition of the simulation
+ *
+ * return: the diagnostics of the system
+ */
+func Compute(step int, bodies []phys.Body, box rl.Rectangle, mode phys.Boundary) Diagnostics {
+	return ComputeSoftened(step, bodies, box, mode, 0)
+}
+
+/*
+ * Calculate the diagnostics of a system of bodies with a Plummer softened potential energy,
+ * so the energy is conserved by a simulation with the same softening
+ *
+ * softening: softening length. 0 is the same as Compute
+ */
+func ComputeSoftened(step int, bodies []phys.Body, box rl.Rectangle, mode phys.Boundary, softening float32) Diagnostics {
+
+	d := kinematics(step, bodies)
+
+	d.ExactPotential = len(bodies) <= ExactLimit
+	if d.ExactPotential {
+		d.Potential = exactPotential(bodies, period(box, mode), softening)
+	} else {
+		d.Potential = treePotential(bodies, box, mode, softening)
+	}
+
+	d.total()
+	return d
+}
+
+/*
+ * Calculate the diagnostics of a system of bodies simulated with the pm or treepm solver, with the
+ * potential energy of the mesh and tree the forces come from, so the energy drift is that of the run.
+ * The mesh potential of each body includes its own mass on the mesh, which for pm changes a little as
+ * the body moves within its cell
+ *
+ * box: Rectangle of the periodic box
+ * grid: Integer - number of mesh cells per side
+ * split: Bool - TreePM, with the short-range potential from a tree, rather than a pure mesh
+ * softening: softening length of the short-range potential
+ */
+func ComputeMesh(step int, bodies []phys.Body, box rl.Rectangle, grid int, split bool, softening float32) Diagnostics {
+
+	d := kinematics(step, bodies)
+	d.MeshPotential = true
+	d.Potential = meshPotential(bodies, box, grid, split, softening)
+
+	d.total()
+	return d
+}
+
+/*
+ * Calculate the diagnostics that do not need the potential energy
+ */
+func kinematics(step int, bodies []phys.Body) Diagnostics {
+
+	d := Diagnostics{Step: step, Bodies: len(bodies)}
+
+	// Mass weighted sums for the centre of mass and momentum
+	var mass float64
+	for i := 0; i < len(bodies); i++ {
+		m := float64(bodies[i].Mass)
+		vx, vy := float64(bodies[i].Velocity.X), float64(bodies[i].Velocity.Y)
+
+		mass += m
+		d.Kinetic += 0.5 * m * (vx*vx + vy*vy)
+		d.Momentum[0] += m * vx
+		d.Momentum[1] += m * vy
+		d.CentreOfMass[0] += m * float64(bodies[i].Position.X)
+		d.CentreOfMass[1] += m * float64(bodies[i].Position.Y)
+	}
+
+	if mass > 0 {
+		d.CentreOfMass[0] /= mass
+		d.CentreOfMass[1] /= mass
+	}
+
+	// Angular momentum about the centre of mass
+	for i := 0; i < len(bodies); i++ {
+		rx := float64(bodies[i].Position.X) - d.CentreOfMass[0]
+		ry := float64(bodies[i].Position.Y) - d.CentreOfMass[1]
+		d.AngularMomentum += float64(bodies[i].Mass) *
+			(rx*float64(bodies[i].Velocity.Y) - ry*float64(bodies[i].Velocity.X))
+	}
+
+	return d
+}
+
+/*
+ * Fill in the total energy and virial ratio from the kinetic and potential energy
+ */
+func (d *Diagnostics) total() {
+	d.Energy = d.Kinetic + d.Potential
+	if d.Potential != 0 {
+		d.VirialRatio = 2 * d.Kinetic / math.Abs(d.Potential)
+	}
+}
+
+/*
+ * Return lines summarising the diagnostics for display
+ */
+func (d Diagnostics) Summary() []string {
+	return []string{
+		fmt.Sprintf("Energy: %.4g (K: %.4g, W: %.4g)", d.Energy, d.Kinetic, d.Potential),
+		fmt.Sprintf("Virial Ratio: %.4f", d.VirialRatio),
+		fmt.Sprintf("Momentum: [%.4g, %.4g]", d.Momentum[0], d.Momentum[1]),
+		fmt.Sprintf("Angular Momentum: %.4g", d.AngularMomentum),
+		fmt.Sprintf("Centre of Mass: [%.1f, %.1f]", d.CentreOfMass[0], d.CentreOfMass[1]),
+	}
+}
+
+/*
+ * Potential energy summed over every pair of bodies
+ */
+func exactPotential(bodies []phys.Body, period rl.Vector2, softening float32) float64 {
+
+	var potential float64
+	for i := 0; i < len(bodies); i++ {
+		for j := i + 1; j < len(bodies); j++ {
+			separation := phys.MinimumImage(raymath.Vector2Subtract(bodies[j].Position, bodies[i].Position), period)
+			potential += float64(bodies[i].SoftenedPotentialEnergy(&bodies[j], separation, softening))
+		}
+	}
+
+	return potential
+}
+
+/*
+ * Potential energy approximated with a BHTree
+ */
+func treePotential(bodies []phys.Body, box rl.Rectangle, mode phys.Boundary, softening float32) float64 {
+
+	var tree *qtree.BHTree
+	if mode == phys.Periodic {
+		tree = qtree.NewPeriodicBHTree(box)
+	} else {
+		tree = qtree.NewBHTree(bounds(bodies, box))
+	}
+	tree.SetAccuracy(qtree.DefaultTheta, softening)
+
+	for i := 0; i < len(bodies); i++ {
+		tree.Insert(bodies[i], 0)
+	}
+
+	// Every pair is counted twice
+	var potential float64
+	for i := 0; i < len(bodies); i++ {
+		potential += float64(tree.PotentialEnergy(&bodies[i]))
+	}
+
+	return potential / 2
+}
+
+/*
+ * Potential energy of a pm or treepm solve
+ */
+func meshPotential(bodies []phys.Body, box rl.Rectangle, grid int, split bool, softening float32) float64 {
+
+	var energy func(body *phys.Body) float32
+	if split {
+		treePM := pm.NewTreePM(box, grid)
+		treePM.Tree.SetAccuracy(qtree.DefaultTheta, softening)
+		for i := 0; i < len(bodies); i++ {
+			treePM.Insert(bodies[i])
+		}
+		treePM.Solve()
+		energy = treePM.PotentialEnergy
+	} else {
+		mesh := pm.NewMesh(box, grid)
+		for i := 0; i < len(bodies); i++ {
+			mesh.Assign(bodies[i])
+		}
+		mesh.Solve()
+		energy = mesh.PotentialEnergy
+	}
+
+	// Every pair is counted twice
+	var potential float64
+	for i := 0; i < len(bodies); i++ {
+		potential += float64(energy(&bodies[i]))
+	}
+
+	return potential / 2
+}
+
+/*
+ * Return the smallest rectangle containing the box and every body
+ */
+func bounds(bodies []phys.Body, box rl.Rectangle) rl.Rectangle {
+
+	minX, minY := box.X, box.Y
+	maxX, maxY := box.X+box.Width, box.Y+box.Height
+	for i := 0; i < len(bodies); i++ {
+		minX = float32(math.Min(float64(minX), float64(bodies[i].Position.X)))
+		minY = float32(math.Min(float64(minY), float64(bodies[i].Position.Y)))
+		maxX = float32(math.Max(float64(maxX), float64(bodies[i].Position.X)))
+		maxY = float32(math.Max(float64(maxY), float64(bodies[i].Position.Y)))
+	}
+
+	return rl.NewRectangle(minX, minY, maxX-minX, maxY-minY)
+}
+
+/*
+ * Return the size of the periodic box, or zero for other boundary conditions
+ */
+func period(box rl.Rectangle, mode phys.Boundary) rl.Vector2 {
+	if mode == phys.Periodic {
+		return rl.NewVector2(box.Width, box.Height)
+	}
+	return rl.NewVector2(0, 0)
+}
@@ -0,0 +1,100 @@
+package diag
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"math/rand"
+	"proj3/phys"
+	"testing"
+)
+
+/*
+ * Return two bodies of the mass on a circular orbit about their centre of mass
+ */
+func circularOrbit(mass, separation float64, centre rl.Vector2) []phys.Body {
+
+	speed := math.Sqrt(phys.G * mass / (2 * separation))
+	half := float32(separation / 2)
+	return []phys.Body{
+		phys.NewBody(float32(mass), 0, rl.NewVector2(centre.X-half, centre.Y), rl.NewVector2(0, -float32(speed))),
+		phys.NewBody(float32(mass), 1, rl.NewVector2(centre.X+half, centre.Y), rl.NewVector2(0, float32(speed))),
+	}
+}
+
+/*
+ * Check a value is within a relative tolerance of the wanted value
+ */
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance*math.Abs(want)
+}
+
+func TestCircularOrbit(t *testing.T) {
+
+	const mass, separation = 10.0, 100.0
+	bodies := circularOrbit(mass, separation, rl.NewVector2(500, 500))
+	d := Compute(3, bodies, rl.NewRectangle(0, 0, 1000, 1000), phys.Open)
+
+	kinetic := phys.G * mass * mass / (2 * separation)
+	potential := -phys.G * mass * mass / separation
+	speed := math.Sqrt(phys.G * mass / (2 * separation))
+	if d.Step != 3 || d.Bodies != 2 || !d.ExactPotential || d.MeshPotential {
+		t.Errorf("diagnostics = %+v", d)
+	}
+	if !near(d.Kinetic, kinetic, 1e-5) || !near(d.Potential, potential, 1e-5) ||
+		!near(d.Energy, kinetic+potential, 1e-5) {
+		t.Errorf("energies K = %v, W = %v, E = %v, want %v, %v, %v", d.Kinetic, d.Potential, d.Energy,
+			kinetic, potential, kinetic+potential)
+	}
+	if !near(d.VirialRatio, 1, 1e-5) {
+		t.Errorf("virial ratio = %v, want 1", d.VirialRatio)
+	}
+	if math.Abs(d.Momentum[0]) > 1e-6 || math.Abs(d.Momentum[1]) > 1e-6 {
+		t.Errorf("momentum = %v, want 0", d.Momentum)
+	}
+	if !near(d.AngularMomentum, mass*separation*speed, 1e-5) {
+		t.Errorf("angular momentum = %v, want %v", d.AngularMomentum, mass*separation*speed)
+	}
+	if d.CentreOfMass != [2]float64{500, 500} {
+		t.Errorf("centre of mass = %v, want [500 500]", d.CentreOfMass)
+	}
+}
+
+func TestTreePotentialMatchesExact(t *testing.T) {
+
+	r := rand.New(rand.NewSource(1))
+	box := rl.NewRectangle(0, 0, 1000, 1000)
+	bodies := make([]phys.Body, 500)
+	for i := range bodies {
+		pos := rl.NewVector2(r.Float32()*box.Width, r.Float32()*box.Height)
+		bodies[i] = phys.NewBody(0.5+r.Float32(), i, pos, rl.NewVector2(0, 0))
+	}
+
+	for _, mode := range []phys.Boundary{phys.Open, phys.Periodic} {
+		for _, softening := range []float32{0, 5} {
+			exact := exactPotential(bodies, period(box, mode), softening)
+			tree := treePotential(bodies, box, mode, softening)
+			if !near(tree, exact, 0.03) {
+				t.Errorf("boundary %v softening %v: tree potential = %v, want %v", mode, softening, tree, exact)
+			}
+		}
+	}
+}
+
+func TestMeshPotential(t *testing.T) {
+
+	// The change in the potential as two bodies move apart, which is all the energy drift depends on
+	const mass = 10.0
+	box := rl.NewRectangle(0, 0, 2000, 2000)
+	for _, split := range []bool{false, true} {
+		near50 := ComputeMesh(0, circularOrbit(mass, 50, rl.NewVector2(1000, 1000)), box, 256, split, 0)
+		far200 := ComputeMesh(0, circularOrbit(mass, 200, rl.NewVector2(1000, 1000)), box, 256, split, 0)
+		if !near50.MeshPotential || near50.ExactPotential {
+			t.Errorf("split %v: diagnostics = %+v", split, near50)
+		}
+
+		want := phys.G * mass * mass * (1.0/50 - 1.0/200)
+		if change := far200.Potential - near50.Potential; !near(change, want, 0.05) {
+			t.Errorf("split %v: potential rises by %v, want %v", split, change, want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package engine
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/diag"
+	"proj3/phys"
+	"proj3/pm"
+	"proj3/qtree"
+	"sort"
+	"sync"
+)
+
+// Solver types
+const (
+	TreeSolver   = "tree"
+	MeshSolver   = "pm"
+	TreePMSolver = "treepm"
+)
+
+// Solver calculates the forces acting on a body for one time-step
+type Solver interface {
+	CalculateForces(body *phys.Body)
+}
+
+// Config holds the settings of a simulation
+type Config struct {
+	Width     int           // Width of the box
+	Height    int           // Height of the box
+	Solver    string        // Gravity solver: tree, pm or treepm
+	Grid      int           // Number of mesh cells per side for pm and treepm
+	Boundary  phys.Boundary // Boundary condition at the edges of the box
+	Dt        float32       // Timestep
+	Theta     float32       // Opening angle of the tree. 0 sums every body directly
+	Softening float32       // Plummer softening length of the forces. 0 for none
+}
+
+/*
+ * Return the default settings for a box of the given size, matching the command line defaults
+ */
+func NewConfig(width, height int) Config {
+	return Config{Width: width, Height: height, Solver: TreeSolver, Grid: pm.DefaultGridSize,
+		Boundary: phys.Open, Dt: phys.DefaultDt, Theta: qtree.DefaultTheta}
+}
+
+/*
+ * Check that the settings can be simulated
+ */
+func (c Config) Check() error {
+
+	if c.Width <= 0 || c.Height <= 0 || c.Dt == 0 {
+		return fmt.Errorf("width and height must be greater than 0 and dt must not be 0. Not [%v, %v, %v]",
+			c.Width, c.Height, c.Dt)
+	}
+	if (c.Solver != TreeSolver && c.Solver != MeshSolver && c.Solver != TreePMSolver) ||
+		c.Grid <= 0 || c.Grid&(c.Grid-1) != 0 {
+		return fmt.Errorf("solver must be tree, pm or treepm and grid must be a power of two. Not [%v, %v]",
+			c.Solver, c.Grid)
+	}
+	if c.Boundary < phys.Open || c.Boundary > phys.Absorbing {
+		return fmt.Errorf("unknown boundary condition %v", c.Boundary)
+	}
+	if c.Theta < 0 || c.Softening < 0 {
+		return fmt.Errorf("theta and softening must not be negative. Not [%v, %v]", c.Theta, c.Softening)
+	}
+
+	return nil
+}
+
+/*
+ * Return the box the boundary condition applies to
+ */
+func (c Config) Box() rl.Rectangle {
+	return rl.NewRectangle(0, 0, float32(c.Width), float32(c.Height))
+}
+
+// Sim is a simulation that owns its bodies and settings, so several can run at once
+type Sim struct {
+	Config Config      // Settings of the simulation
+	Bodies []phys.Body // Bodies still in the box, in order of Id
+	Step   int         // Number of time-steps taken
+	Time   float64     // Simulated time since the start
+}
+
+/*
+ * Return a simulation of the bodies, which are copied and sorted by Id
+ */
+func New(config Config, bodies []phys.Body) (*Sim, error) {
+
+	if err := config.Check(); err != nil {
+		return nil, err
+	}
+
+	s := &Sim{Config: config, Bodies: make([]phys.Body, len(bodies))}
+	copy(s.Bodies, bodies)
+	SortById(s.Bodies)
+
+	return s, nil
+}
+
+/*
+ * Build the solver of the settings from the bodies, adding them in order of Id
+ * so the result does not depend on how the bodies were split between workers
+ */
+func BuildSolver(config Config, bodies []phys.Body) Solver {
+
+	solver, insert, solve := NewSolver(config, Bound(config, bodies))
+	for i := range bodies {
+		insert(bodies[i])
+	}
+	solve()
+
+	return solver
+}
+
+/*
+ * Start an empty solver of the settings, for callers that add the bodies as they arrive
+ *
+ * bound: area the solver covers. See Bound
+ *
+ * return: the solver, a function adding a body to it and a function to call once every body is added
+ */
+func NewSolver(config Config, bound rl.Rectangle) (Solver, func(phys.Body), func()) {
+
+	switch config.Solver {
+	case MeshSolver:
+		mesh := pm.NewMesh(bound, config.Grid)
+		return mesh, mesh.Assign, mesh.Solve
+
+	case TreePMSolver:
+		treePM := pm.NewTreePM(bound, config.Grid)
+		treePM.Tree.SetAccuracy(config.Theta, config.Softening)
+		return treePM, treePM.Insert, treePM.Solve
+	}
+
+	var tree *qtree.BHTree
+	if config.Boundary == phys.Periodic {
+		tree = qtree.NewPeriodicBHTree(bound)
+	} else {
+		tree = qtree.NewBHTree(bound)
+	}
+	tree.SetAccuracy(config.Theta, config.Softening)
+
+	return tree, func(body phys.Body) {
+		tree.Insert(body, 0)
+	}, func() {}
+}
+
+/*
+ * Whether the solver of the settings covers every body rather than the box,
+ * so its bound is only known once every body has been seen
+ */
+func (c Config) GrowsBound() bool {
+	return c.Solver == TreeSolver && c.Boundary == phys.Open
+}
+
+/*
+ * Return the area the solver of the settings covers: the box,
+ * grown to fit every body if GrowsBound
+ */
+func Bound(config Config, bodies []phys.Body) rl.Rectangle {
+
+	bound := config.Box()
+	if config.GrowsBound() {
+		// Bodies can be anywhere, so grow the tree to fit all of them
+		for i := range bodies {
+			bound = ExpandBound(bound, bodies[i].Position)
+		}
+	}
+
+	return bound
+}
+
+/*
+ * Take one time-step, splitting the bodies between workers
+ * Bodies absorbed by the boundary are removed
+ *
+ * workers: number of goroutines to calculate the forces with. Less than 1 uses one
+ *
+ * return: the Ids of the absorbed bodies
+ */
+func (s *Sim) Advance(workers int) []int {
+
+	solver := BuildSolver(s.Config, s.Bodies)
+	box := s.Config.Box()
+
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(s.Bodies) {
+		workers = len(s.Bodies)
+	}
+
+	// Each worker steps a contiguous part of the bodies
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		min, max := w*len(s.Bodies)/workers, (w+1)*len(s.Bodies)/workers
+		wg.Add(1)
+		go func(part []phys.Body) {
+			defer wg.Done()
+			for i := range part {
+				solver.CalculateForces(&part[i])
+				part[i].Position = part[i].Update(s.Config.Dt)
+				part[i].ZeroForce()
+				if !part[i].ApplyBoundary(s.Config.Boundary, box) {
+					part[i].Mass = 0
+				}
+			}
+		}(s.Bodies[min:max])
+	}
+	wg.Wait()
+
+	absorbed := make([]int, 0)
+	remaining := s.Bodies[:0]
+	for i := range s.Bodies {
+		if s.Bodies[i].Mass == 0 {
+			absorbed = append(absorbed, s.Bodies[i].Id)
+		} else {
+			remaining = append(remaining, s.Bodies[i])
+		}
+	}
+	s.Bodies = remaining
+
+	s.Step++
+	s.Time += float64(s.Config.Dt)
+	return absorbed
+}
+
+/*
+ * Return the diagnostics of the bodies at the current time-step
+ */
+func (s *Sim) Diagnostics() diag.Diagnostics {
+	if s.Config.Solver != TreeSolver {
+		return diag.ComputeMesh(s.Step, s.Bodies, s.Config.Box(), s.Config.Grid, s.Config.Solver == TreePMSolver,
+			s.Config.Softening)
+	}
+	return diag.ComputeSoftened(s.Step, s.Bodies, s.Config.Box(), s.Config.Boundary, s.Config.Softening)
+}
+
+/*
+ * Add a body, keeping the bodies in order of Id
+ *
+ * return: an error if a body already has its Id
+ */
+func (s *Sim) Add(body phys.Body) error {
+
+	i := sort.Search(len(s.Bodies), func(i int) bool { return s.Bodies[i].Id >= body.Id })
+	if i < len(s.Bodies) && s.Bodies[i].Id == body.Id {
+		return fmt.Errorf("a body with Id %v already exists", body.Id)
+	}
+
+	s.Bodies = append(s.Bodies, phys.Body{})
+	copy(s.Bodies[i+1:], s.Bodies[i:])
+	s.Bodies[i] = body
+	return nil
+}
+
+/*
+ * Remove the body with the Id
+ *
+ * return: whether there was a body to remove
+ */
+func (s *Sim) Remove(id int) bool {
+
+	i := sort.Search(len(s.Bodies), func(i int) bool { return s.Bodies[i].Id >= id })
+	if i == len(s.Bodies) || s.Bodies[i].Id != id {
+		return false
+	}
+
+	s.Bodies = append(s.Bodies[:i], s.Bodies[i+1:]...)
+	return true
+}
+
+/*
+ * Return an Id not used by any body
+ */
+func (s *Sim) NextId() int {
+	if len(s.Bodies) == 0 {
+		return 0
+	}
+	return s.Bodies[len(s.Bodies)-1].Id + 1
+}
+
+/*
+ * Sort a slice of bodies in order of Id
+ */
+func SortById(bodies []phys.Body) {
+	sort.Slice(bodies, func(i, j int) bool {
+		return bodies[i].Id < bodies[j].Id
+	})
+}
+
+/*
+ * Return the smallest rectangle containing both bound and pos
+ */
+func ExpandBound(bound rl.Rectangle, pos rl.Vector2) rl.Rectangle {
+
+	if pos.X < bound.X {
+		bound.Width += bound.X - pos.X
+		bound.X = pos.X
+	} else if pos.X > bound.X+bound.Width {
+		bound.Width = pos.X - bound.X
+	}
+
+	if pos.Y < bound.Y {
+		bound.Height += bound.Y - pos.Y
+		bound.Y = pos.Y
+	} else if pos.Y > bound.Y+bound.Height {
+		bound.Height = pos.Y - bound.Y
+	}
+
+	return bound
+}
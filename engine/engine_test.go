@@ -0,0 +1,73 @@
+package engine
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/phys"
+	"testing"
+)
+
+func TestConfigCheck(t *testing.T) {
+
+	if err := NewConfig(100, 100).Check(); err != nil {
+		t.Errorf("default config is invalid: %v", err)
+	}
+
+	bad := []Config{NewConfig(0, 100), NewConfig(100, 100), NewConfig(100, 100), NewConfig(100, 100),
+		NewConfig(100, 100), NewConfig(100, 100)}
+	bad[1].Solver = "direct"
+	bad[2].Grid = 48
+	bad[3].Dt = 0
+	bad[4].Theta = -0.5
+	bad[5].Softening = -1
+	for _, c := range bad {
+		if c.Check() == nil {
+			t.Errorf("Check accepted %+v", c)
+		}
+	}
+}
+
+func TestAddRemove(t *testing.T) {
+
+	sim, err := New(NewConfig(100, 100), []phys.Body{
+		phys.NewBody(1, 4, rl.NewVector2(10, 10), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 1, rl.NewVector2(20, 20), rl.NewVector2(0, 0)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sim.Add(phys.NewBody(1, 2, rl.NewVector2(30, 30), rl.NewVector2(0, 0))); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Add(phys.NewBody(1, 4, rl.NewVector2(30, 30), rl.NewVector2(0, 0))) == nil {
+		t.Error("Add accepted a repeated Id")
+	}
+	if sim.Bodies[0].Id != 1 || sim.Bodies[1].Id != 2 || sim.Bodies[2].Id != 4 || sim.NextId() != 5 {
+		t.Errorf("bodies are not in order of Id: %+v", sim.Bodies)
+	}
+
+	if !sim.Remove(2) || sim.Remove(2) || len(sim.Bodies) != 2 {
+		t.Errorf("Remove(2) did not remove exactly one body: %+v", sim.Bodies)
+	}
+}
+
+func TestAdvanceAbsorbs(t *testing.T) {
+
+	config := NewConfig(100, 100)
+	config.Boundary = phys.Absorbing
+	sim, err := New(config, []phys.Body{
+		phys.NewBody(1, 0, rl.NewVector2(50, 50), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 1, rl.NewVector2(99, 50), rl.NewVector2(10, 0)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	absorbed := sim.Advance(4)
+	if len(absorbed) != 1 || absorbed[0] != 1 || len(sim.Bodies) != 1 {
+		t.Errorf("absorbed = %v, bodies = %+v, want body 1 absorbed", absorbed, sim.Bodies)
+	}
+	if sim.Step != 1 || sim.Time != float64(config.Dt) {
+		t.Errorf("Step, Time = %v, %v, want 1, %v", sim.Step, sim.Time, config.Dt)
+	}
+}
@@ -0,0 +1,128 @@
+package gen
+
+import (
+	"math"
+	"math/rand"
+	"proj3/phys"
+	"sort"
+)
+
+// Radial step when integrating the King model, in core radii
+const kingStep = 1e-3
+
+// Radial profile of a King model in units of the core radius
+type kingProfile struct {
+	radius []float64 // Radius of each step
+	w      []float64 // Dimensionless potential at each radius
+	mass   []float64 // Mass enclosed by each radius, in units of the central density
+}
+
+/*
+ * King model (King, 1966) with core radius p.Radius and central potential p.W0, projected onto the plane
+ * Velocities are drawn from the lowered isothermal distribution function f(E) = e^W - 1
+ */
+func King(r *rand.Rand, bodies []Body, p Params) {
+
+	profile := newKingProfile(p.W0)
+	total := profile.mass[len(profile.mass)-1]
+
+	// Velocity dispersion from the core radius definition r0^2 = 9 sigma^2 / (4 pi G rho0)
+	sigma := math.Sqrt(4 * math.Pi * phys.G * totalMass(bodies) / (9 * p.Radius * total))
+
+	for i := range bodies {
+		radius, w := profile.sample(r.Float64() * total)
+
+		// Speed in units of sigma, from v^2 (e^(W - v^2/2) - 1) below the escape speed
+		escape := math.Sqrt(2 * w)
+		bound := escape * escape * (math.Exp(w) - 1)
+		var v float64
+		for {
+			v = escape * r.Float64()
+			if r.Float64()*bound <= v*v*(math.Exp(w-v*v/2)-1) {
+				break
+			}
+		}
+
+		bodies[i].Position = project(r, radius*p.Radius)
+		bodies[i].Velocity = project(r, v*sigma)
+	}
+}
+
+/*
+ * Integrate Poisson's equation W'' + 2W'/r = -9 rho(W)/rho(W0) out to the tidal radius, where W = 0
+ */
+func newKingProfile(w0 float64) kingProfile {
+
+	rho0 := kingDensity(w0)
+	derivs := func(r, w, dw float64) (float64, float64) {
+		return dw, -9*kingDensity(w)/rho0 - 2*dw/r
+	}
+
+	// Start off the centre with the series W = W0 - 3r^2/2
+	r := kingStep
+	w, dw := w0-1.5*r*r, -3*r
+	profile := kingProfile{[]float64{0, r}, []float64{w0, w}, []float64{0, 4 * math.Pi / 3 * r * r * r}}
+
+	for w > 0 {
+		// Runge-Kutta step
+		k1w, k1d := derivs(r, w, dw)
+		k2w, k2d := derivs(r+kingStep/2, w+kingStep/2*k1w, dw+kingStep/2*k1d)
+		k3w, k3d := derivs(r+kingStep/2, w+kingStep/2*k2w, dw+kingStep/2*k2d)
+		k4w, k4d := derivs(r+kingStep, w+kingStep*k3w, dw+kingStep*k3d)
+		w += kingStep / 6 * (k1w + 2*k2w + 2*k3w + k4w)
+		dw += kingStep / 6 * (k1d + 2*k2d + 2*k3d + k4d)
+		r += kingStep
+
+		// Mass of the shell, with the density clipped at the tidal radius
+		density := kingDensity(math.Max(w, 0)) / rho0
+		mass := profile.mass[len(profile.mass)-1] + 4*math.Pi*r*r*density*kingStep
+
+		profile.radius = append(profile.radius, r)
+		profile.w = append(profile.w, math.Max(w, 0))
+		profile.mass = append(profile.mass, mass)
+	}
+
+	return profile
+}
+
+/*
+ * Return the radius and potential enclosing the given mass
+ */
+func (k kingProfile) sample(mass float64) (float64, float64) {
+
+	i := sort.SearchFloat64s(k.mass, mass)
+	if i == 0 {
+		return 0, k.w[0]
+	}
+	if i == len(k.mass) {
+		i = len(k.mass) - 1
+	}
+
+	// Interpolate within the step
+	f := (mass - k.mass[i-1]) / (k.mass[i] - k.mass[i-1])
+	return k.radius[i-1] + f*(k.radius[i]-k.radius[i-1]), k.w[i-1] + f*(k.w[i]-k.w[i-1])
+}
+
+/*
+ * Return the tidal radius in units of the core radius
+ */
+func (k kingProfile) tidalRadius() float64 {
+	return k.radius[len(k.radius)-1]
+}
+
+/*
+ * Return the tidal radius of a King model with central potential w0, in units of the core radius
+ */
+func KingTidalRadius(w0 float64) float64 {
+	return newKingProfile(w0).tidalRadius()
+}
+
+/*
+ * Return the density of the King model at dimensionless potential W, up to a constant
+ */
+func kingDensity(w float64) float64 {
+	if w <= 0 {
+		return 0
+	}
+	return math.Exp(w)*math.Erf(math.Sqrt(w)) - math.Sqrt(4*w/math.Pi)*(1+2*w/3)
+}
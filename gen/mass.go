@@ -0,0 +1,149 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Masses describes the distribution the mass of each body is picked from
+type Masses struct {
+	Function string  // Name of the mass function
+	Min      float64 // Smallest mass
+	Max      float64 // Largest mass
+	Mass     float64 // Mass of the fixed function and median of the log-normal function
+	Sigma    float64 // Standard deviation of the natural log of the log-normal masses
+}
+
+// Power law slopes and break masses of the Kroupa (2001) IMF, dN/dm = m^-alpha
+var kroupaSlopes = []float64{0.3, 1.3, 2.3}
+var kroupaBreaks = []float64{0.08, 0.5}
+
+// Salpeter (1955) IMF slope
+const salpeterSlope = 2.35
+
+// Names of the mass functions
+var massFunctions = []string{"fixed", "kroupa", "lognormal", "salpeter", "uniform"}
+
+/*
+ * Return a function picking masses from the distribution
+ *
+ * return: the function, and an error if the distribution is unknown or its parameters are invalid
+ */
+func (m Masses) Sampler() (func(r *rand.Rand) float64, error) {
+
+	if m.Function != "fixed" && (m.Min <= 0 || m.Max < m.Min) {
+		return nil, fmt.Errorf("the mass range must be positive with min not above max. Not [%v, %v]", m.Min, m.Max)
+	}
+
+	switch m.Function {
+	case "uniform":
+		return func(r *rand.Rand) float64 {
+			return m.Min + r.Float64()*(m.Max-m.Min)
+		}, nil
+	case "salpeter":
+		return powerLaw([]float64{salpeterSlope}, nil, m.Min, m.Max), nil
+	case "kroupa":
+		return powerLaw(kroupaSlopes, kroupaBreaks, m.Min, m.Max), nil
+	case "lognormal":
+		if m.Mass < m.Min || m.Mass > m.Max || m.Sigma <= 0 {
+			return nil, fmt.Errorf("the log-normal median must be in the mass range and sigma must be "+
+				"greater than 0. Not [%v, %v]", m.Mass, m.Sigma)
+		}
+		return func(r *rand.Rand) float64 {
+			// Redraw masses outside the range
+			for {
+				mass := m.Mass * math.Exp(m.Sigma*r.NormFloat64())
+				if mass >= m.Min && mass <= m.Max {
+					return mass
+				}
+			}
+		}, nil
+	case "fixed":
+		if m.Mass <= 0 {
+			return nil, fmt.Errorf("the fixed mass must be greater than 0. Not [%v]", m.Mass)
+		}
+		return func(r *rand.Rand) float64 {
+			return m.Mass
+		}, nil
+	}
+
+	return nil, fmt.Errorf("mass function must be one of %v. Not [%v]", strings.Join(massFunctions, ", "), m.Function)
+}
+
+/*
+ * Return a function picking masses between min and max from a broken power law dN/dm = m^-alpha
+ *
+ * slopes: alpha of each segment
+ * breaks: masses between the segments, in increasing order
+ */
+func powerLaw(slopes, breaks []float64, min, max float64) func(r *rand.Rand) float64 {
+
+	// Clip the segments to the mass range, keeping the density continuous at each break
+	lower := make([]float64, 0)
+	upper := make([]float64, 0)
+	scale := make([]float64, 0)
+	weight := make([]float64, 0)
+	alpha := make([]float64, 0)
+	k := 1.0
+	for i, slope := range slopes {
+		lo, hi := 0.0, math.Inf(1)
+		if i > 0 {
+			lo = breaks[i-1]
+			k *= math.Pow(lo, slope-slopes[i-1])
+		}
+		if i < len(breaks) {
+			hi = breaks[i]
+		}
+		lo, hi = math.Max(lo, min), math.Min(hi, max)
+		if lo > hi {
+			continue
+		}
+
+		lower = append(lower, lo)
+		upper = append(upper, hi)
+		scale = append(scale, k)
+		alpha = append(alpha, slope)
+		weight = append(weight, k*powerIntegral(slope, lo, hi))
+	}
+
+	// Cumulative weight of the segments
+	for i := 1; i < len(weight); i++ {
+		weight[i] += weight[i-1]
+	}
+
+	return func(r *rand.Rand) float64 {
+		if min == max {
+			return min
+		}
+
+		// Pick a segment by its weight, then invert the integral within it
+		u := r.Float64() * weight[len(weight)-1]
+		i := sort.SearchFloat64s(weight, u)
+		if i == len(weight) {
+			i = len(weight) - 1
+		}
+		if i > 0 {
+			u -= weight[i-1]
+		}
+		u /= scale[i]
+
+		lo, a := lower[i], alpha[i]
+		if a == 1 {
+			return math.Min(lo*math.Exp(u), upper[i])
+		}
+		return math.Min(math.Pow(math.Pow(lo, 1-a)+u*(1-a), 1/(1-a)), upper[i])
+	}
+}
+
+/*
+ * Return the integral of m^-alpha from lo to hi
+ */
+func powerIntegral(alpha, lo, hi float64) float64 {
+	if alpha == 1 {
+		return math.Log(hi / lo)
+	}
+	return (math.Pow(hi, 1-alpha) - math.Pow(lo, 1-alpha)) / (1 - alpha)
+}
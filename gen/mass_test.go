@@ -0,0 +1,68 @@
+package gen
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Masses stay in their range and follow the shape of their distribution
+ */
+func TestMassFunctions(t *testing.T) {
+
+	const n = 100000
+	for _, test := range []struct {
+		masses Masses
+		mean   float64
+	}{
+		{Masses{Function: "uniform", Min: 1, Max: 3}, 2},
+		// The mean of a power law m^-alpha is the integral of m^(1-alpha) over the integral of m^-alpha
+		{Masses{Function: "salpeter", Min: 1, Max: 10}, powerIntegral(1.35, 1, 10) / powerIntegral(2.35, 1, 10)},
+		// Between 0.08 and 0.5 the Kroupa IMF is m^-1.3
+		{Masses{Function: "kroupa", Min: 0.08, Max: 0.5}, powerIntegral(0.3, 0.08, 0.5) / powerIntegral(1.3, 0.08, 0.5)},
+		{Masses{Function: "fixed", Mass: 2}, 2},
+	} {
+		sample, err := test.masses.Sampler()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		r := rand.New(rand.NewSource(1))
+		var sum float64
+		for i := 0; i < n; i++ {
+			m := sample(r)
+			if test.masses.Function != "fixed" && (m < test.masses.Min || m > test.masses.Max) {
+				t.Fatalf("%v: mass %v outside [%v, %v]", test.masses.Function, m, test.masses.Min, test.masses.Max)
+			}
+			sum += m
+		}
+		if mean := sum / n; math.Abs(mean-test.mean) > 0.01*test.mean {
+			t.Errorf("%v: mean mass = %v, want %v", test.masses.Function, mean, test.mean)
+		}
+	}
+}
+
+/*
+ * The Kroupa IMF is continuous at its breaks, so a range across a break
+ * has the number of bodies on each side in the ratio of the integrals
+ */
+func TestKroupaBreak(t *testing.T) {
+
+	sample, _ := Masses{Function: "kroupa", Min: 0.1, Max: 1}.Sampler()
+	r := rand.New(rand.NewSource(1))
+
+	const n = 100000
+	below := 0
+	for i := 0; i < n; i++ {
+		if sample(r) < 0.5 {
+			below++
+		}
+	}
+
+	low := powerIntegral(1.3, 0.1, 0.5)
+	high := 0.5 * powerIntegral(2.3, 0.5, 1)
+	if want := low / (low + high); math.Abs(float64(below)/n-want) > 0.01 {
+		t.Errorf("fraction below the break = %v, want %v", float64(below)/n, want)
+	}
+}
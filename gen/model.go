@@ -0,0 +1,273 @@
+package gen
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"proj3/phys"
+	"sort"
+	"strings"
+)
+
+// Constants for the uniform model
+const MaxVel = 2
+const MinVel = -2
+
+// Default parameters
+const DefaultW0 = 6
+const DefaultCentral = 10
+const DefaultDispersion = 0.05
+
+// Body is a generated body, relative to the centre of its model
+type Body struct {
+	Mass      float64
+	Position  [2]float64
+	Velocity  [2]float64
+	Component string // Name of the scenario component the body belongs to
+}
+
+// Params holds the parameters of the models. Each model only uses some of them
+type Params struct {
+	Width      float64 // Width of the box for the uniform model
+	Height     float64 // Height of the box for the uniform model
+	Radius     float64 // Scale radius: Plummer radius, King core radius, disk scale length or outer radius
+	Inner      float64 // Inner radius of the Keplerian disk
+	W0         float64 // Dimensionless central potential of the King model
+	Central    float64 // Mass of the central body of the Keplerian disk
+	Dispersion float64 // Random velocity of the disks as a fraction of the circular velocity
+}
+
+// Model sets the position and velocity of bodies whose masses are already set
+type Model func(r *rand.Rand, bodies []Body, p Params)
+
+// Models by the name used on the command line
+var Models = map[string]Model{
+	"uniform":  Uniform,
+	"plummer":  Plummer,
+	"king":     King,
+	"disk":     ExponentialDisk,
+	"kepler":   KeplerDisk,
+	"collapse": ColdCollapse,
+}
+
+/*
+ * Return the model with the given name
+ */
+func ParseModel(name string) (Model, error) {
+	if model, ok := Models[name]; ok {
+		return model, nil
+	}
+	return nil, fmt.Errorf("model must be one of %v. Not [%v]", ModelNames(), name)
+}
+
+/*
+ * Return the sorted names of every model
+ */
+func ModelNames() string {
+	names := make([]string, 0, len(Models))
+	for name := range Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+/*
+ * Return the default parameters for a box of the given size
+ * The radii are left at zero for Defaults to fill in for each model
+ */
+func NewParams(width, height float64) Params {
+	return Params{Width: width, Height: height, W0: DefaultW0, Central: DefaultCentral, Dispersion: DefaultDispersion}
+}
+
+/*
+ * Fill in the radii left at zero with the defaults of the named model, and check them
+ * Defaults are scaled to the smaller side of the box
+ *
+ * name: name of the model
+ * p: parameters, including the size of the box
+ *
+ * return: the parameters with the defaults filled in, and an error if they are invalid
+ */
+func Defaults(name string, p Params) (Params, error) {
+
+	side := math.Min(p.Width, p.Height)
+	if p.Radius <= 0 {
+		switch name {
+		case "king":
+			// The King model extends out to its tidal radius, so fit that in the box instead
+			if p.W0 > 0 {
+				p.Radius = side / 2 / KingTidalRadius(p.W0)
+			}
+		case "kepler":
+			p.Radius = side / 3
+		default:
+			p.Radius = side / 8
+		}
+	}
+	if p.Inner <= 0 && name == "kepler" {
+		// Keep the disk clear of the radius the central body's force is clamped to
+		p.Inner = math.Max(p.Radius/10, 2*phys.RadiusCoeff*p.Central)
+	}
+
+	if p.Width <= 0 || p.Height <= 0 || p.W0 <= 0 || p.Inner >= p.Radius || p.Central < 0 || p.Dispersion < 0 {
+		return p, fmt.Errorf("w0 must be greater than 0, inner less than radius, and central and dispersion "+
+			"must not be negative. Not [%v, %v, %v, %v, %v]", p.W0, p.Inner, p.Radius, p.Central, p.Dispersion)
+	}
+
+	return p, nil
+}
+
+/*
+ * Bodies at random positions in the box with random velocities between MinVel and MaxVel
+ */
+func Uniform(r *rand.Rand, bodies []Body, p Params) {
+	for i := range bodies {
+		bodies[i].Position = [2]float64{
+			(r.Float64() - 0.5) * p.Width,
+			(r.Float64() - 0.5) * p.Height}
+		bodies[i].Velocity = [2]float64{
+			r.Float64()*(MaxVel-MinVel) + MinVel,
+			r.Float64()*(MaxVel-MinVel) + MinVel}
+	}
+}
+
+/*
+ * Plummer sphere with scale radius p.Radius, projected onto the plane
+ * Velocities are drawn from the isotropic distribution function (Aarseth, Henon & Wielen, 1974)
+ */
+func Plummer(r *rand.Rand, bodies []Body, p Params) {
+
+	mass := totalMass(bodies)
+	for i := range bodies {
+
+		// Invert the cumulative mass, leaving out the far tail
+		var radius float64
+		for {
+			m := r.Float64()
+			radius = p.Radius / math.Sqrt(math.Pow(m, -2.0/3)-1)
+			if radius < 10*p.Radius {
+				break
+			}
+		}
+
+		// Speed as a fraction q of the escape speed, from g(q) = q^2 (1 - q^2)^3.5
+		var q float64
+		for {
+			q = r.Float64()
+			if 0.1*r.Float64() < q*q*math.Pow(1-q*q, 3.5) {
+				break
+			}
+		}
+		escape := math.Sqrt(2*phys.G*mass/p.Radius) * math.Pow(1+radius*radius/(p.Radius*p.Radius), -0.25)
+
+		bodies[i].Position = project(r, radius)
+		bodies[i].Velocity = project(r, q*escape)
+	}
+}
+
+/*
+ * Exponential disk with scale length p.Radius rotating on circular orbits
+ * The circular velocity uses the mass enclosed by each radius
+ */
+func ExponentialDisk(r *rand.Rand, bodies []Body, p Params) {
+
+	mass := totalMass(bodies)
+	for i := range bodies {
+
+		// The surface density e^(-R/Rd) has radii following a gamma distribution with shape 2
+		radius := -p.Radius * math.Log((1-r.Float64())*(1-r.Float64()))
+		x := radius / p.Radius
+		enclosed := mass * (1 - (1+x)*math.Exp(-x))
+
+		bodies[i].Position, bodies[i].Velocity = circularOrbit(r, radius, enclosed, p.Dispersion)
+	}
+}
+
+/*
+ * Disk of bodies between p.Inner and p.Radius orbiting a central body of mass p.Central
+ * The first body becomes the central body at rest at the centre
+ */
+func KeplerDisk(r *rand.Rand, bodies []Body, p Params) {
+
+	if len(bodies) == 0 {
+		return
+	}
+
+	bodies[0] = Body{Mass: p.Central}
+
+	// Place the disk uniformly in area, then give each body the mass inside its orbit
+	disk := bodies[1:]
+	radii := make([]float64, len(disk))
+	for i := range radii {
+		radii[i] = math.Sqrt(p.Inner*p.Inner + r.Float64()*(p.Radius*p.Radius-p.Inner*p.Inner))
+	}
+
+	order := make([]int, len(disk))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return radii[order[a]] < radii[order[b]] })
+
+	enclosed := p.Central
+	for _, i := range order {
+		disk[i].Position, disk[i].Velocity = circularOrbit(r, radii[i], enclosed, p.Dispersion)
+		enclosed += disk[i].Mass
+	}
+}
+
+/*
+ * Uniform disk of radius p.Radius with every body at rest
+ */
+func ColdCollapse(r *rand.Rand, bodies []Body, p Params) {
+	for i := range bodies {
+		radius := p.Radius * math.Sqrt(r.Float64())
+		angle := 2 * math.Pi * r.Float64()
+		bodies[i].Position = [2]float64{radius * math.Cos(angle), radius * math.Sin(angle)}
+		bodies[i].Velocity = [2]float64{0, 0}
+	}
+}
+
+/*
+ * Return the total mass of the bodies
+ */
+func totalMass(bodies []Body) float64 {
+	var mass float64
+	for i := range bodies {
+		mass += bodies[i].Mass
+	}
+	return mass
+}
+
+/*
+ * Return the plane projection of a vector of the given length in a random 3D direction
+ */
+func project(r *rand.Rand, length float64) [2]float64 {
+	cosTheta := 2*r.Float64() - 1
+	sinTheta := math.Sqrt(1 - cosTheta*cosTheta)
+	phi := 2 * math.Pi * r.Float64()
+	return [2]float64{length * sinTheta * math.Cos(phi), length * sinTheta * math.Sin(phi)}
+}
+
+/*
+ * Return a random position at the radius and the velocity of a circular orbit around the enclosed mass
+ *
+ * dispersion: random velocity added to each component as a fraction of the circular velocity
+ */
+func circularOrbit(r *rand.Rand, radius, enclosed, dispersion float64) ([2]float64, [2]float64) {
+
+	angle := 2 * math.Pi * r.Float64()
+	cos, sin := math.Cos(angle), math.Sin(angle)
+
+	speed := 0.0
+	if radius > 0 {
+		speed = math.Sqrt(phys.G * enclosed / radius)
+	}
+
+	pos := [2]float64{radius * cos, radius * sin}
+	vel := [2]float64{
+		-speed*sin + dispersion*speed*r.NormFloat64(),
+		speed*cos + dispersion*speed*r.NormFloat64()}
+
+	return pos, vel
+}
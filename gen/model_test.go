@@ -0,0 +1,88 @@
+package gen
+
+import (
+	"math"
+	"math/rand"
+	"proj3/phys"
+	"sort"
+	"testing"
+)
+
+/*
+ * Return n bodies of unit mass
+ */
+func unitBodies(n int) []Body {
+	bodies := make([]Body, n)
+	for i := range bodies {
+		bodies[i].Mass = 1
+	}
+	return bodies
+}
+
+/*
+ * Half of the mass of a projected Plummer sphere lies inside the scale radius
+ */
+func TestPlummerHalfMassRadius(t *testing.T) {
+
+	bodies := unitBodies(5000)
+	Plummer(rand.New(rand.NewSource(1)), bodies, Params{Radius: 50})
+
+	radii := make([]float64, len(bodies))
+	for i := range bodies {
+		radii[i] = math.Hypot(bodies[i].Position[0], bodies[i].Position[1])
+	}
+	sort.Float64s(radii)
+
+	if median := radii[len(radii)/2]; math.Abs(median-50) > 0.05*50 {
+		t.Errorf("projected half-mass radius = %v, want 50", median)
+	}
+}
+
+/*
+ * A King model with W0 = 6 has a concentration log10(rt/r0) of about 1.26
+ */
+func TestKingTidalRadius(t *testing.T) {
+
+	profile := newKingProfile(6)
+	if c := math.Log10(profile.tidalRadius()); math.Abs(c-1.26) > 0.02 {
+		t.Errorf("concentration = %v, want 1.26", c)
+	}
+
+	bodies := unitBodies(1000)
+	King(rand.New(rand.NewSource(1)), bodies, Params{Radius: 10, W0: 6})
+	for i := range bodies {
+		if r := math.Hypot(bodies[i].Position[0], bodies[i].Position[1]); r > 10*profile.tidalRadius() {
+			t.Fatalf("body %v is at %v, outside the tidal radius", i, r)
+		}
+	}
+}
+
+/*
+ * Without dispersion every body of a Keplerian disk is on a circular orbit around the central body
+ */
+func TestKeplerDiskCircularOrbits(t *testing.T) {
+
+	bodies := unitBodies(100)
+	KeplerDisk(rand.New(rand.NewSource(1)), bodies, Params{Radius: 200, Inner: 20, Central: 1000})
+
+	if bodies[0].Mass != 1000 || bodies[0].Position != [2]float64{} || bodies[0].Velocity != [2]float64{} {
+		t.Fatalf("central body = %+v", bodies[0])
+	}
+
+	for i := 1; i < len(bodies); i++ {
+		pos, vel := bodies[i].Position, bodies[i].Velocity
+		r := math.Hypot(pos[0], pos[1])
+		if r < 20 || r > 200 {
+			t.Errorf("body %v is at radius %v, outside the disk", i, r)
+		}
+
+		// Perpendicular to the radius, at least as fast as the orbit around the central body alone
+		if math.Abs(pos[0]*vel[0]+pos[1]*vel[1]) > 1e-9*r {
+			t.Errorf("body %v has a radial velocity", i)
+		}
+		speed := math.Hypot(vel[0], vel[1])
+		if min := math.Sqrt(phys.G * 1000 / r); speed < min*(1-1e-9) || speed > min*math.Sqrt(1.1) {
+			t.Errorf("body %v has speed %v, want about %v", i, speed, min)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package gen
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"proj3/phys"
+)
+
+// Component is one model placed in a scenario
+type Component struct {
+	Name     string     // Name each body of the component is tagged with. Defaults to the model and index
+	Model    string     // Name of the model
+	Count    int        // Number of bodies
+	Offset   [2]float64 // Centre of the component relative to the centre of the box
+	Velocity [2]float64 // Bulk velocity added to every body
+	Rotation float64    // Angle in degrees to turn the component by, from the x axis towards the y axis
+	Orbit    string     // Name of an earlier component to put this one on a circular orbit around
+	Masses   Masses     // Distribution of the masses of the bodies
+	Virial   float64    // Virial ratio to scale the velocities to in the component's own frame. 0 leaves them
+	Params              // Parameters of the model. Zero radii are filled in by Defaults
+}
+
+/*
+ * Read a scenario from a JSON array of components
+ * Each component starts as a copy of the defaults, so fields it leaves out take their values from the command line
+ *
+ * in: Reader to read the scenario from
+ * defaults: component holding the default mass distribution, virial ratio and parameters, including the box
+ *
+ * return: the components, and any error reading or checking them
+ */
+func ReadScenario(in io.Reader, defaults Component) ([]Component, error) {
+
+	var raw []json.RawMessage
+	if err := json.NewDecoder(in).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	components := make([]Component, len(raw))
+	names := make(map[string]bool)
+	for i := range raw {
+		c := defaults
+		if err := json.Unmarshal(raw[i], &c); err != nil {
+			return nil, fmt.Errorf("component %v: %v", i, err)
+		}
+		c.Width, c.Height = defaults.Width, defaults.Height
+
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("%v%v", c.Model, i)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("component %v: name %v is used twice", i, c.Name)
+		}
+		if c.Orbit != "" && !names[c.Orbit] {
+			return nil, fmt.Errorf("component %v: orbit must name an earlier component. Not [%v]", c.Name, c.Orbit)
+		}
+		names[c.Name] = true
+
+		if err := c.Check(); err != nil {
+			return nil, err
+		}
+		components[i] = c
+	}
+
+	return components, nil
+}
+
+/*
+ * Fill in the default parameters of the component's model and check the component
+ *
+ * return: an error naming the component if it is invalid
+ */
+func (c *Component) Check() error {
+
+	if _, err := ParseModel(c.Model); err != nil {
+		return fmt.Errorf("component %v: %v", c.Name, err)
+	}
+	if c.Count < 0 || c.Virial < 0 {
+		return fmt.Errorf("component %v: count and virial must not be negative. Not [%v, %v]", c.Name, c.Count, c.Virial)
+	}
+
+	var err error
+	if c.Params, err = Defaults(c.Model, c.Params); err != nil {
+		return fmt.Errorf("component %v: %v", c.Name, err)
+	}
+	if _, err = c.Masses.Sampler(); err != nil {
+		return fmt.Errorf("component %v: %v", c.Name, err)
+	}
+
+	return nil
+}
+
+/*
+ * Generate every component of a scenario, relative to the centre of the box
+ * The bodies are in the order of the components, so their index is a unique Id
+ *
+ * r: random source. The same seed always gives the same bodies
+ * components: components of the scenario
+ *
+ * return: the bodies of every component, and an error if a mass distribution is invalid
+ */
+func Compose(r *rand.Rand, components []Component) ([]Body, error) {
+
+	bodies := make([]Body, 0)
+	centres := make(map[string]Body)
+
+	for _, c := range components {
+		mass, err := c.Masses.Sampler()
+		if err != nil {
+			return nil, fmt.Errorf("component %v: %v", c.Name, err)
+		}
+
+		part := make([]Body, c.Count)
+		for i := range part {
+			part[i].Mass = mass(r)
+		}
+		Models[c.Model](r, part, c.Params)
+		if c.Virial > 0 {
+			if err := SetVirialRatio(r, part, c.Virial); err != nil {
+				return nil, fmt.Errorf("component %v: %v", c.Name, err)
+			}
+		}
+
+		// Put the component on a circular orbit around the centre of the other one
+		velocity := c.Velocity
+		if host, ok := centres[c.Orbit]; ok {
+			d := [2]float64{c.Offset[0] - host.Position[0], c.Offset[1] - host.Position[1]}
+			if distance := math.Hypot(d[0], d[1]); distance > 0 {
+				speed := math.Sqrt(phys.G * (host.Mass + totalMass(part)) / distance)
+				velocity[0] += host.Velocity[0] - speed*d[1]/distance
+				velocity[1] += host.Velocity[1] + speed*d[0]/distance
+			}
+		}
+
+		sin, cos := math.Sincos(c.Rotation * math.Pi / 180)
+		for i := range part {
+			pos, vel := part[i].Position, part[i].Velocity
+			part[i].Position = [2]float64{cos*pos[0] - sin*pos[1] + c.Offset[0], sin*pos[0] + cos*pos[1] + c.Offset[1]}
+			part[i].Velocity = [2]float64{cos*vel[0] - sin*vel[1] + velocity[0], sin*vel[0] + cos*vel[1] + velocity[1]}
+			part[i].Component = c.Name
+		}
+
+		centres[c.Name] = Body{Mass: totalMass(part), Position: c.Offset, Velocity: velocity}
+		bodies = append(bodies, part...)
+	}
+
+	return bodies, nil
+}
@@ -0,0 +1,93 @@
+package gen
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*
+ * Return defaults giving every body unit mass in a 500 by 500 box
+ */
+func unitDefaults() Component {
+	return Component{Masses: Masses{Function: "fixed", Mass: 1}, Params: NewParams(500, 500)}
+}
+
+/*
+ * Components are offset, rotated, moved and tagged, and an orbiting component
+ * gets the circular velocity around the earlier one
+ */
+func TestCompose(t *testing.T) {
+
+	scenario := `[
+		{"Name": "host", "Model": "collapse", "Count": 10, "Radius": 20},
+		{"Name": "moon", "Model": "kepler", "Count": 5, "Radius": 10, "Inner": 5, "Central": 1, "Dispersion": 0,
+		 "Offset": [0, 100], "Velocity": [0, 1], "Rotation": 90, "Orbit": "host"}
+	]`
+	components, err := ReadScenario(strings.NewReader(scenario), unitDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bodies, err := Compose(rand.New(rand.NewSource(1)), components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 15 || bodies[0].Component != "host" || bodies[10].Component != "moon" {
+		t.Fatalf("got %v bodies, first tagged %v and eleventh %v", len(bodies), bodies[0].Component, bodies[10].Component)
+	}
+
+	// The central body of the moon sits at its offset, moving with the bulk and orbital velocity
+	speed := math.Sqrt((10.0 + 5) / 100)
+	centre := bodies[10]
+	if centre.Position != [2]float64{0, 100} ||
+		math.Abs(centre.Velocity[0]+speed) > 1e-12 || math.Abs(centre.Velocity[1]-1) > 1e-12 {
+		t.Errorf("moon centre at %v moving at %v, want [0, 100] moving at [%v, 1]", centre.Position, centre.Velocity, -speed)
+	}
+
+	// The rotation turns the orbits of the moon along with its positions, so they stay circular
+	for i := 11; i < len(bodies); i++ {
+		d := [2]float64{bodies[i].Position[0] - centre.Position[0], bodies[i].Position[1] - centre.Position[1]}
+		v := [2]float64{bodies[i].Velocity[0] - centre.Velocity[0], bodies[i].Velocity[1] - centre.Velocity[1]}
+		if math.Abs(d[0]*v[0]+d[1]*v[1]) > 1e-9 {
+			t.Errorf("body %v has a radial velocity around the moon", i)
+		}
+	}
+}
+
+func TestReadScenarioErrors(t *testing.T) {
+
+	for _, scenario := range []string{
+		`[{"Model": "galaxy", "Count": 1}]`,
+		`[{"Model": "plummer", "Count": -1}]`,
+		`[{"Name": "a", "Model": "plummer"}, {"Name": "a", "Model": "king"}]`,
+		`[{"Model": "plummer", "Orbit": "later"}, {"Name": "later", "Model": "plummer"}]`,
+		`[{"Model": "plummer", "Masses": {"Function": "salpeter", "Min": 2, "Max": 1}}]`,
+		`[{"Model": "plummer", "Virial": -1}]`,
+	} {
+		if _, err := ReadScenario(strings.NewReader(scenario), unitDefaults()); err == nil {
+			t.Errorf("no error reading %v", scenario)
+		}
+	}
+}
+
+/*
+ * The same seed always generates the same bodies
+ */
+func TestComposeSeed(t *testing.T) {
+
+	scenario := `[{"Model": "king", "Count": 50}, {"Model": "disk", "Count": 50, "Masses": {"Function": "kroupa"}}]`
+	components, err := ReadScenario(strings.NewReader(scenario),
+		Component{Masses: Masses{Function: "salpeter", Min: 0.5, Max: 3}, Params: NewParams(500, 500)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := Compose(rand.New(rand.NewSource(42)), components)
+	second, _ := Compose(rand.New(rand.NewSource(42)), components)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("bodies differ between runs with the same seed")
+	}
+}
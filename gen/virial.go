@@ -0,0 +1,105 @@
+package gen
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"math/rand"
+	"proj3/phys"
+)
+
+/*
+ * Move the bodies into their centre of mass frame, with the centre of mass at rest at the origin
+ */
+func CentreOfMassFrame(bodies []Body) {
+
+	mass := totalMass(bodies)
+	if mass == 0 {
+		return
+	}
+
+	var pos, vel [2]float64
+	for i := range bodies {
+		for k := 0; k < 2; k++ {
+			pos[k] += bodies[i].Mass * bodies[i].Position[k] / mass
+			vel[k] += bodies[i].Mass * bodies[i].Velocity[k] / mass
+		}
+	}
+
+	for i := range bodies {
+		for k := 0; k < 2; k++ {
+			bodies[i].Position[k] -= pos[k]
+			bodies[i].Velocity[k] -= vel[k]
+		}
+	}
+}
+
+/*
+ * Return the kinetic energy of the bodies
+ */
+func KineticEnergy(bodies []Body) float64 {
+	var kinetic float64
+	for i := range bodies {
+		v := bodies[i].Velocity
+		kinetic += 0.5 * bodies[i].Mass * (v[0]*v[0] + v[1]*v[1])
+	}
+	return kinetic
+}
+
+/*
+ * Return the potential energy summed over every pair of bodies
+ * The pairs use phys, so the energy has the same G and clamped distances as the simulation
+ */
+func PotentialEnergy(bodies []Body) float64 {
+
+	simulated := make([]phys.Body, len(bodies))
+	for i := range bodies {
+		simulated[i] = phys.NewBody(float32(bodies[i].Mass), i,
+			rl.NewVector2(float32(bodies[i].Position[0]), float32(bodies[i].Position[1])), rl.NewVector2(0, 0))
+	}
+
+	var potential float64
+	for i := range simulated {
+		for j := i + 1; j < len(simulated); j++ {
+			d := rl.NewVector2(simulated[j].Position.X-simulated[i].Position.X,
+				simulated[j].Position.Y-simulated[i].Position.Y)
+			potential += float64(simulated[i].PotentialEnergy(&simulated[j], d))
+		}
+	}
+
+	return potential
+}
+
+/*
+ * Move the bodies into their centre of mass frame and scale their velocities to give the virial ratio 2K/|W|
+ * Bodies that are all at rest are first given random isotropic velocities
+ *
+ * r: random source for the velocities of bodies at rest
+ * bodies: bodies to scale the velocities of
+ * ratio: virial ratio to scale to. 1 is equilibrium and 0 leaves every body at rest
+ *
+ * return: an error if the bodies have no potential energy to balance
+ */
+func SetVirialRatio(r *rand.Rand, bodies []Body, ratio float64) error {
+
+	potential := PotentialEnergy(bodies)
+	if potential == 0 {
+		return fmt.Errorf("bodies with no potential energy can not be scaled to a virial ratio")
+	}
+
+	CentreOfMassFrame(bodies)
+	if KineticEnergy(bodies) == 0 {
+		for i := range bodies {
+			bodies[i].Velocity = [2]float64{r.NormFloat64(), r.NormFloat64()}
+		}
+		CentreOfMassFrame(bodies)
+	}
+
+	scale := math.Sqrt(ratio * math.Abs(potential) / (2 * KineticEnergy(bodies)))
+	for i := range bodies {
+		bodies[i].Velocity[0] *= scale
+		bodies[i].Velocity[1] *= scale
+	}
+
+	return nil
+}
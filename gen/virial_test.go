@@ -0,0 +1,44 @@
+package gen
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"math/rand"
+	"proj3/diag"
+	"proj3/phys"
+	"testing"
+)
+
+/*
+ * After scaling, the simulation's own diagnostics measure the target virial ratio and no bulk motion
+ */
+func TestSetVirialRatio(t *testing.T) {
+
+	for _, model := range []string{"plummer", "collapse"} {
+		r := rand.New(rand.NewSource(1))
+		bodies := make([]Body, 300)
+		for i := range bodies {
+			bodies[i].Mass = 0.5 + 2.5*r.Float64()
+		}
+		Models[model](r, bodies, Params{Radius: 100})
+
+		if err := SetVirialRatio(r, bodies, 1); err != nil {
+			t.Fatal(err)
+		}
+
+		simulated := make([]phys.Body, len(bodies))
+		for i := range bodies {
+			simulated[i] = phys.NewBody(float32(bodies[i].Mass), i,
+				rl.NewVector2(float32(bodies[i].Position[0]), float32(bodies[i].Position[1])),
+				rl.NewVector2(float32(bodies[i].Velocity[0]), float32(bodies[i].Velocity[1])))
+		}
+		d := diag.Compute(0, simulated, rl.NewRectangle(-500, -500, 1000, 1000), phys.Open)
+
+		if math.Abs(d.VirialRatio-1) > 1e-4 {
+			t.Errorf("%v: virial ratio = %v, want 1", model, d.VirialRatio)
+		}
+		if math.Hypot(d.Momentum[0], d.Momentum[1]) > 1e-3 || math.Hypot(d.CentreOfMass[0], d.CentreOfMass[1]) > 1e-3 {
+			t.Errorf("%v: momentum %v and centre of mass %v, want zero", model, d.Momentum, d.CentreOfMass)
+		}
+	}
+}
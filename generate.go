@@ -2,60 +2,187 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"math/rand"
 	"os"
+	"proj3/gen"
 	"strconv"
 	"time"
 )
 
-const usage = "Usage: generate <num_of_obj> <x> <y> [-s] \n" +
+const usage = "Usage: generate <num_of_obj> <x> <y> [-s] [-model=NAME] [-radius=FLOAT] [-inner=FLOAT]\n" +
+	"\t [-w0=FLOAT] [-central=FLOAT] [-dispersion=FLOAT] [-seed=INTEGER] [-imf=NAME]\n" +
+	"\t [-minmass=FLOAT] [-maxmass=FLOAT] [-mass=FLOAT] [-masssigma=FLOAT] [-virial=FLOAT] [-com]\n" +
+	"       generate -scenario=FILE <x> <y> [-s] [-seed=INTEGER] [-imf=NAME] ...\n" +
 	"\t <num_of_obj> = the number of objects you want to generate\n" +
 	"\t <x> = the width of the window. Integer\n" +
 	"\t <y> = the height of the window. Integer\n" +
-	"\t -s = Have all objects start with 0 initial velocity."
+	"\t -s = Have all objects start with 0 initial velocity.\n" +
+	"\t -model = uniform (default), plummer, king, disk (exponential), kepler or collapse (cold uniform disk).\n" +
+	"\t -radius = Scale radius of the model. Plummer radius, King core radius, disk scale length,\n" +
+	"\t           or outer radius of the kepler and collapse models. Defaults to an eighth of the smaller side,\n" +
+	"\t           a King tidal radius of half the smaller side, or a kepler disk out to a third of it.\n" +
+	"\t -inner = Inner radius of the kepler disk. Defaults to a tenth of the radius, and at least\n" +
+	"\t          twice the radius of the central body.\n" +
+	"\t -w0 = Dimensionless central potential of the king model.\n" +
+	"\t -central = Mass of the central body of the kepler disk.\n" +
+	"\t -dispersion = Random velocity of the disk and kepler models as a fraction of the circular velocity.\n" +
+	"\t -scenario = JSON array of components, each with its own model, parameters, offset, velocity and rotation.\n" +
+	"\t -seed = Seed of the random numbers. The same seed gives the same output. Defaults to one picked from the time.\n" +
+	"\t -imf = Mass function. uniform (default), salpeter, kroupa, lognormal or fixed.\n" +
+	"\t -minmass, -maxmass = Range of the masses.\n" +
+	"\t -mass = Mass of every body for fixed, and the median mass for lognormal.\n" +
+	"\t -masssigma = Standard deviation of the natural log of the lognormal masses.\n" +
+	"\t -virial = Scale the velocities of each component to this virial ratio 2K/|W| in its centre of mass frame.\n" +
+	"\t           1 is equilibrium. Components at rest are given random velocities. Can not be used with -s.\n" +
+	"\t -com = Move the bodies into their centre of mass frame, at rest in the centre of the window."
 
-// Constants to change values
+// Default range of the masses
 const MaxMass = 3
 const MinMass = 0.5
-const MaxVel = 2
-const MinVel = -2
 
 func main() {
-	rand.Seed(time.Now().UTC().UnixNano())
 
-	// Read in CL arguments
-	args := os.Args[1:]
-	if len(args) < 3 || len(args) > 4 {
+	// Flag commands for CLI
+	sPtr := flag.Bool("s", false, "Have all objects start with 0 initial velocity.")
+	modelPtr := flag.String("model", "uniform", "Initial condition model.")
+	radiusPtr := flag.Float64("radius", 0, "Scale radius of the model.")
+	innerPtr := flag.Float64("inner", 0, "Inner radius of the kepler disk.")
+	w0Ptr := flag.Float64("w0", gen.DefaultW0, "Central potential of the king model.")
+	centralPtr := flag.Float64("central", gen.DefaultCentral, "Mass of the central body of the kepler disk.")
+	dispersionPtr := flag.Float64("dispersion", gen.DefaultDispersion, "Random velocity of the disks.")
+	scenarioPtr := flag.String("scenario", "", "JSON file of components to compose.")
+	seedPtr := flag.Int64("seed", 0, "Seed of the random numbers. 0 picks one from the time.")
+	imfPtr := flag.String("imf", "uniform", "Mass function: uniform, salpeter, kroupa, lognormal or fixed.")
+	minMassPtr := flag.Float64("minmass", MinMass, "Smallest mass.")
+	maxMassPtr := flag.Float64("maxmass", MaxMass, "Largest mass.")
+	massPtr := flag.Float64("mass", 1, "Mass of the fixed function and median of the lognormal function.")
+	sigmaPtr := flag.Float64("masssigma", 0.5, "Standard deviation of the natural log of the lognormal masses.")
+	virialPtr := flag.Float64("virial", 0, "Virial ratio to scale the velocities of each component to. 0 leaves them.")
+	comPtr := flag.Bool("com", false, "Move the bodies into their centre of mass frame.")
+
+	// Read in CL arguments, allowing the flags before or after them
+	args := make([]string, 0)
+	rest := os.Args[1:]
+	for {
+		if err := flag.CommandLine.Parse(rest); err != nil {
+			os.Exit(0)
+		}
+		rest = flag.Args()
+		if len(rest) == 0 {
+			break
+		}
+		args = append(args, rest[0])
+		rest = rest[1:]
+	}
+	if (*scenarioPtr == "" && len(args) != 3) || (*scenarioPtr != "" && len(args) != 2) {
 		fmt.Println(usage)
 		os.Exit(0)
 	}
 
 	// Parse the arguments
-	numObj, _ := strconv.Atoi(args[0])
-	X, _ := strconv.Atoi(args[1])
-	Y, _ := strconv.Atoi(args[2])
-	stationary := false
-	if len(args) == 4 && args[3] == "-s" {
-		stationary = true
+	numObj := 0
+	if *scenarioPtr == "" {
+		numObj, _ = strconv.Atoi(args[0])
+		args = args[1:]
+	}
+	X, _ := strconv.Atoi(args[0])
+	Y, _ := strconv.Atoi(args[1])
+	if numObj < 0 || X <= 0 || Y <= 0 {
+		fmt.Printf("num_of_obj must not be negative and x and y must be greater than 0. Not [%v, %v, %v]\n",
+			numObj, X, Y)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if *sPtr && *virialPtr > 0 {
+		fmt.Println("-s and -virial can not be used together")
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	// The options are the defaults of every component
+	defaults := gen.Component{
+		Masses: gen.Masses{Function: *imfPtr, Min: *minMassPtr, Max: *maxMassPtr, Mass: *massPtr, Sigma: *sigmaPtr},
+		Virial: *virialPtr,
+		Params: gen.NewParams(float64(X), float64(Y)),
+	}
+	defaults.Radius, defaults.Inner, defaults.W0 = *radiusPtr, *innerPtr, *w0Ptr
+	defaults.Central, defaults.Dispersion = *centralPtr, *dispersionPtr
+
+	// A single model is a scenario with one component at the centre
+	var components []gen.Component
+	var err error
+	if *scenarioPtr != "" {
+		components, err = readScenario(*scenarioPtr, defaults)
+	} else {
+		components = []gen.Component{defaults}
+		components[0].Name, components[0].Model, components[0].Count = *modelPtr, *modelPtr, numObj
+		err = components[0].Check()
+	}
+
+	// Let the models place the bodies around the centre of the window
+	var bodies []gen.Body
+	if err == nil {
+		bodies, err = gen.Compose(rand.New(rand.NewSource(seed(*seedPtr))), components)
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+	if *comPtr {
+		gen.CentreOfMassFrame(bodies)
 	}
 
 	// Decoder to output JSON
 	dec := json.NewEncoder(os.Stdout)
 
-	for i := 0; i < numObj; i++ {
+	for i := 0; i < len(bodies); i++ {
 		// Holds the JSON object_data
 		objectData := make(map[string]interface{})
 		objectData["Command"] = "ADD"
-		objectData["Mass"] = (rand.Float32() * (MaxMass - MinMass)) + MinMass
-		objectData["Position"] = [2]int{rand.Intn(X), rand.Intn(Y)}
+		objectData["Mass"] = float32(bodies[i].Mass)
+		objectData["Position"] = [2]float32{
+			float32(bodies[i].Position[0] + float64(X)/2),
+			float32(bodies[i].Position[1] + float64(Y)/2)}
 		vel := [2]float32{0, 0}
-		if !stationary {
-			vel[0] = (rand.Float32() * (MaxVel - MinVel)) + MinVel
-			vel[1] = (rand.Float32() * (MaxVel - MinVel)) + MinVel
+		if !*sPtr {
+			vel[0] = float32(bodies[i].Velocity[0])
+			vel[1] = float32(bodies[i].Velocity[1])
 		}
 		objectData["Velocity"] = vel
 		objectData["Id"] = i
+		if *scenarioPtr != "" {
+			objectData["Component"] = bodies[i].Component
+		}
 		_ = dec.Encode(objectData)
 	}
 }
+
+/*
+ * Return the seed to generate with, picking one from the time if it is 0
+ * A picked seed is written to Stderr so the output can be generated again
+ */
+func seed(s int64) int64 {
+	if s == 0 {
+		s = time.Now().UTC().UnixNano()
+		fmt.Fprintf(os.Stderr, "seed: %v\n", s)
+	}
+	return s
+}
+
+/*
+ * Read the components of a scenario file
+ */
+func readScenario(path string, defaults gen.Component) ([]gen.Component, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return gen.ReadScenario(f, defaults)
+}
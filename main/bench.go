@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"proj3/phys"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const benchUsage = "Usage: ./sim bench [-inputs=FILES] [-threads=LIST] [-steps=INTEGER] [-repeats=INTEGER]\n" +
+	"\t [-json=FILE] [-csv=FILE] [-svg=FILE] <X> <Y>\n" +
+	"\t -inputs = Comma separated input files. Defaults to object_data/small.txt,medium.txt,large.txt.\n" +
+	"\t -threads = Comma separated thread counts. 0 runs the sequential version.\n" +
+	"\t -steps = Number of time-steps in each run. Must be greater than 0.\n" +
+	"\t -repeats = Number of runs of each input and thread count. Must be greater than 0.\n" +
+	"\t -json = File to write the report into as JSON. Defaults to Stdout.\n" +
+	"\t -csv = File to write the report into as CSV.\n" +
+	"\t -svg = File to draw the speedup chart into.\n" +
+	"\t <X> = The width of the window. Positive Integer.\n" +
+	"\t <Y> = The height of the window. Positive Integer."
+
+// Time spent in each phase of a run, summed over its time-steps and every thread
+type phaseTimes struct {
+	Build     time.Duration // Building the tree or mesh
+	Force     time.Duration // Calculating the forces on each body
+	Integrate time.Duration // Updating each body and applying the boundary
+	Output    time.Duration // Recording and encoding the output
+}
+
+// Mean and standard deviation of a phase over the repeated runs, in seconds
+type benchStat struct {
+	Mean   float64
+	StdDev float64
+}
+
+// Benchmark report for one input and thread count
+// The phases are summed over every thread, and the parallel driver builds the next tree while the forces
+// are calculated, so they need not add up to the total, which is the wall time of the run
+type benchResult struct {
+	Input     string
+	Bodies    int
+	Threads   int
+	Steps     int
+	Repeats   int
+	Build     benchStat
+	Force     benchStat
+	Integrate benchStat
+	Output    benchStat
+	Total     benchStat
+	Speedup   float64 // Mean sequential (or fewest threads) total divided by this mean total
+}
+
+/*
+ * Run an input through the console driver the program runs for the thread count, with the output thrown
+ * away and each phase timed by the profiler
+ *
+ * input: contents of the input file
+ * threads: Integer - number of threads. 0 for sequential
+ * steps: Integer - number of time-steps
+ *
+ * return: the wall time of the run and the time spent in each phase
+ */
+func timedRun(input []byte, threads, steps int) (time.Duration, phaseTimes) {
+
+	// Tree interactions are not counted, as that walks the tree a second time for every body
+	var lines bytes.Buffer
+	Profile = newProfiler(json.NewEncoder(&lines), false)
+	defer func() { Profile = nil }()
+
+	ThreadCount = threads
+	start := time.Now()
+	if threads == 0 {
+		sequential(steps, bytes.NewReader(input), ioutil.Discard)
+	} else {
+		parallel(steps, bytes.NewReader(input), ioutil.Discard)
+	}
+	wall := time.Since(start)
+
+	var times phaseTimes
+	ms := func(v float64) time.Duration { return time.Duration(v * float64(time.Millisecond)) }
+	dec := json.NewDecoder(&lines)
+	for {
+		var step stepProfile
+		if dec.Decode(&step) != nil {
+			break
+		}
+		times.Build += ms(step.BuildMs)
+		times.Force += ms(step.ForceMs)
+		times.Integrate += ms(step.UpdateMs)
+		times.Output += ms(step.OutputMs)
+	}
+
+	return wall, times
+}
+
+/*
+ * Return the number of bodies in an input file's contents
+ */
+func countBodies(input []byte) int {
+	bodies := make([]phys.Body, 0)
+	readData(&bodies, nil, nil, json.NewDecoder(bytes.NewReader(input)), nil, nil)
+	return len(bodies)
+}
+
+/*
+ * Time repeated runs of one input with one thread count
+ *
+ * name: name of the input
+ * input: contents of the input file
+ * threads: Integer - number of threads. 0 for sequential
+ * steps: Integer - number of time-steps in each run
+ * repeats: Integer - number of runs
+ *
+ * return: the statistics of the runs
+ */
+func benchRun(name string, input []byte, threads, steps, repeats int) benchResult {
+
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(int(math.Max(float64(threads), 1))))
+
+	var build, force, integrate, output, total []float64
+	for r := 0; r < repeats; r++ {
+		wall, times := timedRun(input, threads, steps)
+		build = append(build, times.Build.Seconds())
+		force = append(force, times.Force.Seconds())
+		integrate = append(integrate, times.Integrate.Seconds())
+		output = append(output, times.Output.Seconds())
+		total = append(total, wall.Seconds())
+	}
+
+	return benchResult{name, countBodies(input), threads, steps, repeats, newBenchStat(build), newBenchStat(force),
+		newBenchStat(integrate), newBenchStat(output), newBenchStat(total), 0}
+}
+
+/*
+ * Return the mean and sample standard deviation of the values
+ */
+func newBenchStat(values []float64) benchStat {
+
+	var mean float64
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	var variance float64
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	if len(values) > 1 {
+		variance /= float64(len(values) - 1)
+	}
+
+	return benchStat{mean, math.Sqrt(variance)}
+}
+
+/*
+ * Set the speedup of each result relative to the first result of the same input
+ */
+func setSpeedup(results []benchResult) {
+
+	baseline := make(map[string]float64)
+	for i := range results {
+		if _, ok := baseline[results[i].Input]; !ok {
+			baseline[results[i].Input] = results[i].Total.Mean
+		}
+		if results[i].Total.Mean > 0 {
+			results[i].Speedup = baseline[results[i].Input] / results[i].Total.Mean
+		}
+	}
+}
+
+/*
+ * Write the results as CSV with one row for each input and thread count
+ */
+func writeBenchCSV(w io.Writer, results []benchResult) error {
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"input", "bodies", "threads", "steps", "repeats",
+		"build_mean", "build_stddev", "force_mean", "force_stddev",
+		"integrate_mean", "integrate_stddev", "output_mean", "output_stddev", "total_mean", "total_stddev", "speedup"})
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	for _, r := range results {
+		_ = out.Write([]string{r.Input, strconv.Itoa(r.Bodies), strconv.Itoa(r.Threads),
+			strconv.Itoa(r.Steps), strconv.Itoa(r.Repeats),
+			f(r.Build.Mean), f(r.Build.StdDev), f(r.Force.Mean), f(r.Force.StdDev),
+			f(r.Integrate.Mean), f(r.Integrate.StdDev), f(r.Output.Mean), f(r.Output.StdDev),
+			f(r.Total.Mean), f(r.Total.StdDev), f(r.Speedup)})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+/*
+ * Draw a chart of speedup against thread count with a line for each input
+ */
+func writeBenchSVG(w io.Writer, results []benchResult) error {
+
+	const width, height, margin = 640.0, 420.0, 60.0
+	colours := []string{"blue", "red", "orange", "green", "purple", "brown"}
+	markers := []string{"square", "diamond", "triangle"}
+
+	// Axis ranges
+	maxThreads, maxSpeedup := 1.0, 1.0
+	inputs := make([]string, 0)
+	for _, r := range results {
+		maxThreads = math.Max(maxThreads, float64(r.Threads))
+		maxSpeedup = math.Max(maxSpeedup, r.Speedup)
+		if len(inputs) == 0 || inputs[len(inputs)-1] != r.Input {
+			inputs = append(inputs, r.Input)
+		}
+	}
+	maxSpeedup = math.Ceil(maxSpeedup)
+
+	x := func(threads float64) float64 { return margin + threads/maxThreads*(width-2*margin) }
+	y := func(speedup float64) float64 { return height - margin - speedup/maxSpeedup*(height-2*margin) }
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" "+
+		"font-family=\"sans-serif\" font-size=\"12\">\n", width, height)
+	fmt.Fprintf(&svg, "<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+	fmt.Fprintf(&svg, "<text x=\"%v\" y=\"25\" text-anchor=\"middle\" font-size=\"16\">"+
+		"Number of Threads vs. Speedup</text>\n", width/2)
+
+	// Axes, grid and labels
+	fmt.Fprintf(&svg, "<line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"black\"/>\n",
+		margin, height-margin, width-margin, height-margin)
+	fmt.Fprintf(&svg, "<line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"black\"/>\n",
+		margin, margin, margin, height-margin)
+	for s := 0.0; s <= maxSpeedup; s++ {
+		fmt.Fprintf(&svg, "<line x1=\"%v\" y1=\"%.1f\" x2=\"%v\" y2=\"%.1f\" stroke=\"#ddd\"/>\n",
+			margin, y(s), width-margin, y(s))
+		fmt.Fprintf(&svg, "<text x=\"%v\" y=\"%.1f\" text-anchor=\"end\">%v</text>\n", margin-8, y(s)+4, s)
+	}
+	for t := 0.0; t <= maxThreads; t++ {
+		fmt.Fprintf(&svg, "<text x=\"%.1f\" y=\"%v\" text-anchor=\"middle\">%v</text>\n",
+			x(t), height-margin+18, t)
+	}
+	fmt.Fprintf(&svg, "<text x=\"%v\" y=\"%v\" text-anchor=\"middle\">Number of Threads (N)</text>\n",
+		width/2, height-15)
+	fmt.Fprintf(&svg, "<text x=\"15\" y=\"%v\" text-anchor=\"middle\" transform=\"rotate(-90 15 %v)\">"+
+		"Speedup</text>\n", height/2, height/2)
+
+	// A line and legend entry for each input
+	for i, input := range inputs {
+		colour := colours[i%len(colours)]
+		points := make([]string, 0)
+		shapes := make([]string, 0)
+		for _, r := range results {
+			if r.Input == input {
+				cx, cy := x(float64(r.Threads)), y(r.Speedup)
+				points = append(points, fmt.Sprintf("%.1f,%.1f", cx, cy))
+				shapes = append(shapes, marker(markers[i%len(markers)], cx, cy, colour))
+			}
+		}
+
+		fmt.Fprintf(&svg, "<polyline points=\"%v\" fill=\"none\" stroke=\"%v\" stroke-width=\"2\"/>\n",
+			strings.Join(points, " "), colour)
+		svg.WriteString(strings.Join(shapes, ""))
+
+		fmt.Fprintf(&svg, "<line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"%v\" stroke-width=\"2\"/>\n",
+			width-margin-110, margin+20*float64(i), width-margin-90, margin+20*float64(i), colour)
+		fmt.Fprintf(&svg, "<text x=\"%v\" y=\"%v\">%v</text>\n",
+			width-margin-85, margin+20*float64(i)+4, filepath.Base(input))
+	}
+
+	svg.WriteString("</svg>\n")
+	_, err := io.WriteString(w, svg.String())
+	return err
+}
+
+/*
+ * Return an SVG marker shape centred on cx, cy
+ */
+func marker(shape string, cx, cy float64, colour string) string {
+
+	switch shape {
+	case "diamond":
+		return fmt.Sprintf("<polygon points=\"%.1f,%.1f %.1f,%.1f %.1f,%.1f %.1f,%.1f\" fill=\"%v\"/>\n",
+			cx, cy-5, cx+5, cy, cx, cy+5, cx-5, cy, colour)
+	case "triangle":
+		return fmt.Sprintf("<polygon points=\"%.1f,%.1f %.1f,%.1f %.1f,%.1f\" fill=\"%v\"/>\n",
+			cx, cy-5, cx+5, cy+4, cx-5, cy+4, colour)
+	default:
+		return fmt.Sprintf("<rect x=\"%.1f\" y=\"%.1f\" width=\"8\" height=\"8\" fill=\"%v\"/>\n",
+			cx-4, cy-4, colour)
+	}
+}
+
+/*
+ * Create a file and pass it to write, or pass Stdout if the path is empty
+ */
+func writeFile(path string, write func(io.Writer) error) error {
+
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+/*
+ * Run the benchmark harness from the command line
+ *
+ * args: command line arguments after "bench"
+ */
+func benchMode(args []string) {
+
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	inputsPtr := flags.String("inputs", "object_data/small.txt,object_data/medium.txt,object_data/large.txt",
+		"Comma separated input files.")
+	threadsPtr := flags.String("threads", "0,1,2,4,6,8,10,12", "Comma separated thread counts.")
+	stepsPtr := flags.Int("steps", 100, "Number of time-steps in each run.")
+	repeatsPtr := flags.Int("repeats", 5, "Number of runs of each input and thread count.")
+	jsonPtr := flags.String("json", "", "File to write the JSON report into.")
+	csvPtr := flags.String("csv", "", "File to write the CSV report into.")
+	svgPtr := flags.String("svg", "", "File to draw the speedup chart into.")
+	flags.Usage = func() { fmt.Println(benchUsage) }
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 || *stepsPtr <= 0 || *repeatsPtr <= 0 {
+		fmt.Println(benchUsage)
+		os.Exit(0)
+	}
+
+	WindowWidth, _ = strconv.Atoi(flags.Arg(0))
+	WindowHeight, _ = strconv.Atoi(flags.Arg(1))
+	if WindowWidth <= 0 || WindowHeight <= 0 {
+		fmt.Println(benchUsage)
+		os.Exit(0)
+	}
+
+	threads := make([]int, 0)
+	for _, t := range strings.Split(*threadsPtr, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(t))
+		if err != nil || n < 0 {
+			fmt.Printf("thread counts must be integers greater than -1. Not [%v]\n", t)
+			os.Exit(0)
+		}
+		threads = append(threads, n)
+	}
+
+	// Run every thread count for each input
+	results := make([]benchResult, 0)
+	for _, name := range strings.Split(*inputsPtr, ",") {
+		input, err := ioutil.ReadFile(strings.TrimSpace(name))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, n := range threads {
+			fmt.Fprintf(os.Stderr, "Running %v with %v Threads\n", name, n)
+			results = append(results, benchRun(name, input, n, *stepsPtr, *repeatsPtr))
+		}
+	}
+	setSpeedup(results)
+
+	err := writeFile(*jsonPtr, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	})
+	if err == nil && *csvPtr != "" {
+		err = writeFile(*csvPtr, func(w io.Writer) error { return writeBenchCSV(w, results) })
+	}
+	if err == nil && *svgPtr != "" {
+		err = writeFile(*svgPtr, func(w io.Writer) error { return writeBenchSVG(w, results) })
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
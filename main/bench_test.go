@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+/*
+ * Benchmark runs of each input through the console drivers with the same thread counts as the timing scripts
+ * Each phase is reported as its own metric
+ */
+func BenchmarkRun(b *testing.B) {
+
+	const steps = 10
+	for _, name := range []string{"small", "medium", "large"} {
+		input, err := ioutil.ReadFile(filepath.Join("..", "object_data", name+".txt"))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for _, threads := range []int{0, 1, 2, 4, 8} {
+			b.Run(fmt.Sprintf("%v/threads=%v", name, threads), func(b *testing.B) {
+				setup(1000, 1000, threads)
+
+				var sum phaseTimes
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					_, times := timedRun(input, threads, steps)
+					sum.Build += times.Build
+					sum.Force += times.Force
+					sum.Integrate += times.Integrate
+					sum.Output += times.Output
+				}
+
+				b.ReportMetric(float64(sum.Build.Nanoseconds())/float64(b.N), "build-ns/op")
+				b.ReportMetric(float64(sum.Force.Nanoseconds())/float64(b.N), "force-ns/op")
+				b.ReportMetric(float64(sum.Integrate.Nanoseconds())/float64(b.N), "integrate-ns/op")
+				b.ReportMetric(float64(sum.Output.Nanoseconds())/float64(b.N), "output-ns/op")
+			})
+		}
+	}
+}
+
+func TestBenchStatistics(t *testing.T) {
+
+	stat := newBenchStat([]float64{1, 2, 3, 4})
+	if stat.Mean != 2.5 || stat.StdDev < 1.29 || stat.StdDev > 1.30 {
+		t.Errorf("newBenchStat = %+v, want mean 2.5 and stddev 1.29", stat)
+	}
+
+	results := []benchResult{
+		{Input: "a", Threads: 0, Total: benchStat{Mean: 4}},
+		{Input: "a", Threads: 2, Total: benchStat{Mean: 2}},
+		{Input: "b", Threads: 0, Total: benchStat{Mean: 9}},
+		{Input: "b", Threads: 2, Total: benchStat{Mean: 3}},
+	}
+	setSpeedup(results)
+	for i, want := range []float64{1, 2, 1, 3} {
+		if results[i].Speedup != want {
+			t.Errorf("result %v speedup = %v, want %v", i, results[i].Speedup, want)
+		}
+	}
+}
+
+func TestBenchReports(t *testing.T) {
+
+	setup(500, 500, 0)
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []benchResult{benchRun("plummer", input, 0, 2, 2), benchRun("plummer", input, 2, 2, 2)}
+	setSpeedup(results)
+	if results[0].Bodies != 64 || results[0].Total.Mean <= 0 || results[0].Force.Mean <= 0 ||
+		results[0].Total.Mean < results[0].Force.Mean {
+		t.Errorf("unexpected result %+v", results[0])
+	}
+
+	// One CSV header and a row for each result
+	var out bytes.Buffer
+	if err := writeBenchCSV(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(rows) != 3 || rows[2][2] != "2" {
+		t.Errorf("CSV rows = %v, %v", rows, err)
+	}
+
+	// The chart is well formed XML
+	out.Reset()
+	if err := writeBenchSVG(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	dec := xml.NewDecoder(&out)
+	for {
+		if _, err := dec.Token(); err != nil {
+			if err.Error() != "EOF" {
+				t.Errorf("invalid SVG: %v", err)
+			}
+			break
+		}
+	}
+}
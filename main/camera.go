@@ -0,0 +1,234 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"proj3/phys"
+)
+
+// Camera modes
+const (
+	FreeCamera   = iota // Only moved by panning and zooming
+	FitCamera           // Keeps every body in the window
+	CentreCamera        // Follows the centre of mass
+	BodyCamera          // Follows the selected body
+)
+
+// Names of the camera modes for the HUD
+var cameraModes = []string{"Free", "Fit all", "Centre of mass", "Selected body"}
+
+// Limits on the zoom
+const minZoom = 0.01
+const maxZoom = 100
+
+// Fraction of the window the bodies fill when fitted
+const fitMargin = 0.9
+
+// Camera maps positions in the simulation onto the window
+type camera struct {
+	target   rl.Vector2 // Simulation position at the centre of the window
+	zoom     float32    // Window pixels per simulation unit
+	mode     int        // Camera mode
+	selected int        // Id of the body selected in the editor. -1 if none is selected
+	dragging bool       // Whether the view is being dragged
+	drag     rl.Vector2 // Mouse position the drag was last at
+}
+
+// Camera of the GUI. nil outside GUI mode
+var View *camera
+
+/*
+ * Return a camera showing the whole window
+ */
+func newCamera() *camera {
+	c := &camera{selected: -1}
+	c.reset()
+	return c
+}
+
+/*
+ * Show the whole window at its own scale
+ */
+func (c *camera) reset() {
+	c.target = rl.NewVector2(float32(WindowWidth)/2, float32(WindowHeight)/2)
+	c.zoom = 1
+	c.mode = FreeCamera
+}
+
+/*
+ * Return the window position of a simulation position
+ */
+func (c *camera) toScreen(pos rl.Vector2) rl.Vector2 {
+	return rl.NewVector2((pos.X-c.target.X)*c.zoom+float32(WindowWidth)/2,
+		(pos.Y-c.target.Y)*c.zoom+float32(WindowHeight)/2)
+}
+
+/*
+ * Return the simulation position of a window position
+ */
+func (c *camera) toWorld(pos rl.Vector2) rl.Vector2 {
+	return rl.NewVector2((pos.X-float32(WindowWidth)/2)/c.zoom+c.target.X,
+		(pos.Y-float32(WindowHeight)/2)/c.zoom+c.target.Y)
+}
+
+/*
+ * Zoom by a factor, keeping the simulation position under a window position fixed
+ *
+ * factor: amount to multiply the zoom by
+ * anchor: window position to zoom about
+ */
+func (c *camera) zoomAt(factor float32, anchor rl.Vector2) {
+
+	before := c.toWorld(anchor)
+	c.zoom = float32(math.Min(math.Max(float64(c.zoom*factor), minZoom), maxZoom))
+	after := c.toWorld(anchor)
+
+	c.target.X += before.X - after.X
+	c.target.Y += before.Y - after.Y
+}
+
+/*
+ * Move the view by a distance in window pixels
+ */
+func (c *camera) pan(dx, dy float32) {
+	c.target.X -= dx / c.zoom
+	c.target.Y -= dy / c.zoom
+}
+
+/*
+ * Centre the view on the bodies and zoom so every one of them is in the window
+ */
+func (c *camera) fit(bodies []phys.Body) {
+
+	if len(bodies) == 0 {
+		return
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := 0; i < len(bodies); i++ {
+		x, y, r := float64(bodies[i].Position.X), float64(bodies[i].Position.Y), float64(bodies[i].Radius)
+		minX, maxX = math.Min(minX, x-r), math.Max(maxX, x+r)
+		minY, maxY = math.Min(minY, y-r), math.Max(maxY, y+r)
+	}
+
+	c.target = rl.NewVector2(float32(minX+maxX)/2, float32(minY+maxY)/2)
+	zoom := fitMargin * math.Min(float64(WindowWidth)/(maxX-minX), float64(WindowHeight)/(maxY-minY))
+	c.zoom = float32(math.Min(math.Max(zoom, minZoom), maxZoom))
+}
+
+/*
+ * Return the Id of the body under a window position, or -1 if there is none
+ * The closest body whose circle, or a few pixels around it, covers the position is picked
+ */
+func (c *camera) bodyAt(bodies []phys.Body, pos rl.Vector2) int {
+
+	id := -1
+	best := math.Inf(1)
+	for i := 0; i < len(bodies); i++ {
+		p := c.toScreen(bodies[i].Position)
+		d := math.Hypot(float64(p.X-pos.X), float64(p.Y-pos.Y))
+		if d <= float64(bodies[i].Radius*c.zoom)+5 && d < best {
+			id, best = bodies[i].Id, d
+		}
+	}
+
+	return id
+}
+
+/*
+ * Move the camera to follow the bodies in its mode
+ */
+func (c *camera) follow(bodies []phys.Body) {
+
+	switch c.mode {
+	case FitCamera:
+		c.fit(bodies)
+	case CentreCamera:
+		if len(bodies) > 0 {
+			com := bodies[0]
+			for i := 1; i < len(bodies); i++ {
+				com = phys.AddBody(com, bodies[i])
+			}
+			c.target = com.Position
+		}
+	case BodyCamera:
+		for i := 0; i < len(bodies); i++ {
+			if bodies[i].Id == c.selected {
+				c.target = bodies[i].Position
+				return
+			}
+		}
+		// The selected body was absorbed
+		c.selected = -1
+		c.mode = FreeCamera
+	}
+}
+
+/*
+ * Handle the camera controls for one frame, then follow the bodies
+ * Wheel zooms about the mouse, middle drag and the arrow keys pan,
+ * F fits every body, C follows the centre of mass, T follows the selected body and R resets the view
+ */
+func (c *camera) update(bodies []phys.Body) {
+
+	mouse := rl.GetMousePosition()
+
+	// Shift and the wheel is left for the editor
+	if wheel := rl.GetMouseWheelMove(); wheel != 0 && !shiftDown() {
+		c.zoomAt(float32(math.Pow(1.1, float64(wheel))), mouse)
+		if c.mode == FitCamera {
+			c.mode = FreeCamera
+		}
+	}
+
+	// Panning leaves any mode that moves the view
+	if rl.IsMouseButtonPressed(rl.MouseMiddleButton) {
+		c.dragging, c.drag = true, mouse
+	}
+	if rl.IsMouseButtonReleased(rl.MouseMiddleButton) {
+		c.dragging = false
+	}
+	if c.dragging && mouse != c.drag {
+		c.pan(mouse.X-c.drag.X, mouse.Y-c.drag.Y)
+		c.drag = mouse
+		c.mode = FreeCamera
+	}
+
+	const keyPan = 10
+	for key, d := range map[int32][2]float32{
+		rl.KeyLeft: {keyPan, 0}, rl.KeyRight: {-keyPan, 0}, rl.KeyUp: {0, keyPan}, rl.KeyDown: {0, -keyPan}} {
+		if rl.IsKeyDown(key) {
+			c.pan(d[0], d[1])
+			c.mode = FreeCamera
+		}
+	}
+
+	switch {
+	case rl.IsKeyPressed(rl.KeyF):
+		c.mode = FitCamera
+	case rl.IsKeyPressed(rl.KeyC):
+		c.mode = CentreCamera
+	case rl.IsKeyPressed(rl.KeyT) && c.selected >= 0:
+		c.mode = BodyCamera
+	case rl.IsKeyPressed(rl.KeyR):
+		c.reset()
+	}
+
+	c.follow(bodies)
+}
+
+/*
+ * Draw a body as a circle outline
+ */
+func (c *camera) drawBody(body *phys.Body, colour rl.Color) {
+	p := c.toScreen(body.Position)
+	rl.DrawCircleLines(int32(p.X), int32(p.Y), body.Radius*c.zoom, colour)
+}
+
+/*
+ * Draw a body as a filled circle, at least a pixel across
+ */
+func (c *camera) fillBody(body *phys.Body, colour rl.Color) {
+	rl.DrawCircleV(c.toScreen(body.Position), float32(math.Max(float64(body.Radius*c.zoom), 1)), colour)
+}
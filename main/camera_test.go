@@ -0,0 +1,58 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"proj3/phys"
+	"testing"
+)
+
+/*
+ * Zooming keeps the position under the mouse fixed and the transforms invert each other
+ */
+func TestCameraZoomAt(t *testing.T) {
+
+	setup(800, 600, 1)
+	c := newCamera()
+	anchor := rl.NewVector2(100, 500)
+	before := c.toWorld(anchor)
+
+	c.zoomAt(2.5, anchor)
+	c.pan(30, -20)
+	c.pan(-30, 20)
+
+	if after := c.toWorld(anchor); math.Abs(float64(after.X-before.X)) > 1e-3 || math.Abs(float64(after.Y-before.Y)) > 1e-3 {
+		t.Errorf("position under the mouse moved from %v to %v", before, after)
+	}
+	if p := c.toScreen(c.toWorld(anchor)); math.Abs(float64(p.X-anchor.X)) > 1e-3 || math.Abs(float64(p.Y-anchor.Y)) > 1e-3 {
+		t.Errorf("toScreen(toWorld(%v)) = %v", anchor, p)
+	}
+}
+
+/*
+ * Fitting puts every body in the window and the selection finds the body under the mouse
+ */
+func TestCameraFit(t *testing.T) {
+
+	setup(800, 600, 1)
+	bodies := []phys.Body{
+		phys.NewBody(1, 4, rl.NewVector2(-5000, 200), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 7, rl.NewVector2(3000, -100), rl.NewVector2(0, 0)),
+	}
+
+	c := newCamera()
+	c.fit(bodies)
+	for i := range bodies {
+		p := c.toScreen(bodies[i].Position)
+		if p.X < 0 || p.X > 800 || p.Y < 0 || p.Y > 600 {
+			t.Errorf("body %v is drawn outside the window at %v", bodies[i].Id, p)
+		}
+	}
+
+	if id := c.bodyAt(bodies, c.toScreen(bodies[1].Position)); id != 7 {
+		t.Errorf("bodyAt picked %v, want 7", id)
+	}
+	if id := c.bodyAt(bodies, rl.NewVector2(400, 0)); id != -1 {
+		t.Errorf("bodyAt picked %v in empty space", id)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"proj3/phys"
+)
+
+// Limits on the number of steps run each frame
+const maxSpeed = 64
+
+// Limits on the size of the timestep, and the factor it changes by for each key press
+const minDt = 0.01
+const maxDt = 4
+const dtStep = 1.25
+
+// Clock controls how the GUI steps the simulation
+type clock struct {
+	paused bool    // Whether the simulation is paused
+	speed  int     // Number of steps to run each frame
+	time   float64 // Simulated time since the start
+}
+
+/*
+ * Return a running clock stepping once each frame
+ */
+func newClock() *clock {
+	return &clock{speed: 1}
+}
+
+/*
+ * Handle the time controls for one frame
+ * P pauses and resumes, N runs a single step while paused, [ and ] halve and double the steps each frame,
+ * , and . shrink and grow the timestep and Z reverses the direction of time
+ *
+ * bodies: slice of physics bodies, kicked when time is reversed
+ *
+ * return: the number of steps to run this frame
+ */
+func (c *clock) update(bodies []phys.Body) int {
+
+	if rl.IsKeyPressed(rl.KeyP) {
+		c.paused = !c.paused
+	}
+	if rl.IsKeyPressed(rl.KeyLeftBracket) && c.speed > 1 {
+		c.speed /= 2
+	}
+	if rl.IsKeyPressed(rl.KeyRightBracket) && c.speed < maxSpeed {
+		c.speed *= 2
+	}
+	if rl.IsKeyPressed(rl.KeyComma) {
+		setDtSize(math.Abs(float64(Dt)) / dtStep)
+	}
+	if rl.IsKeyPressed(rl.KeyPeriod) {
+		setDtSize(math.Abs(float64(Dt)) * dtStep)
+	}
+	if rl.IsKeyPressed(rl.KeyZ) {
+		reverseTime(bodies)
+	}
+
+	steps := c.speed
+	if c.paused {
+		steps = 0
+		if rl.IsKeyPressed(rl.KeyN) {
+			steps = 1
+		}
+	}
+
+	c.time += float64(steps) * float64(Dt)
+	return steps
+}
+
+/*
+ * Return the state of the clock for the HUD
+ */
+func (c *clock) String() string {
+
+	state := "Running"
+	if c.paused {
+		state = "Paused"
+	}
+	if Dt < 0 {
+		state += " backwards"
+	}
+
+	return fmt.Sprintf("%v x%v, dt %.3f, t = %.1f (P pause, N step, [ ] speed, , . dt, Z reverse)",
+		state, c.speed, math.Abs(float64(Dt)), c.time)
+}
+
+/*
+ * Set the size of the timestep within its limits, keeping its direction
+ */
+func setDtSize(size float64) {
+
+	size = math.Min(math.Max(size, minDt), maxDt)
+	if Dt < 0 {
+		size = -size
+	}
+
+	Dt = float32(size)
+}
+
+/*
+ * Reverse the direction of time
+ * Every body is kicked with the force at its current position first,
+ * so the following steps retrace the earlier ones
+ *
+ * bodies: slice of physics bodies
+ */
+func reverseTime(bodies []phys.Body) {
+
+	solver := buildSolver(bodies)
+	for i := 0; i < len(bodies); i++ {
+		solver.CalculateForces(&bodies[i])
+		bodies[i].Kick(Dt)
+		bodies[i].ZeroForce()
+	}
+
+	Dt = -Dt
+}
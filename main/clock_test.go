@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"proj3/engine"
+	"proj3/phys"
+	"testing"
+)
+
+/*
+ * Reversing time after some steps brings the Plummer sphere back to its starting positions
+ */
+func TestReverseTime(t *testing.T) {
+
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup(500, 500, 0)
+
+	bodies := make([]phys.Body, 0)
+	readData(&bodies, nil, nil, json.NewDecoder(bytes.NewReader(input)), nil, nil)
+	engine.SortById(bodies)
+	start := make([]phys.Body, len(bodies))
+	copy(start, bodies)
+
+	const steps = 50
+	for step := 0; step < steps; step++ {
+		bodies = seqProcess(bodies, nil, false)
+	}
+	reverseTime(bodies)
+	for step := 0; step < steps; step++ {
+		bodies = seqProcess(bodies, nil, false)
+	}
+
+	if Dt != -phys.DefaultDt {
+		t.Errorf("Dt = %v, want %v", Dt, -phys.DefaultDt)
+	}
+	for i := range bodies {
+		dx := float64(bodies[i].Position.X - start[i].Position.X)
+		dy := float64(bodies[i].Position.Y - start[i].Position.Y)
+		if math.Hypot(dx, dy) > 0.05 {
+			t.Errorf("body %v is %v from its starting position", bodies[i].Id, math.Hypot(dx, dy))
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"proj3/engine"
+	"proj3/phys"
+	"proj3/snapshot"
+	"proj3/traj"
+	"sort"
+	"strconv"
+)
+
+// Formats of the output of a console run
+const (
+	JSONFormat   = "json"   // Positions of each body, encoded at the end of the run
+	BinaryFormat = "binary" // Positions of each time-step, as read by traj.Reader
+	CSVFormat    = "csv"    // Row of the state of each body at each time-step
+	NPYFormat    = "npy"    // NumPy array shaped (steps, bodies, fields)
+	NPZFormat    = "npz"    // NumPy archive of the npy array with the ids, times and field names
+)
+
+const convertUsage = "Usage: ./sim convert [-precision=32|64] [-dt=FLOAT] [-config=JSON] [-snapshot=tipsy|gadget]\n" +
+	"\t [-project=xy|xz|yz] <input> <output> [<X> <Y>]\n" +
+	"\t Converts a binary trajectory to the JSON output of a console run, or the JSON output to a binary trajectory.\n" +
+	"\t A TIPSY or Gadget snapshot is converted to an input file, and an input file to a snapshot with -snapshot.\n" +
+	"\t -precision = Bits per coordinate of a binary trajectory, 32 (default) or 64.\n" +
+	"\t -dt = Timestep of the run, recorded in a binary trajectory. Defaults to 0.4.\n" +
+	"\t -config = JSON object of the other settings of the run, recorded in a binary trajectory.\n" +
+	"\t -snapshot = Write an input file or snapshot as a tipsy or gadget snapshot.\n" +
+	"\t -project = Plane to project the 3D positions and velocities of a snapshot onto. Defaults to xy.\n" +
+	"\t <input> = Trajectory, input file or snapshot to convert. Binary trajectories and snapshots are recognised by their header.\n" +
+	"\t <output> = File to write the converted trajectory into.\n" +
+	"\t <X> <Y> = The size of the window of the run, recorded in a binary trajectory. Defaults to unknown."
+
+/*
+ * Return the settings of a console run as the config of a binary trajectory
+ */
+func runConfig() string {
+	config, _ := json.Marshal(map[string]interface{}{"Solver": SolverType, "Grid": GridSize,
+		"Boundary": BoundaryMode.String(), "Threads": ThreadCount, "Deterministic": Deterministic})
+	return string(config)
+}
+
+/*
+ * Return whether a format is one the output of a console run can be written in
+ */
+func validFormat(format string) bool {
+	switch format {
+	case JSONFormat, BinaryFormat, CSVFormat, NPYFormat, NPZFormat:
+		return true
+	}
+	return false
+}
+
+/*
+ * Start writing a console run a time-step at a time in the output format, with the fields of OutputFields
+ *
+ * out: Writer to write the trajectory into
+ * bodies: slice of physics bodies at the start of the run
+ * frames: number of frames of the run, counting the first
+ *
+ * return: the writer of the trajectory. nil for JSON, which is encoded at the end of the run
+ */
+func newTrajectoryWriter(out io.Writer, bodies []phys.Body, frames int) traj.Recorder {
+
+	ids := make([]int, len(bodies))
+	for i := range bodies {
+		ids[i] = bodies[i].Id
+	}
+	sort.Ints(ids)
+
+	header := traj.Header{Precision: Precision, Frames: frames, Dt: float64(Dt), Width: float64(WindowWidth),
+		Height: float64(WindowHeight), Config: runConfig(), Ids: ids, Fields: OutputFields}
+
+	var w traj.Recorder
+	var err error
+	switch OutputFormat {
+	case BinaryFormat:
+		w, err = traj.NewWriter(out, header)
+	case CSVFormat:
+		w, err = traj.NewCSVWriter(out, header)
+	case NPYFormat:
+		w, err = traj.NewNPYWriter(out, header)
+	case NPZFormat:
+		w, err = traj.NewNPZWriter(out, header)
+	default:
+		return nil
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	return w
+}
+
+/*
+ * Finish a trajectory, filling in the number of frames of a binary trajectory if the output is a file
+ */
+func closeTrajectory(w traj.Recorder) {
+	if err := w.Close(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+/*
+ * Convert between binary and JSON trajectories from the command line
+ *
+ * args: command line arguments after "convert"
+ */
+func convertMode(args []string) {
+
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	precisionPtr := flags.Int("precision", 32, "Bits per coordinate of a binary trajectory: 32 or 64.")
+	dtPtr := flags.Float64("dt", phys.DefaultDt, "Timestep of the run.")
+	configPtr := flags.String("config", "", "JSON object of the other settings of the run.")
+	snapshotPtr := flags.String("snapshot", "", "Snapshot format to write an input file as: tipsy or gadget.")
+	projectPtr := flags.String("project", snapshot.XY.String(), "Plane to project 3D snapshots onto: xy, xz or yz.")
+	flags.Usage = func() { fmt.Println(convertUsage) }
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 && flags.NArg() != 4 {
+		fmt.Println(convertUsage)
+		os.Exit(0)
+	}
+
+	var width, height int
+	if flags.NArg() == 4 {
+		width, _ = strconv.Atoi(flags.Arg(2))
+		height, _ = strconv.Atoi(flags.Arg(3))
+	}
+	if (*precisionPtr != 32 && *precisionPtr != 64) || *dtPtr == 0 || width < 0 || height < 0 ||
+		(*configPtr != "" && !json.Valid([]byte(*configPtr))) {
+		fmt.Printf("precision must be 32 or 64, dt must not be 0, X and Y must not be negative "+
+			"and config must be JSON. Not [%v, %v, %v, %v, %v]\n", *precisionPtr, *dtPtr, width, height, *configPtr)
+		fmt.Println(convertUsage)
+		os.Exit(0)
+	}
+
+	var format snapshot.Format
+	var err error
+	if Projection, err = snapshot.ParseProjection(*projectPtr); err == nil && *snapshotPtr != "" {
+		format, err = snapshot.ParseFormat(*snapshotPtr)
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(convertUsage)
+		os.Exit(0)
+	}
+
+	// With -snapshot the input is written as a snapshot. Otherwise a binary input is written as JSON,
+	// a snapshot as an input file, and anything else is read as JSON and written as binary
+	var write func(io.Writer) error
+	if *snapshotPtr != "" {
+		initial, err := readInitial(flags.Arg(0))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		bodies := make([]phys.Body, 0, len(initial))
+		for _, b := range initial {
+			bodies = append(bodies, b)
+		}
+		engine.SortById(bodies)
+		write = func(w io.Writer) error { return snapshot.Write(w, format, snapshot.Snapshot{Bodies: bodies}) }
+	} else if s, err := readSnapshot(flags.Arg(0)); err != snapshot.ErrUnknown {
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		renumber(s.Bodies)
+		write = func(w io.Writer) error { return writeInput(w, s.Bodies, nil) }
+	} else if r, err := traj.Open(flags.Arg(0)); err == nil {
+		defer r.Close()
+		trajectory, err := r.Trajectory()
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		write = trajectory.WriteJSON
+	} else if err != traj.ErrNotBinary {
+		fmt.Println(err)
+		os.Exit(1)
+	} else {
+		trajectory, err := readTrajectory(flags.Arg(0))
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		header := traj.Header{Precision: *precisionPtr / 8, Dt: *dtPtr, Width: float64(width),
+			Height: float64(height), Config: *configPtr}
+		write = func(w io.Writer) error { return trajectory.WriteBinary(w, header) }
+	}
+
+	if err := writeFile(flags.Arg(1), write); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
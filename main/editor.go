@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/phys"
+)
+
+// Velocity given to a body for each simulation unit it is dragged
+const dragScale = 0.02
+
+// Factor the mass changes by for each key press or wheel step
+const massStep = 1.25
+
+// Editor places, moves, deletes and edits bodies with the mouse in GUI mode
+type editor struct {
+	mass     float32    // Mass of new bodies
+	spawning bool       // Whether a new body is being dragged out
+	moving   bool       // Whether the selected body is being dragged
+	aiming   bool       // Whether the velocity of the selected body is being dragged out
+	start    rl.Vector2 // Simulation position the drag started at
+}
+
+/*
+ * Return an editor placing bodies of unit mass
+ */
+func newEditor() *editor {
+	return &editor{mass: 1}
+}
+
+/*
+ * Return whether either shift key is held
+ */
+func shiftDown() bool {
+	return rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift)
+}
+
+/*
+ * Return the index of the body with the Id, or -1 if there is none
+ */
+func indexOf(bodies []phys.Body, id int) int {
+	for i := 0; i < len(bodies); i++ {
+		if bodies[i].Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+/*
+ * Return an Id not used by any body
+ */
+func nextId(bodies []phys.Body) int {
+	id := 0
+	for i := 0; i < len(bodies); i++ {
+		if bodies[i].Id >= id {
+			id = bodies[i].Id + 1
+		}
+	}
+	return id
+}
+
+/*
+ * Return the velocity given by dragging from one simulation position to another
+ */
+func dragVelocity(from, to rl.Vector2) rl.Vector2 {
+	return rl.NewVector2((to.X-from.X)*dragScale, (to.Y-from.Y)*dragScale)
+}
+
+/*
+ * Return a copy of the body with a new mass, keeping the radius in step with it
+ */
+func withMass(body phys.Body, mass float32) phys.Body {
+	return phys.NewBody(mass, body.Id, body.Position, body.Velocity)
+}
+
+/*
+ * Handle the editing controls for one frame
+ * Left click selects a body and drags it, or drags out a new body and its velocity from empty space.
+ * Shift and left drag from the selected body sets its velocity. Right click or Delete removes a body.
+ * + and - (or shift and the wheel) change the mass of the selected body, or of new bodies if none is selected
+ *
+ * bodies: slice of physics bodies
+ *
+ * return: the edited bodies, and whether any body changed
+ */
+func (e *editor) update(bodies []phys.Body) ([]phys.Body, bool) {
+
+	mouse := rl.GetMousePosition()
+	world := View.toWorld(mouse)
+	selected := indexOf(bodies, View.selected)
+	edited := false
+
+	if rl.IsMouseButtonPressed(rl.MouseLeftButton) {
+		e.start = world
+		switch {
+		case shiftDown() && selected >= 0:
+			e.aiming = true
+		case View.bodyAt(bodies, mouse) >= 0:
+			View.selected = View.bodyAt(bodies, mouse)
+			selected = indexOf(bodies, View.selected)
+			e.moving = true
+		default:
+			View.selected, selected = -1, -1
+			e.spawning = true
+		}
+	}
+
+	if e.moving && selected >= 0 && world != e.start {
+		bodies[selected].Position.X += world.X - e.start.X
+		bodies[selected].Position.Y += world.Y - e.start.Y
+		e.start = world
+		edited = true
+	}
+
+	if rl.IsMouseButtonReleased(rl.MouseLeftButton) {
+		if e.aiming && selected >= 0 {
+			bodies[selected].Velocity = dragVelocity(e.start, world)
+			edited = true
+		}
+		if e.spawning {
+			body := phys.NewBody(e.mass, nextId(bodies), e.start, dragVelocity(e.start, world))
+			bodies = append(bodies, body)
+			View.selected = body.Id
+			edited = true
+		}
+		e.spawning, e.moving, e.aiming = false, false, false
+	}
+
+	// Delete the body under the mouse, or the selected one
+	remove := -1
+	if rl.IsMouseButtonPressed(rl.MouseRightButton) {
+		remove = indexOf(bodies, View.bodyAt(bodies, mouse))
+	} else if rl.IsKeyPressed(rl.KeyDelete) || rl.IsKeyPressed(rl.KeyBackspace) {
+		remove = selected
+	}
+	if remove >= 0 {
+		if bodies[remove].Id == View.selected {
+			View.selected, selected = -1, -1
+		}
+		bodies = append(bodies[:remove], bodies[remove+1:]...)
+		edited = true
+	}
+
+	// Change the mass
+	factor := float32(1)
+	if rl.IsKeyPressed(rl.KeyEqual) || rl.IsKeyPressed(rl.KeyKpAdd) {
+		factor = massStep
+	}
+	if rl.IsKeyPressed(rl.KeyMinus) || rl.IsKeyPressed(rl.KeyKpSubtract) {
+		factor = 1 / massStep
+	}
+	if wheel := rl.GetMouseWheelMove(); wheel != 0 && shiftDown() {
+		if wheel > 0 {
+			factor = massStep
+		} else {
+			factor = 1 / massStep
+		}
+	}
+	if factor != 1 {
+		selected = indexOf(bodies, View.selected)
+		if selected >= 0 {
+			bodies[selected] = withMass(bodies[selected], bodies[selected].Mass*factor)
+			edited = true
+		} else {
+			e.mass *= factor
+		}
+	}
+
+	return bodies, edited
+}
+
+/*
+ * Draw the body being placed, the velocity of the selected body and the editing panel
+ *
+ * bodies: slice of physics bodies
+ * y: window height to start the panel at
+ */
+func (e *editor) draw(bodies []phys.Body, y int32) {
+
+	mouse := View.toWorld(rl.GetMousePosition())
+
+	// Velocities are drawn as the drag that would give them
+	if e.spawning {
+		ghost := phys.NewBody(e.mass, -1, e.start, rl.NewVector2(0, 0))
+		View.drawBody(&ghost, rl.SkyBlue)
+		rl.DrawLineV(View.toScreen(e.start), View.toScreen(mouse), rl.SkyBlue)
+	}
+
+	if i := indexOf(bodies, View.selected); i >= 0 {
+		b := bodies[i]
+		end := rl.NewVector2(b.Position.X+b.Velocity.X/dragScale, b.Position.Y+b.Velocity.Y/dragScale)
+		if e.aiming {
+			end = mouse
+		}
+		rl.DrawLineV(View.toScreen(b.Position), View.toScreen(end), rl.Yellow)
+
+		rl.DrawText(fmt.Sprintf("Body %v: mass %.3f, position [%.1f, %.1f], velocity [%.3f, %.3f]",
+			b.Id, b.Mass, b.Position.X, b.Position.Y, b.Velocity.X, b.Velocity.Y), 20, y, 15, rl.Yellow)
+		rl.DrawText("Drag to move, shift+drag for velocity, +/- for mass, Delete to remove", 20, y+25, 15, rl.Yellow)
+	} else {
+		rl.DrawText(fmt.Sprintf("New body mass: %.3f (+/-). Click and drag to place", e.mass), 20, y, 15, rl.White)
+	}
+}
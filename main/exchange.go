@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"proj3/engine"
+	"proj3/phys"
+	"proj3/snapshot"
+)
+
+/*
+ * Return a reader of the JSON input of a run, converting a TIPSY or Gadget snapshot into it
+ * Snapshots are projected onto the plane of Projection, and their bodies numbered 0 to N-1 in order of Id,
+ * as the console output is indexed by Id
+ *
+ * in: Reader of an input file or snapshot
+ */
+func inputReader(in io.Reader) (*bufio.Reader, error) {
+
+	buf := bufio.NewReader(in)
+	s, _, err := snapshot.Read(buf, Projection)
+	if err == snapshot.ErrUnknown {
+		return buf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	renumber(s.Bodies)
+	var converted bytes.Buffer
+	if err := writeInput(&converted, s.Bodies, nil); err != nil {
+		return nil, err
+	}
+	return bufio.NewReader(&converted), nil
+}
+
+/*
+ * Read a TIPSY or Gadget snapshot file, projected onto the plane of Projection
+ *
+ * return: the snapshot, or snapshot.ErrUnknown if the file is neither
+ */
+func readSnapshot(path string) (snapshot.Snapshot, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return snapshot.Snapshot{}, err
+	}
+	defer f.Close()
+
+	s, _, err := snapshot.Read(f, Projection)
+	return s, err
+}
+
+/*
+ * Return a decoder of the JSON input of a run, exiting if a snapshot can not be read
+ */
+func inputDecoder(in io.Reader) *json.Decoder {
+
+	input, err := inputReader(in)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	return json.NewDecoder(input)
+}
+
+/*
+ * Number the bodies 0 to N-1 in order of Id
+ */
+func renumber(bodies []phys.Body) {
+	engine.SortById(bodies)
+	for i := range bodies {
+		bodies[i].Id = i
+	}
+}
+
+/*
+ * Write bodies as an input file, one JSON object each with their components
+ *
+ * tags: scenario component of each body by Id. nil for none
+ */
+func writeInput(out io.Writer, bodies []phys.Body, tags map[int]string) error {
+
+	enc := json.NewEncoder(out)
+	for i := range bodies {
+		b := &bodies[i]
+		objectData := map[string]interface{}{"Command": "ADD", "Id": b.Id, "Mass": b.Mass,
+			"Position": [2]float32{b.Position.X, b.Position.Y}, "Velocity": [2]float32{b.Velocity.X, b.Velocity.Y}}
+		if tag, ok := tags[b.Id]; ok {
+			objectData["Component"] = tag
+		}
+		if err := enc.Encode(objectData); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Write the bodies at the end of a console run to the checkpoint, as an input file or a snapshot
+ * A JSON checkpoint is the input of a run carrying on from it, so like a snapshot its bodies are numbered
+ * 0 to N-1 in order of Id, as the console output is indexed by Id and absorbed bodies leave gaps
+ *
+ * bodies: slice of physics bodies
+ * step: Integer - time-step the bodies are at
+ */
+func writeCheckpoint(bodies []phys.Body, step int) {
+
+	sorted := append([]phys.Body(nil), bodies...)
+	engine.SortById(sorted)
+
+	err := writeFile(CheckpointPath, func(w io.Writer) error {
+		if CheckpointFormat == JSONFormat {
+			tags := make(map[int]string)
+			for i := range sorted {
+				if tag, ok := Tags[sorted[i].Id]; ok {
+					tags[i] = tag
+				}
+				sorted[i].Id = i
+			}
+			return writeInput(w, sorted, tags)
+		}
+		format, _ := snapshot.ParseFormat(CheckpointFormat)
+		return snapshot.Write(w, format, snapshot.Snapshot{Time: float64(step) * float64(Dt), Bodies: sorted})
+	})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"proj3/phys"
+	"proj3/snapshot"
+	"strings"
+	"testing"
+)
+
+/*
+ * A run carried on from its checkpoint, in any format, takes the same steps as the run would have
+ */
+func TestCheckpoint(t *testing.T) {
+
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	setup(500, 500, 0)
+	whole := run(t, input, 15, 0)
+
+	for _, format := range []string{JSONFormat, snapshot.Tipsy.String(), snapshot.Gadget.String()} {
+		setup(500, 500, 0)
+		CheckpointPath, CheckpointFormat = filepath.Join(dir, format), format
+		run(t, input, 10, 0)
+
+		CheckpointPath = ""
+		checkpoint, err := ioutil.ReadFile(filepath.Join(dir, format))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rest := run(t, checkpoint, 5, 0)
+
+		if len(rest) != len(whole) {
+			t.Fatalf("%v: %v bodies carried on, want %v", format, len(rest), len(whole))
+		}
+		for i := range rest {
+			for step, p := range rest[i].Position {
+				want := whole[i].Position[10+step]
+				if p[0] != want[0] || p[1] != want[1] {
+					t.Fatalf("%v: body %v at step %v = %v, want %v", format, i, 10+step, p, want)
+				}
+			}
+		}
+	}
+}
+
+/*
+ * A run carries on from a JSON checkpoint written after the boundary absorbed a body, with any driver,
+ * from the bodies numbered 0 to N-1 with their components
+ */
+func TestCheckpointAfterAbsorption(t *testing.T) {
+
+	input := []byte("{\"Command\":\"ADD\",\"Id\":0,\"Mass\":1,\"Position\":[95,50],\"Velocity\":[20,0]}\n" +
+		"{\"Command\":\"ADD\",\"Id\":1,\"Mass\":1,\"Position\":[40,50],\"Velocity\":[0,0]}\n" +
+		"{\"Command\":\"ADD\",\"Id\":2,\"Mass\":1,\"Position\":[60,50],\"Velocity\":[0,0],\"Component\":\"disk\"}\n")
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint.json")
+
+	setup(100, 100, 0)
+	BoundaryMode, CheckpointPath = phys.Absorbing, path
+	first := run(t, input, 10, 0)
+	CheckpointPath = ""
+
+	checkpoint, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(checkpoint), "\"Component\":\"disk\"") {
+		t.Errorf("checkpoint lost the component of body 2:\n%s", checkpoint)
+	}
+
+	for _, threads := range []int{0, 2} {
+		setup(100, 100, threads)
+		BoundaryMode = phys.Absorbing
+		rest := run(t, checkpoint, 5, threads)
+
+		if len(rest) != 2 || rest[0].Id != 0 || rest[1].Id != 1 {
+			t.Fatalf("threads = %v: carried on with %+v, want bodies 0 and 1", threads, rest)
+		}
+		for i := range rest {
+			p, want := rest[i].Position[0], first[i+1].Position[10]
+			if p[0] != want[0] || p[1] != want[1] {
+				t.Errorf("threads = %v: body %v starts at %v, want %v", threads, i, p, want)
+			}
+		}
+	}
+}
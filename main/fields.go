@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"os"
+	"proj3/phys"
+	"proj3/pm"
+	"proj3/qtree"
+	"proj3/traj"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fields of a body that come from the forces of the time-step that moved it
+type forceFields struct {
+	Acceleration rl.Vector2 // Acceleration the body was moved with
+	Interactions int        // Number of tree nodes the acceleration was calculated from
+}
+
+// Force fields of each body, indexed by Id. nil when OutputFields holds none of them
+var stepFields []forceFields
+
+/*
+ * Record the fields of OutputFields for the bodies of a time-step
+ * A trajectory is written a frame, while the JSON output gets every field but the position, which is
+ * added as each body moves
+ *
+ * w: writer of the trajectory. nil for JSON
+ * data: JSON output of the bodies, indexed by Id
+ * bodies: slice of physics bodies
+ */
+func recordFrame(w traj.Recorder, data []map[string]interface{}, bodies []phys.Body) {
+
+	if w == nil && len(OutputFields) < 2 {
+		return
+	}
+
+	start := time.Now()
+	states := measure(bodies)
+	var err error
+	if w != nil {
+		err = w.WriteFrame(states)
+	} else {
+		addFields(data, states)
+	}
+	if Profile != nil {
+		Profile.add(&Profile.output, start)
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+/*
+ * Start keeping the fields of OutputFields that come from the forces of each time-step
+ * Until a body is first moved they are 0
+ *
+ * bodies: slice of physics bodies, with Ids from 0 to one less than their number
+ */
+func startFields(bodies []phys.Body) {
+
+	stepFields = nil
+	if wantsField(traj.Acceleration) || wantsField(traj.Interactions) {
+		stepFields = make([]forceFields, len(bodies))
+	}
+}
+
+/*
+ * Whether OutputFields holds a field
+ */
+func wantsField(field traj.Field) bool {
+
+	for _, f := range OutputFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Keep the fields of OutputFields that come from the forces on a body, before they are cleared
+ *
+ * solver: solver the forces were calculated with
+ * body: body whose forces were just calculated
+ */
+func keepForceFields(solver Solver, body *phys.Body) {
+
+	f := &stepFields[body.Id]
+	f.Acceleration = body.Force
+	if wantsField(traj.Interactions) {
+		f.Interactions = interactions(solver, body)
+	}
+}
+
+/*
+ * Return the states of the bodies, with the fields of OutputFields that come from the forces
+ * The acceleration and interactions are those of the time-step that moved each body, while the potential
+ * is found with a solver of its own, so asking for it about doubles the time of a step
+ *
+ * bodies: slice of physics bodies
+ */
+func measure(bodies []phys.Body) []traj.State {
+
+	states := traj.States(bodies)
+	if stepFields != nil {
+		for i := range states {
+			f := stepFields[states[i].Id]
+			states[i].Acceleration, states[i].Interactions = f.Acceleration, f.Interactions
+		}
+	}
+	if !wantsField(traj.Potential) || len(states) == 0 {
+		return states
+	}
+
+	solver := newSolver(toChannel(bodies), nil)
+
+	threads := ThreadCount
+	if threads < 1 {
+		threads = 1
+	}
+	sublength := (len(states) + threads - 1) / threads
+
+	var wg sync.WaitGroup
+	for min := 0; min < len(states); min += sublength {
+		max := min + sublength
+		if max > len(states) {
+			max = len(states)
+		}
+		wg.Add(1)
+		go func(part []traj.State) {
+			defer wg.Done()
+			for i := range part {
+				part[i].Potential = potentialEnergy(solver, &part[i].Body)
+			}
+		}(states[min:max])
+	}
+	wg.Wait()
+
+	return states
+}
+
+/*
+ * Return the potential energy of a body with the bodies of a solver
+ */
+func potentialEnergy(solver Solver, body *phys.Body) float32 {
+
+	switch s := solver.(type) {
+	case *qtree.BHTree:
+		return s.PotentialEnergy(body)
+	case *pm.TreePM:
+		return s.PotentialEnergy(body)
+	case *pm.Mesh:
+		return s.PotentialEnergy(body)
+	}
+	return 0
+}
+
+/*
+ * Return the number of tree interactions of a body with a solver
+ * The particle-mesh solver has no tree, so has none
+ */
+func interactions(solver Solver, body *phys.Body) int {
+
+	switch s := solver.(type) {
+	case *qtree.BHTree:
+		return s.CountInteractions(body, 0)
+	case *pm.TreePM:
+		return s.CountInteractions(body)
+	}
+	return 0
+}
+
+/*
+ * Append the fields of OutputFields other than the position to the JSON output of each body
+ * Vectors are added as [x, y] and the rest as numbers, under the capitalised name of the field
+ *
+ * data: JSON output of the bodies, indexed by Id
+ * states: states of the bodies
+ */
+func addFields(data []map[string]interface{}, states []traj.State) {
+
+	for i := range states {
+		s := &states[i]
+		entry := data[s.Id]
+		for _, f := range OutputFields {
+			var value interface{}
+			switch f {
+			case traj.Position:
+				continue
+			case traj.Velocity:
+				value = []float32{s.Velocity.X, s.Velocity.Y}
+			case traj.Acceleration:
+				value = []float32{s.Acceleration.X, s.Acceleration.Y}
+			case traj.Mass:
+				value = s.Mass
+			case traj.Radius:
+				value = s.Radius
+			case traj.Potential:
+				value = s.Potential
+			case traj.Interactions:
+				value = s.Interactions
+			}
+
+			name := f.String()
+			key := strings.ToUpper(name[:1]) + name[1:]
+			values, _ := entry[key].([]interface{})
+			entry[key] = append(values, value)
+		}
+	}
+}
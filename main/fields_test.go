@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"proj3/phys"
+	"proj3/traj"
+	"testing"
+)
+
+// Output of one body with every field
+type fieldsOutput struct {
+	Id           int
+	Position     [][]float32
+	Velocity     [][]float32
+	Acceleration [][]float32
+	Mass         []float32
+	Radius       []float32
+	Potential    []float32
+	Interactions []int
+}
+
+/*
+ * Every field of two bodies is output in JSON with the value it has at each step, and a binary trajectory
+ * holds the same values. The forces are those of the time-step that moved the bodies
+ */
+func TestOutputFields(t *testing.T) {
+
+	const mass = 10
+	const separation = 100.0
+	input := fmt.Sprintf(
+		"{\"Command\":\"ADD\",\"Id\":0,\"Mass\":%v,\"Position\":[%v,250],\"Velocity\":[0,-1]}\n"+
+			"{\"Command\":\"ADD\",\"Id\":1,\"Mass\":%v,\"Position\":[%v,250],\"Velocity\":[0,1]}\n",
+		mass, 250-separation/2, mass, 250+separation/2)
+
+	fields, err := traj.ParseFields("velocity,acceleration,mass,radius,potential,interactions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const steps = 5
+	output := make(map[string][]byte)
+	for _, format := range []string{JSONFormat, BinaryFormat} {
+		setup(500, 500, 1)
+		OutputFormat, OutputFields = format, fields
+		var out bytes.Buffer
+		parallel(steps, bytes.NewReader([]byte(input)), &out)
+		output[format] = out.Bytes()
+	}
+
+	var result []fieldsOutput
+	if err := json.Unmarshal(output[JSONFormat], &result); err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Fatalf("%v bodies in the output, not 2", len(result))
+	}
+	for _, b := range result {
+		for _, n := range []int{len(b.Position), len(b.Velocity), len(b.Acceleration), len(b.Mass), len(b.Radius),
+			len(b.Potential), len(b.Interactions)} {
+			if n != steps+1 {
+				t.Fatalf("body %v has a field with %v values, not %v", b.Id, n, steps+1)
+			}
+		}
+	}
+
+	// The bodies start at rest along x, so the first time-step pulls each other along it
+	// Nothing has moved them when the first frame is written
+	want := phys.G * mass / (separation * separation)
+	if a := result[0].Acceleration[1]; math.Abs(float64(a[0])-want) > 0.01*want || a[1] != 0 {
+		t.Errorf("acceleration = %v, want [%v 0]", a, want)
+	}
+	if a := result[1].Acceleration[1]; math.Abs(float64(a[0])+want) > 0.01*want || a[1] != 0 {
+		t.Errorf("acceleration = %v, want [%v 0]", a, -want)
+	}
+	if a := result[0].Acceleration[0]; a[0] != 0 || a[1] != 0 || result[0].Interactions[0] != 0 {
+		t.Errorf("acceleration %v and interactions %v before the first time-step", a, result[0].Interactions[0])
+	}
+	potential := -phys.G * mass * mass / separation
+	if p := result[0].Potential[0]; math.Abs(float64(p)-potential) > 0.01*math.Abs(potential) {
+		t.Errorf("potential = %v, want %v", p, potential)
+	}
+	if result[0].Velocity[0][1] != -1 || result[0].Mass[0] != mass || result[0].Interactions[1] != 1 {
+		t.Errorf("velocity %v, mass %v and interactions %v of the first step", result[0].Velocity[0],
+			result[0].Mass[0], result[0].Interactions[1])
+	}
+
+	r, err := traj.NewReader(bytes.NewReader(output[BinaryFormat]), int64(len(output[BinaryFormat])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k <= steps; k++ {
+		values, err := r.Values(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, b := range result {
+			var want []float32
+			want = append(want, b.Position[k]...)
+			want = append(want, b.Velocity[k]...)
+			want = append(want, b.Acceleration[k]...)
+			want = append(want, b.Mass[k], b.Radius[k], b.Potential[k], float32(b.Interactions[k]))
+			got := values[i*len(want) : (i+1)*len(want)]
+			for c := range want {
+				if got[c] != float64(want[c]) {
+					t.Fatalf("frame %v body %v: binary %v, JSON %v", k, i, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"os"
+	"proj3/phys"
+	"proj3/pm"
+	"proj3/qtree"
+	"proj3/render"
+)
+
+// Frames draws images of a console run without a window
+type frames struct {
+	renderer *render.Renderer   // Draws the bodies onto an image
+	out      render.FrameWriter // Writes the images
+	every    int                // Number of time-steps between frames
+	cells    bool               // Whether the tree cells are drawn
+}
+
+// Frames of the console run. nil if no frames are drawn
+var Frames *frames
+
+/*
+ * Return frames of the whole window, written as numbered PNGs into a directory or as an animated GIF
+ *
+ * pngDir: directory to write PNG files into, or "" for a GIF
+ * gifPath: file to write the GIF into
+ * every: number of time-steps between frames
+ * cells: whether to draw the tree cells
+ */
+func newFrames(pngDir, gifPath string, every int, cells bool) (*frames, error) {
+
+	var out render.FrameWriter
+	if pngDir != "" {
+		w, err := render.NewPNGWriter(pngDir)
+		if err != nil {
+			return nil, err
+		}
+		out = w
+	} else {
+		w, err := render.NewGIFWriter(gifPath)
+		if err != nil {
+			return nil, err
+		}
+		out = w
+	}
+
+	renderer := &render.Renderer{Width: WindowWidth, Height: WindowHeight, Box: windowBox(),
+		Colours: ColourBy, Style: DrawStyle, Trails: render.NewTrails(TrailLength), Tags: Tags}
+
+	return &frames{renderer, out, every, cells}, nil
+}
+
+/*
+ * Add the positions of a time-step to the trails, and draw a frame if it is due
+ * The tree drawn is the one built from the positions at this time-step
+ *
+ * step: Integer - time-step of the bodies
+ * bodies: slice of physics bodies
+ */
+func (f *frames) record(step int, bodies []phys.Body) {
+
+	f.renderer.Trails.Record(bodies)
+	if step%f.every != 0 {
+		return
+	}
+
+	var cells []rl.Rectangle
+	if f.cells {
+		cells = solverCells(buildSolver(bodies))
+	}
+
+	if err := f.out.WriteFrame(f.renderer.Draw(bodies, cells)); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+/*
+ * Finish writing the frames
+ */
+func (f *frames) close() {
+	if err := f.out.Close(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+/*
+ * Return the cells of the tree in a solver. The particle-mesh solver has none
+ */
+func solverCells(solver Solver) []rl.Rectangle {
+
+	var tree *qtree.BHTree
+	switch s := solver.(type) {
+	case *qtree.BHTree:
+		tree = s
+	case *pm.TreePM:
+		tree = s.Tree
+	default:
+		return nil
+	}
+
+	cells := make([]rl.Rectangle, 0)
+	tree.Cells(func(cell rl.Rectangle) { cells = append(cells, cell) })
+	return cells
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"proj3/phys"
+	"proj3/pm"
+	"proj3/qtree"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync/atomic"
+	"time"
+)
+
+// Accumulates the time spent in each phase of a time-step, across every thread
+// The counters are in nanoseconds and are updated atomically
+type profiler struct {
+	build           int64 // Adding bodies to the tree or mesh
+	force           int64 // Calculating the forces on each body
+	update          int64 // Updating each body and applying the boundary
+	output          int64 // Recording and encoding the output
+	interactions    int64 // Total tree interactions of every body
+	maxInteractions int64 // Most tree interactions of a single body
+	treeDepth       int64 // Depth of the last tree built
+	treeNodes       int64 // Number of nodes in the last tree built
+	counting        bool  // Whether to count the tree interactions of every body, which walks the tree again
+	last            time.Time
+	enc             *json.Encoder
+}
+
+// Timing and tree statistics of one time-step
+// Phase times are summed over every thread, so in parallel mode they can be larger than the wall time
+type stepProfile struct {
+	Step            int
+	WallMs          float64 // Time since the previous time-step was recorded
+	BuildMs         float64
+	ForceMs         float64
+	UpdateMs        float64
+	OutputMs        float64
+	TreeDepth       int
+	TreeNodes       int
+	Interactions    float64 // Mean tree interactions per body
+	MaxInteractions int
+}
+
+// Profiler for the current run. nil if profiling is disabled
+var Profile *profiler
+
+/*
+ * Return a new profiler writing a JSON line for each time-step into enc
+ *
+ * counting: Bool - count the tree interactions of every body
+ */
+func newProfiler(enc *json.Encoder, counting bool) *profiler {
+	return &profiler{counting: counting, last: time.Now(), enc: enc}
+}
+
+/*
+ * Add the time since start to a phase counter
+ */
+func (p *profiler) add(counter *int64, start time.Time) {
+	atomic.AddInt64(counter, int64(time.Since(start)))
+}
+
+/*
+ * Record the number of tree interactions of a body with the solver
+ * The particle-mesh solver has no tree, so records none
+ */
+func (p *profiler) countInteractions(solver Solver, body *phys.Body) {
+
+	count := int64(interactions(solver, body))
+	atomic.AddInt64(&p.interactions, count)
+	for {
+		max := atomic.LoadInt64(&p.maxInteractions)
+		if count <= max || atomic.CompareAndSwapInt64(&p.maxInteractions, max, count) {
+			return
+		}
+	}
+}
+
+/*
+ * Record the depth and number of nodes of the tree of a solver
+ */
+func (p *profiler) treeStats(solver Solver) {
+
+	var tree *qtree.BHTree
+	switch s := solver.(type) {
+	case *qtree.BHTree:
+		tree = s
+	case *pm.TreePM:
+		tree = s.Tree
+	default:
+		return
+	}
+
+	depth, nodes := tree.Stats()
+	atomic.StoreInt64(&p.treeDepth, int64(depth))
+	atomic.StoreInt64(&p.treeNodes, int64(nodes))
+}
+
+/*
+ * Write the profile of a time-step and reset the counters
+ *
+ * step: Integer - time-step that just finished
+ * bodies: Integer - number of bodies processed in the time-step
+ */
+func (p *profiler) record(step int, bodies int) {
+
+	ms := func(counter *int64) float64 {
+		return float64(atomic.SwapInt64(counter, 0)) / float64(time.Millisecond)
+	}
+
+	now := time.Now()
+	profile := stepProfile{
+		Step:            step,
+		WallMs:          float64(now.Sub(p.last)) / float64(time.Millisecond),
+		BuildMs:         ms(&p.build),
+		ForceMs:         ms(&p.force),
+		UpdateMs:        ms(&p.update),
+		OutputMs:        ms(&p.output),
+		TreeDepth:       int(atomic.LoadInt64(&p.treeDepth)),
+		TreeNodes:       int(atomic.LoadInt64(&p.treeNodes)),
+		MaxInteractions: int(atomic.SwapInt64(&p.maxInteractions, 0)),
+	}
+	if bodies > 0 {
+		profile.Interactions = float64(atomic.SwapInt64(&p.interactions, 0)) / float64(bodies)
+	}
+	p.last = now
+
+	_ = p.enc.Encode(profile)
+}
+
+/*
+ * Start the CPU profile and runtime trace if their files are given
+ *
+ * cpuPath: file to write the pprof CPU profile into. Empty to disable
+ * tracePath: file to write the runtime trace into. Empty to disable
+ *
+ * return: function stopping the profiles, and any error starting them
+ */
+func startProfiles(cpuPath, tracePath string) (func(), error) {
+
+	stops := make([]func(), 0)
+	stop := func() {
+		for _, s := range stops {
+			s()
+		}
+	}
+
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return stop, err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return stop, err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+
+	if tracePath != "" {
+		f, err := os.Create(tracePath)
+		if err != nil {
+			return stop, err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			return stop, err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+
+	return stop, nil
+}
+
+/*
+ * Write a pprof heap profile
+ *
+ * path: file to write the heap profile into
+ */
+func writeHeapProfile(path string) error {
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return pprof.WriteHeapProfile(f)
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"os"
+	"proj3/phys"
+	"proj3/render"
+	"proj3/traj"
+	"strconv"
+)
+
+const replayUsage = "Usage: ./sim replay [-input=FILE] [-dt=FLOAT] [-trail=INTEGER] [-colour=NAME]\n" +
+	"\t [-style=outline|filled|additive] [-png=DIR | -gif=FILE] [-every=INTEGER] [-cells] <trajectory> <X> <Y>\n" +
+	"\t -input = Input file the run started from, for the masses and components of the bodies. Masses are 1 without it.\n" +
+	"\t -dt = Timestep of the run, for the velocities. Defaults to 0.4.\n" +
+	"\t -trail, -colour, -style = As for a live run.\n" +
+	"\t -png, -gif, -every, -cells = Draw the frames without a window, as for a console run.\n" +
+	"\t <trajectory> = JSON or binary output of a console run.\n" +
+	"\t <X> = The width of the window the run used. Positive Integer.\n" +
+	"\t <Y> = The height of the window the run used. Positive Integer."
+
+// Limits on the number of recorded time-steps played each frame
+const minPlaySpeed = 1.0 / 8
+const maxPlaySpeed = 64
+
+// Player steps through a recorded trajectory in replay mode
+type player struct {
+	position  float64 // Time-step being shown. Fractional while playing slower than a step each frame
+	steps     int     // Number of recorded time-steps
+	speed     float64 // Number of time-steps played each frame
+	paused    bool    // Whether playback is paused
+	backwards bool    // Whether playback runs backwards
+	scrubbing bool    // Whether the timeline is being dragged
+}
+
+/*
+ * Return a player at the start of a trajectory with the given number of time-steps
+ */
+func newPlayer(steps int) *player {
+	return &player{steps: steps, speed: 1}
+}
+
+/*
+ * Return the time-step being shown
+ */
+func (p *player) step() int {
+	return int(p.position)
+}
+
+/*
+ * Move to a time-step, or as close to it as the trajectory goes
+ */
+func (p *player) seek(position float64) {
+	p.position = math.Min(math.Max(position, 0), float64(p.steps-1))
+}
+
+/*
+ * Return the window rectangle of the timeline
+ */
+func (p *player) timeline() rl.Rectangle {
+	return rl.NewRectangle(20, float32(WindowHeight)-30, float32(WindowWidth)-40, 10)
+}
+
+/*
+ * Handle the playback controls for one frame, then advance
+ * P pauses and resumes, , and . step back and forward a time-step, [ and ] halve and double the speed,
+ * Z reverses the direction and dragging the timeline scrubs to a time-step
+ */
+func (p *player) update() {
+
+	if rl.IsKeyPressed(rl.KeyP) {
+		p.paused = !p.paused
+
+		// Resuming at the end starts again from the other end
+		if !p.paused && !p.backwards && p.step() == p.steps-1 {
+			p.seek(0)
+		} else if !p.paused && p.backwards && p.position == 0 {
+			p.seek(float64(p.steps - 1))
+		}
+	}
+	if rl.IsKeyPressed(rl.KeyComma) {
+		p.paused = true
+		p.seek(float64(p.step() - 1))
+	}
+	if rl.IsKeyPressed(rl.KeyPeriod) {
+		p.paused = true
+		p.seek(float64(p.step() + 1))
+	}
+	if rl.IsKeyPressed(rl.KeyLeftBracket) {
+		p.speed = math.Max(p.speed/2, minPlaySpeed)
+	}
+	if rl.IsKeyPressed(rl.KeyRightBracket) {
+		p.speed = math.Min(p.speed*2, maxPlaySpeed)
+	}
+	if rl.IsKeyPressed(rl.KeyZ) {
+		p.backwards = !p.backwards
+	}
+
+	// The timeline can be grabbed a little above and below the bar
+	mouse := rl.GetMousePosition()
+	bar := p.timeline()
+	if rl.IsMouseButtonPressed(rl.MouseLeftButton) &&
+		rl.CheckCollisionPointRec(mouse, rl.NewRectangle(bar.X, bar.Y-10, bar.Width, bar.Height+20)) {
+		p.scrubbing = true
+	}
+	if rl.IsMouseButtonReleased(rl.MouseLeftButton) {
+		p.scrubbing = false
+	}
+	if p.scrubbing {
+		p.seek(math.Round(float64((mouse.X-bar.X)/bar.Width) * float64(p.steps-1)))
+		return
+	}
+
+	if p.paused {
+		return
+	}
+
+	// Playback stops at either end
+	if p.backwards {
+		p.seek(p.position - p.speed)
+		p.paused = p.position == 0
+	} else {
+		p.seek(p.position + p.speed)
+		p.paused = p.step() == p.steps-1
+	}
+}
+
+/*
+ * Draw the timeline with a marker at the time-step being shown
+ */
+func (p *player) draw() {
+
+	bar := p.timeline()
+	rl.DrawRectangleLinesEx(bar, 1, rl.Gray)
+
+	x := bar.X
+	if p.steps > 1 {
+		x += bar.Width * float32(p.step()) / float32(p.steps-1)
+	}
+	rl.DrawRectangleV(rl.NewVector2(x-3, bar.Y-5), rl.NewVector2(6, bar.Height+10), rl.White)
+}
+
+/*
+ * Return the state of the player for the HUD
+ */
+func (p *player) String() string {
+
+	state := "Playing"
+	if p.paused {
+		state = "Paused"
+	}
+	if p.backwards {
+		state += " backwards"
+	}
+
+	return fmt.Sprintf("Replay: step %v / %v, %v x%v (P pause, , . step, [ ] speed, Z reverse, drag the timeline)",
+		p.step(), p.steps-1, state, p.speed)
+}
+
+/*
+ * Play a recorded trajectory from the command line, in a window or into frames
+ *
+ * args: command line arguments after "replay"
+ */
+func replayMode(args []string) {
+
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	inputPtr := flags.String("input", "", "Input file the run started from.")
+	dtPtr := flags.Float64("dt", phys.DefaultDt, "Timestep of the run.")
+	trailPtr := flags.Int("trail", 0, "Number of past positions drawn behind each body.")
+	colourPtr := flags.String("colour", render.Plain.String(), "Colour map: none, speed, mass, energy, density or component.")
+	stylePtr := flags.String("style", render.Outline.String(), "Drawing style: outline, filled or additive.")
+	pngPtr := flags.String("png", "", "Directory to write a numbered PNG of each frame into.")
+	gifPtr := flags.String("gif", "", "File to write an animated GIF of the frames into.")
+	everyPtr := flags.Int("every", 1, "Number of time-steps between frames.")
+	cellsPtr := flags.Bool("cells", false, "Draw the tree cells in the frames.")
+	flags.Usage = func() { fmt.Println(replayUsage) }
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 3 {
+		fmt.Println(replayUsage)
+		os.Exit(0)
+	}
+
+	WindowWidth, _ = strconv.Atoi(flags.Arg(1))
+	WindowHeight, _ = strconv.Atoi(flags.Arg(2))
+	TrailLength = *trailPtr
+	if WindowWidth <= 0 || WindowHeight <= 0 || *dtPtr <= 0 || TrailLength < 0 || *everyPtr <= 0 ||
+		(*pngPtr != "" && *gifPtr != "") {
+		fmt.Printf("X, Y, dt and every must be greater than 0, trail must not be negative, "+
+			"and png and gif can not be used together. Not [%v, %v, %v, %v, %v]\n",
+			WindowWidth, WindowHeight, *dtPtr, TrailLength, *everyPtr)
+		fmt.Println(replayUsage)
+		os.Exit(0)
+	}
+	Dt = float32(*dtPtr)
+
+	var err error
+	if ColourBy, err = render.ParseColourMap(*colourPtr); err == nil {
+		DrawStyle, err = render.ParseStyle(*stylePtr)
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(replayUsage)
+		os.Exit(0)
+	}
+
+	// Load the trajectory and the bodies it started from
+	trajectory, err := readTrajectory(flags.Arg(0))
+	var initial map[int]phys.Body
+	if err == nil && *inputPtr != "" {
+		initial, err = readInitial(*inputPtr)
+	}
+	if err == nil && trajectory.Steps() == 0 {
+		err = fmt.Errorf("%v has no bodies", flags.Arg(0))
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *pngPtr == "" && *gifPtr == "" {
+		replayGUI(trajectory, initial)
+		return
+	}
+
+	if Frames, err = newFrames(*pngPtr, *gifPtr, *everyPtr, *cellsPtr); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for step := 0; step < trajectory.Steps(); step++ {
+		Frames.record(step, trajectory.Bodies(step, initial, Dt))
+	}
+	Frames.close()
+}
+
+/*
+ * Read the trajectory written by a console run, either as JSON or as a binary trajectory
+ */
+func readTrajectory(path string) (*traj.Trajectory, error) {
+
+	if r, err := traj.Open(path); err != traj.ErrNotBinary {
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return r.Trajectory()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return traj.ReadJSON(f)
+}
+
+/*
+ * Read the bodies of an input file or TIPSY or Gadget snapshot by Id, along with their components
+ */
+func readInitial(path string) (map[int]phys.Body, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	in, err := inputReader(f)
+	if err != nil {
+		return nil, err
+	}
+	bodies := make([]phys.Body, 0)
+	readData(&bodies, nil, nil, json.NewDecoder(in), nil, nil)
+
+	initial := make(map[int]phys.Body, len(bodies))
+	for _, b := range bodies {
+		initial[b.Id] = b
+	}
+	return initial, nil
+}
+
+/*
+ * Play a trajectory in a window, with the camera, colours and trails of a live run
+ *
+ * trajectory: recorded positions of the bodies
+ * initial: bodies the run started with by Id. May be nil
+ */
+func replayGUI(trajectory *traj.Trajectory, initial map[int]phys.Body) {
+
+	rl.InitWindow(int32(WindowWidth), int32(WindowHeight), "N-Body Replay")
+	rl.SetTargetFPS(60)
+
+	View = newCamera()
+	drawing := newScene()
+	drawing.legend = int32(WindowHeight) - 50
+	control := newPlayer(trajectory.Steps())
+
+	var drawTree = false
+	var bodies []phys.Body
+	var shown = -1 // Time-step the bodies and trails are from
+
+	for !rl.WindowShouldClose() {
+
+		rl.BeginDrawing()
+		rl.ClearBackground(rl.Black)
+
+		if rl.IsKeyPressed(rl.KeyB) {
+			drawTree = !drawTree
+		}
+
+		// Turning the trails on fills them in from the recorded steps
+		length := drawing.trails.Length
+		drawing.update()
+		if drawing.trails.Length != length {
+			shown = -1
+		}
+
+		control.update()
+		if step := control.step(); step != shown {
+			bodies = trajectory.Bodies(step, initial, Dt)
+
+			// Playing forward adds the steps since the last frame to the trails, anything else fills them in again
+			from := step - drawing.trails.Length + 1
+			if shown >= 0 && step > shown && shown >= from {
+				from = shown + 1
+			} else {
+				drawing.trails.Clear()
+			}
+			for s := from; s <= step && drawing.trails.Length > 0; s++ {
+				if s >= 0 {
+					drawing.trails.Record(trajectory.Bodies(s, initial, Dt))
+				}
+			}
+			shown = step
+		}
+
+		// Clicking a body selects it for the camera to follow
+		View.update(bodies)
+		if rl.IsMouseButtonPressed(rl.MouseLeftButton) && !control.scrubbing {
+			View.selected = View.bodyAt(bodies, rl.GetMousePosition())
+		}
+
+		if drawTree {
+			drawSolver(buildSolver(bodies))
+		}
+		drawing.draw(bodies)
+		control.draw()
+
+		fps := fmt.Sprintf("FPS: %v", rl.GetFPS())
+		rl.DrawText(fps, int32(WindowWidth)-200, 10, 15, rl.White)
+		rl.DrawText(control.String(), 20, 10, 15, rl.White)
+		rl.DrawText(fmt.Sprintf("Camera: %v, zoom %.2fx (F fit, C centre, T follow, R reset)",
+			cameraModes[View.mode], View.zoom), 20, 35, 15, rl.White)
+		if i := indexOf(bodies, View.selected); i >= 0 {
+			b := bodies[i]
+			rl.DrawText(fmt.Sprintf("Body %v: mass %.3f, position [%.1f, %.1f], velocity [%.3f, %.3f]",
+				b.Id, b.Mass, b.Position.X, b.Position.Y, b.Velocity.X, b.Velocity.Y), 20, 60, 15, rl.Yellow)
+		}
+
+		rl.EndDrawing()
+	}
+
+	rl.CloseWindow()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"path/filepath"
+	"proj3/phys"
+	"testing"
+)
+
+func TestPlayerSeek(t *testing.T) {
+
+	p := newPlayer(10)
+	p.seek(3.5)
+	if p.step() != 3 {
+		t.Errorf("step = %v, want 3", p.step())
+	}
+
+	// Seeking stops at either end of the trajectory
+	p.seek(20)
+	if p.step() != 9 {
+		t.Errorf("step = %v, want 9", p.step())
+	}
+	p.seek(-1)
+	if p.step() != 0 {
+		t.Errorf("step = %v, want 0", p.step())
+	}
+}
+
+/*
+ * The golden trajectory of the Plummer sphere replays the run that wrote it
+ */
+func TestReplayTrajectory(t *testing.T) {
+
+	trajectory, err := readTrajectory(filepath.Join("testdata", "plummer.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial, err := readInitial(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The velocities of the run are the change in position over each step
+	setup(500, 500, 0)
+	bodies := make([]phys.Body, 0, len(initial))
+	for i := 0; i < len(initial); i++ {
+		bodies = append(bodies, initial[i])
+	}
+	const steps = 5
+	for step := 0; step < steps; step++ {
+		bodies = seqProcess(bodies, nil, false)
+	}
+
+	replayed := trajectory.Bodies(steps, initial, Dt)
+	if len(replayed) != len(bodies) {
+		t.Fatalf("%v bodies replayed, want %v", len(replayed), len(bodies))
+	}
+	for i := range bodies {
+		if replayed[i].Position != bodies[i].Position || replayed[i].Mass != bodies[i].Mass {
+			t.Errorf("body %v replayed as %+v, want %+v", i, replayed[i], bodies[i])
+		}
+		dx := replayed[i].Velocity.X - bodies[i].Velocity.X
+		dy := replayed[i].Velocity.Y - bodies[i].Velocity.Y
+		if dx*dx+dy*dy > 1e-6 {
+			t.Errorf("body %v velocity %v, want %v", i, replayed[i].Velocity, bodies[i].Velocity)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"proj3/phys"
+	"proj3/render"
+)
+
+// Trail length toggled on with L when none was given on the command line
+const defaultTrail = 100
+
+// Scene draws the bodies, their trails and the colour legend in GUI mode
+type scene struct {
+	colours render.ColourMap // Quantity the bodies are coloured by
+	style   render.Style     // How the bodies are drawn
+	trails  *render.Trails   // Last positions of the bodies
+	length  int              // Trail length restored when the trails are toggled back on
+	legend  int32            // Window height the legend is drawn above
+}
+
+/*
+ * Return a scene with the colour map, style and trail length from the command line
+ */
+func newScene() *scene {
+	length := TrailLength
+	if length <= 0 {
+		length = defaultTrail
+	}
+	return &scene{colours: ColourBy, style: DrawStyle, trails: render.NewTrails(TrailLength), length: length,
+		legend: int32(WindowHeight) - 20}
+}
+
+/*
+ * Handle the drawing controls for one frame
+ * M changes the colour map, V changes the style and L turns the trails on and off
+ */
+func (s *scene) update() {
+
+	if rl.IsKeyPressed(rl.KeyM) {
+		s.colours = s.colours.Next()
+	}
+	if rl.IsKeyPressed(rl.KeyV) {
+		s.style = s.style.Next()
+	}
+	if rl.IsKeyPressed(rl.KeyL) {
+		if s.trails.Length > 0 {
+			s.trails.Length = 0
+			s.trails.Clear()
+		} else {
+			s.trails.Length = s.length
+		}
+	}
+}
+
+/*
+ * Draw the trails and the bodies, with the selected body outlined in yellow, then the legend
+ *
+ * bodies: slice of physics bodies
+ */
+func (s *scene) draw(bodies []phys.Body) {
+
+	colours, legend := render.Colours(s.colours, bodies, Tags)
+
+	box := windowBox()
+	for i := 0; i < len(bodies); i++ {
+		points := s.trails.Points(bodies[i].Id)
+		for j := 1; j < len(points); j++ {
+			if render.Continuous(points[j-1], points[j], box) {
+				rl.DrawLineV(View.toScreen(points[j-1]), View.toScreen(points[j]),
+					rl.Fade(colours[i], render.Fade(j, len(points))))
+			}
+		}
+	}
+
+	if s.style == render.Additive {
+		rl.BeginBlendMode(rl.BlendAdditive)
+	}
+	for i := 0; i < len(bodies); i++ {
+		switch s.style {
+		case render.Outline:
+			View.drawBody(&bodies[i], colours[i])
+		case render.Filled:
+			View.fillBody(&bodies[i], colours[i])
+		case render.Additive:
+			View.fillBody(&bodies[i], rl.Fade(colours[i], render.AdditiveAlpha))
+		}
+	}
+	if s.style == render.Additive {
+		rl.EndBlendMode()
+	}
+
+	if i := indexOf(bodies, View.selected); i >= 0 {
+		View.drawBody(&bodies[i], rl.Yellow)
+	}
+
+	s.drawLegend(legend, s.legend)
+}
+
+/*
+ * Draw what the colours stand for, above a window height
+ */
+func (s *scene) drawLegend(legend render.Legend, bottom int32) {
+
+	const size = 15
+	title := fmt.Sprintf("Colour: %v, style: %v, trails: %v (M colour, V style, L trails)",
+		legend.Map, s.style, s.trails.Length)
+
+	switch legend.Map {
+	case render.Tag:
+		top := bottom - int32(len(legend.Tags))*20
+		for i, tag := range legend.Tags {
+			if tag == "" {
+				tag = "(none)"
+			}
+			y := top + int32(i)*20
+			rl.DrawRectangle(20, y, size, size, legend.Colours[i])
+			rl.DrawText(tag, 20+size+10, y, size, rl.White)
+		}
+		bottom = top
+
+	case render.Speed, render.Mass, render.Kinetic, render.Density:
+		const width, steps = 200, 50
+		y := bottom - size
+		for i := 0; i < steps; i++ {
+			rl.DrawRectangle(20+int32(i*width/steps), y, width/steps, size, render.Ramp((float64(i)+0.5)/steps))
+		}
+		rl.DrawText(legendValue(legend.Min), 20, y-20, size, rl.White)
+		max := legendValue(legend.Max)
+		rl.DrawText(max, 20+width-rl.MeasureText(max, size), y-20, size, rl.White)
+		if legend.Log {
+			rl.DrawText("log", 20+width/2-rl.MeasureText("log", size)/2, y-20, size, rl.White)
+		}
+		bottom = y - 20
+	}
+
+	rl.DrawText(title, 20, bottom-25, size, rl.White)
+}
+
+/*
+ * Return a short label for a legend value
+ */
+func legendValue(v float64) string {
+	if v != 0 && (math.Abs(v) < 0.01 || math.Abs(v) >= 1e4) {
+		return fmt.Sprintf("%.2e", v)
+	}
+	return fmt.Sprintf("%.3g", v)
+}
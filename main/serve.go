@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"proj3/engine"
+	"proj3/phys"
+	"proj3/pm"
+	"proj3/server"
+	"runtime"
+	"strconv"
+)
+
+const serveUsage = "Usage: ./sim serve [-addr=ADDRESS] [-input=FILE] [-cpus=INTEGER] [-threads=INTEGER] [-rate=FLOAT] [-run]\n" +
+	"\t [-solver=tree|pm|treepm] [-grid=INTEGER] [-boundary=open|reflective|periodic|absorbing] [-dt=FLOAT] <X> <Y>\n" +
+	"\t -addr = Address to listen on. Defaults to :8080.\n" +
+	"\t -input = Input file of the bodies to start with. Defaults to none.\n" +
+	"\t -cpus = Number of goroutines all runs together calculate the forces with. Defaults to the number of CPUs.\n" +
+	"\t -threads = Number of goroutines each run asks to calculate the forces with. Defaults to the number of CPUs.\n" +
+	"\t -rate = Number of time-steps to take each second. Defaults to 30.\n" +
+	"\t -run = Start running straight away instead of paused.\n" +
+	"\t -solver, -grid, -boundary, -dt = As for a console run.\n" +
+	"\t <X> = The width of the box. Positive Integer.\n" +
+	"\t <Y> = The height of the box. Positive Integer.\n" +
+	"\t The flags and box set up the first run, with Id 1. More runs are created through the API."
+
+/*
+ * Serve simulations over HTTP from the command line
+ *
+ * args: command line arguments after "serve"
+ */
+func serveMode(args []string) {
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addrPtr := flags.String("addr", ":8080", "Address to listen on.")
+	inputPtr := flags.String("input", "", "Input file of the bodies to start with.")
+	cpusPtr := flags.Int("cpus", runtime.NumCPU(), "Number of goroutines all runs together calculate the forces with.")
+	threadsPtr := flags.Int("threads", runtime.NumCPU(), "Number of goroutines each run asks to calculate the forces with.")
+	ratePtr := flags.Float64("rate", server.DefaultRate, "Number of time-steps to take each second.")
+	runPtr := flags.Bool("run", false, "Start running straight away.")
+	solverPtr := flags.String("solver", engine.TreeSolver, "Gravity solver: tree, pm or treepm.")
+	gridPtr := flags.Int("grid", pm.DefaultGridSize, "Number of mesh cells per side for pm and treepm.")
+	boundaryPtr := flags.String("boundary", "", "Boundary condition: open, reflective, periodic or absorbing.")
+	dtPtr := flags.Float64("dt", phys.DefaultDt, "Timestep.")
+	flags.Usage = func() { fmt.Println(serveUsage) }
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		fmt.Println(serveUsage)
+		os.Exit(0)
+	}
+
+	WindowWidth, _ = strconv.Atoi(flags.Arg(0))
+	WindowHeight, _ = strconv.Atoi(flags.Arg(1))
+	if *cpusPtr <= 0 || *threadsPtr <= 0 {
+		fmt.Printf("cpus and threads must be greater than 0. Not [%v, %v]\n", *cpusPtr, *threadsPtr)
+		fmt.Println(serveUsage)
+		os.Exit(0)
+	}
+
+	// The starting bodies keep their Ids
+	bodies := make([]server.NewBody, 0)
+	if *inputPtr != "" {
+		initial, err := readInitial(*inputPtr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		for id, b := range initial {
+			id := id
+			bodies = append(bodies, server.NewBody{Id: &id, Mass: b.Mass,
+				Position: [2]float32{b.Position.X, b.Position.Y}, Velocity: [2]float32{b.Velocity.X, b.Velocity.Y}})
+		}
+	}
+
+	if *boundaryPtr == "" {
+		*boundaryPtr = defaultBoundary(*solverPtr)
+	}
+
+	dt, paused := float32(*dtPtr), !*runPtr
+	run, err := server.NewRun(engine.NewConfig(WindowWidth, WindowHeight), bodies, *threadsPtr)
+	if err == nil {
+		err = run.Set(server.Params{Solver: solverPtr, Grid: gridPtr, Boundary: boundaryPtr, Dt: &dt,
+			Rate: ratePtr, Paused: &paused})
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(serveUsage)
+		os.Exit(0)
+	}
+
+	s := server.New(*cpusPtr, *threadsPtr)
+	if _, err := s.Add("", run); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Serving on %v\n", *addrPtr)
+	if err := http.ListenAndServe(*addrPtr, s.Handler()); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
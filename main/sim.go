@@ -8,25 +8,143 @@ import (
 	"io"
 	"math/rand"
 	"os"
+	"proj3/diag"
+	"proj3/engine"
 	"proj3/phys"
+	"proj3/pm"
 	"proj3/qtree"
+	"proj3/render"
+	"proj3/snapshot"
+	"proj3/traj"
 	"runtime"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const usage = "Usage: ./sim [-w | -i=INTEGER] <X> <Y> <thread_count>\n" +
+const usage = "Usage: ./sim [-w | -i=INTEGER] [-solver=tree|pm|treepm] [-grid=INTEGER]\n" +
+	"\t [-boundary=open|reflective|periodic|absorbing] [-diag=FILE] [-diagint=INTEGER] [-d] [-dt=FLOAT]\n" +
+	"\t [-trail=INTEGER] [-colour=NAME] [-style=outline|filled|additive]\n" +
+	"\t [-png=DIR | -gif=FILE] [-every=INTEGER] [-cells] [-format=json|binary|csv|npy|npz] [-precision=32|64]\n" +
+	"\t [-prof=FILE] [-cpuprofile=FILE] [-memprofile=FILE] [-trace=FILE] [-project=xy|xz|yz]\n" +
+	"\t [-checkpoint=FILE] [-checkpointformat=json|tipsy|gadget] [-fields=LIST] <X> <Y> <thread_count>\n" +
 	"\t -w = Run this program in GUI mode.\n" +
 	"\t -i = Number of updates to run. Must be greater than 0. (Note only have -w or -i, not both.\n" +
+	"\t -solver = Gravity solver. tree (Barnes-Hut, default), pm (periodic particle-mesh) or treepm (both).\n" +
+	"\t -grid = Number of mesh cells per side for pm and treepm. Power of two.\n" +
+	"\t -boundary = Boundary condition at the edges of the window. Defaults to open for tree and periodic otherwise.\n" +
+	"\t -diag = File to write energy, momentum and virial diagnostics into as JSON lines.\n" +
+	"\t -diagint = Number of time-steps between diagnostics. Must be greater than 0.\n" +
+	"\t -d = Deterministic mode. Output is identical for any thread count, including sequential.\n" +
+	"\t -dt = Timestep. Must be greater than 0. Defaults to 0.4.\n" +
+	"\t -trail = Number of past positions drawn behind each body. Defaults to 0, no trails.\n" +
+	"\t -colour = Colour bodies by none (default), speed, mass, energy, density or component.\n" +
+	"\t -style = Draw bodies as outlines (default), filled circles, or additive blended circles.\n" +
+	"\t -png = Directory to write each frame of a console run into, as frame_00000.png onwards.\n" +
+	"\t -gif = File to write the frames of a console run into as an animated GIF.\n" +
+	"\t -every = Number of time-steps between frames. Defaults to 1.\n" +
+	"\t -cells = Draw the Barnes-Hut tree cells in the frames.\n" +
+	"\t -format = Write the positions of a console run as json (default), or write each time-step as a binary trajectory,\n" +
+	"\t\t csv rows of step, time, id, x, y, vx, vy and mass, or a NumPy npy or npz array shaped (steps, bodies, fields).\n" +
+	"\t -precision = Bits per number of binary, npy and npz output, 32 (default) or 64.\n" +
+	"\t -fields = Comma separated fields of each body to output, from position, velocity, acceleration, mass, radius,\n" +
+	"\t\t potential and interactions. The position is always output. Defaults to position for json and binary output,\n" +
+	"\t\t and position, velocity and mass for csv, npy and npz.\n" +
+	"\t -prof = File to write the phase times, tree depth, node count and interactions of each time-step into as JSON lines.\n" +
+	"\t -cpuprofile, -memprofile = Files to write pprof CPU and heap profiles of the run into.\n" +
+	"\t -trace = File to write a runtime trace of the run into. View it with go tool trace.\n" +
+	"\t -project = Plane to project the 3D positions and velocities of a TIPSY or Gadget snapshot input onto.\n" +
+	"\t -checkpoint = File to write the bodies into at the end of a console run, to carry on from as input.\n" +
+	"\t -checkpointformat = Write the checkpoint as a json input file (default), or a tipsy or gadget snapshot.\n" +
 	"\t <X> = The width of the window. Positive Integer.\n" +
 	"\t <Y> = The height of the window. Positive Integer.\n" +
-	"\t <thread_count> = Number of maximum threads to use. Set to 0 to run in sequential mode."
+	"\t <thread_count> = Number of maximum threads to use. Set to 0 to run in sequential mode.\n" +
+	"       ./sim bench [options] <X> <Y> = Benchmark the tree-build, force and integrate phases. See ./sim bench -h.\n" +
+	"       ./sim replay [options] <trajectory> <X> <Y> = Play the output of a console run. See ./sim replay -h.\n" +
+	"       ./sim svg [options] <state> <X> <Y> = Draw a snapshot as an SVG. See ./sim svg -h.\n" +
+	"       ./sim serve [options] <X> <Y> = Serve simulations over HTTP and WebSockets. See ./sim serve -h.\n" +
+	"       ./sim sweep [options] <spec> = Run every combination of a parameter sweep. See ./sim sweep -h.\n" +
+	"       ./sim convert [options] <input> <output> = Convert between JSON and binary trajectories. See ./sim convert -h."
+
+// Solver types
+const (
+	TreeSolver   = engine.TreeSolver
+	MeshSolver   = engine.MeshSolver
+	TreePMSolver = engine.TreePMSolver
+)
 
 // Global variables
 var WindowWidth int
 var WindowHeight int
 var ThreadCount int
+var SolverType = TreeSolver
+var GridSize = pm.DefaultGridSize
+var BoundaryMode = phys.Open
+var DiagInterval = diag.DefaultInterval
+var DiagOutput *json.Encoder // Diagnostics stream. nil if diagnostics are not written
+var Deterministic bool
+var Dt float32 = phys.DefaultDt // Timestep. Negative when running backwards in the GUI
+var Tags = make(map[int]string) // Scenario component of each body by Id, for bodies that have one
+var TrailLength int
+var ColourBy = render.Plain
+var DrawStyle = render.Outline
+var OutputFormat = JSONFormat // Format of the output of a console run
+var Precision = 4             // Bytes per number of binary, npy and npz output
+var Projection = snapshot.XY  // Plane 3D snapshots are projected onto
+var CheckpointPath string     // File to write the bodies into at the end of a console run. Empty writes none
+var CheckpointFormat = JSONFormat
+var OutputFields []traj.Field // Fields of each body in the output. nil for the default of the format
+
+// Solver calculates the forces acting on a body for one time-step
+type Solver = engine.Solver
+
+/*
+ * Return the name of the boundary condition a solver runs with when none is given
+ * Mesh solvers are periodic, so default to a matching boundary
+ */
+func defaultBoundary(solver string) string {
+	if solver == TreeSolver {
+		return phys.Open.String()
+	}
+	return phys.Periodic.String()
+}
+
+/*
+ * Return the rectangle covered by the window
+ */
+func windowBox() rl.Rectangle {
+	return rl.NewRectangle(0, 0, float32(WindowWidth), float32(WindowHeight))
+}
+
+/*
+ * Calculate the diagnostics of the bodies every DiagInterval time-steps
+ * and write them to the diagnostics stream
+ *
+ * step: Integer - current time-step
+ * bodies: slice of physics body objects
+ *
+ * return: the diagnostics, or nil if they are not due on this time-step
+ */
+func recordDiagnostics(step int, bodies []phys.Body) *diag.Diagnostics {
+
+	if step%DiagInterval != 0 {
+		return nil
+	}
+
+	var d diag.Diagnostics
+	if SolverType == TreeSolver {
+		d = diag.Compute(step, bodies, windowBox(), BoundaryMode)
+	} else {
+		// The energy of a mesh run is only conserved with the mesh potential
+		d = diag.ComputeMesh(step, bodies, windowBox(), GridSize, SolverType == TreePMSolver, 0)
+	}
+	if DiagOutput != nil {
+		_ = DiagOutput.Encode(d)
+	}
+
+	return &d
+}
 
 /*
  * Initialize the data array to hold the positions of the bodies
@@ -50,82 +168,287 @@ func initData(bodies []phys.Body) []map[string]interface{} {
 }
 
 /*
- * Reads from a channel of bodies and adds them to a BHTree
+ * Reads from a channel of bodies and adds them to the selected solver
+ * (BHTree, Mesh or TreePM)
  *
  * bodies: channel holding physics bodies to read from
- * cTree: channel to send a BHTree into
+ * cTree: channel to send the built solver into
+ */
+func addToTree(bodies chan phys.Body, cTree chan Solver) {
+
+	solver := newSolver(bodies, Profile)
+	if Profile != nil {
+		Profile.treeStats(solver)
+	}
+
+	cTree <- solver
+}
+
+/*
+ * Return the settings of the run as an engine configuration
  */
-func addToTree(bodies chan phys.Body, cTree chan *qtree.BHTree) {
+func engineConfig() engine.Config {
 
-	tree := qtree.NewBHTree(rl.NewRectangle(0, 0, float32(WindowWidth), float32(WindowHeight)))
+	config := engine.NewConfig(WindowWidth, WindowHeight)
+	config.Solver, config.Grid, config.Boundary, config.Dt = SolverType, GridSize, BoundaryMode, Dt
+
+	return config
+}
+
+/*
+ * Build the selected solver from a channel of bodies
+ *
+ * bodies: channel holding physics bodies to read from
+ * profile: profiler to time the build with. nil to not time it
+ */
+func newSolver(bodies chan phys.Body, profile *profiler) Solver {
+
+	config := engineConfig()
+	bound := config.Box()
+
+	// Bodies are added as they arrive unless they must be sorted or the bound grown to fit them first
+	if Deterministic || config.GrowsBound() {
+		collected := make([]phys.Body, 0)
+		for body := range bodies {
+			collected = append(collected, body)
+		}
+		if Deterministic {
+			// The order bodies are added in changes the floating-point sums
+			engine.SortById(collected)
+		}
+		bound = engine.Bound(config, collected)
+		bodies = toChannel(collected)
+	}
+
+	solver, insert, solve := engine.NewSolver(config, bound)
+	insertAll(bodies, insert, profile)
+	finishSolver(solve, profile)
+
+	return solver
+}
+
+/*
+ * Insert every body from a channel into a solver, timing each insert when profiling
+ *
+ * bodies: channel holding physics bodies to read from
+ * insert: function adding a body to the solver
+ * profile: profiler to time the inserts with. May be nil
+ */
+func insertAll(bodies chan phys.Body, insert func(phys.Body), profile *profiler) {
 
 	for body := range bodies {
-		tree.Insert(body, 0)
+		if profile == nil {
+			insert(body)
+			continue
+		}
+
+		start := time.Now()
+		insert(body)
+		profile.add(&profile.build, start)
 	}
+}
 
-	cTree <- tree
+/*
+ * Finish a solver after every body is added, timing it when profiling
+ *
+ * solve: function finishing the solver, which solves the mesh of pm and treepm
+ * profile: profiler to time the solve with. May be nil
+ */
+func finishSolver(solve func(), profile *profiler) {
+
+	if profile == nil {
+		solve()
+		return
+	}
+
+	start := time.Now()
+	solve()
+	profile.add(&profile.build, start)
 }
 
 /*
- * Calculate the physics applied to a body
+ * Return a closed channel holding every body in the slice
+ */
+func toChannel(bodies []phys.Body) chan phys.Body {
+
+	c := make(chan phys.Body, len(bodies))
+	for i := 0; i < len(bodies); i++ {
+		c <- bodies[i]
+	}
+	close(c)
+
+	return c
+}
+
+/*
+ * Apply the boundary condition to a body after it is updated
+ * Absorbed bodies are logged and marked for removal by setting their mass to 0
+ *
+ * body: Pointer to the updated physics body
+ *
+ * return: false if the body was absorbed
+ */
+func applyBoundary(body *phys.Body) bool {
+
+	if body.ApplyBoundary(BoundaryMode, windowBox()) {
+		return true
+	}
+
+	fmt.Fprintf(os.Stderr, "Body %v absorbed at [%v, %v]\n", body.Id, body.Position.X, body.Position.Y)
+	body.Mass = 0
+	return false
+}
+
+/*
+ * Calculate the force on a body and move it one time-step
+ * Each phase is timed when profiling
+ *
+ * tree: Solver built from the bodies
+ * body: Pointer to the physics body
+ *
+ * return: false if the body was absorbed
+ */
+func stepBody(tree Solver, body *phys.Body) bool {
+
+	if Profile == nil {
+		tree.CalculateForces(body)
+		if stepFields != nil {
+			keepForceFields(tree, body)
+		}
+		body.Position = body.Update(Dt)
+		body.ZeroForce()
+		return applyBoundary(body)
+	}
+
+	if Profile.counting {
+		Profile.countInteractions(tree, body)
+	}
+
+	start := time.Now()
+	tree.CalculateForces(body)
+	Profile.add(&Profile.force, start)
+
+	if stepFields != nil {
+		start = time.Now()
+		keepForceFields(tree, body)
+		Profile.add(&Profile.output, start)
+	}
+
+	start = time.Now()
+	body.Position = body.Update(Dt)
+	body.ZeroForce()
+	kept := applyBoundary(body)
+	Profile.add(&Profile.update, start)
+
+	return kept
+}
+
+/*
+ * Remove the bodies absorbed by the boundary
  *
  * bodies: slice of physics body objects
- * data: slice of maps holding physics object data
- * draw: bool controlling the drawing of the tree
+ *
+ * return: slice holding the remaining bodies
  */
-func seqProcess(bodies []phys.Body, data []map[string]interface{}, draw bool) {
+func removeAbsorbed(bodies []phys.Body) []phys.Body {
 
-	// Channels for communication
-	cBodies := make(chan phys.Body, len(bodies))
-	cTree := make(chan *qtree.BHTree, 1)
+	if BoundaryMode != phys.Absorbing {
+		return bodies
+	}
 
-	// Add the bodies into the tree
+	remaining := bodies[:0]
 	for i := 0; i < len(bodies); i++ {
-		cBodies <- bodies[i]
+		if bodies[i].Mass != 0 {
+			remaining = append(remaining, bodies[i])
+		}
 	}
 
-	close(cBodies)
+	return remaining
+}
+
+/*
+ * Draw the tree of a solver to the screen in GUI mode, through the camera
+ * The particle-mesh solver has no tree to draw
+ */
+func drawSolver(solver Solver) {
+
+	switch s := solver.(type) {
+	case *qtree.BHTree:
+		s.DrawTree(View.toScreen)
+	case *pm.TreePM:
+		s.Tree.DrawTree(View.toScreen)
+	}
+}
+
+/*
+ * Build a solver from the bodies on this thread
+ */
+func buildSolver(bodies []phys.Body) Solver {
+
+	cTree := make(chan Solver, 1)
+	addToTree(toChannel(bodies), cTree)
+
+	return <-cTree
+}
+
+/*
+ * Calculate the physics applied to a body
+ *
+ * bodies: slice of physics body objects
+ * data: slice of maps holding physics object data
+ * draw: bool controlling the drawing of the tree
+ *
+ * return: slice holding the bodies that were not absorbed by the boundary
+ */
+func seqProcess(bodies []phys.Body, data []map[string]interface{}, draw bool) []phys.Body {
 
 	// Add the bodies to the tree
-	addToTree(cBodies, cTree)
-	tree := <-cTree
+	tree := buildSolver(bodies)
 
 	// Calculate the physics on each object
 	for i := 0; i < len(bodies); i++ {
-		tree.CalculateForces(&bodies[i])
-		bodies[i].Position = bodies[i].Update()
-		bodies[i].ZeroForce()
+		if !stepBody(tree, &bodies[i]) {
+			continue
+		}
 
 		// Add the updated position data
 		if data != nil {
-			data[i]["Position"] = append(data[i]["Position"].([][]float32),
+			start := time.Now()
+			id := bodies[i].Id
+			data[id]["Position"] = append(data[id]["Position"].([][]float32),
 				[]float32{bodies[i].Position.X, bodies[i].Position.Y})
+			if Profile != nil {
+				Profile.add(&Profile.output, start)
+			}
 		}
 	}
 
 	// Draw the tree
 	if data == nil && draw {
-		tree.DrawTree()
+		drawSolver(tree)
 	}
+
+	return removeAbsorbed(bodies)
 }
 
 /*
  * Process the data for each thread
  *
  * bodies: slice of physcis body objects
- * tree: pointer to a BHTree
+ * tree: Solver built from the bodies
  * cData: Channel to send updated bodies into
  * cBodies: Channel to send bodies for the creation of a tree
  * done: Channel signifying that all of the threads are done
+ * running: number of threads still processing, shared by every thread
  */
-func parProcess(bodies []phys.Body, tree *qtree.BHTree,
-	cData chan phys.Body, cBodies chan phys.Body, done chan bool) {
+func parProcess(bodies []phys.Body, tree Solver,
+	cData chan phys.Body, cBodies chan phys.Body, done chan bool, running *int32) {
 
 	// Iterate over the objects and apply the physics on them
 	for i := 0; i < len(bodies); i++ {
-		tree.CalculateForces(&bodies[i])
-		bodies[i].Position = bodies[i].Update()
-		bodies[i].ZeroForce()
+		if !stepBody(tree, &bodies[i]) {
+			continue
+		}
 
 		// Send to create the next tree
 		cBodies <- bodies[i]
@@ -138,7 +461,7 @@ func parProcess(bodies []phys.Body, tree *qtree.BHTree,
 
 	// This thread is done processing and if it is the last one, then close the channels
 	done <- true
-	if len(done) == ThreadCount {
+	if atomic.AddInt32(running, -1) == 0 {
 		if cData != nil {
 			close(cData)
 		}
@@ -200,14 +523,21 @@ func readData(b *[]phys.Body, wg *sync.WaitGroup, mtx *sync.Mutex,
 		vel := rl.NewVector2(float32(inData["Velocity"].([]interface{})[0].(float64)),
 			float32(inData["Velocity"].([]interface{})[1].(float64)))
 		body := phys.NewBody(float32(inData["Mass"].(float64)), int(inData["Id"].(float64)), pos, vel)
+		tag, tagged := inData["Component"].(string)
 
 		// Add it to the slice
 		if mtx != nil {
 			mtx.Lock()
 			*b = append(*b, body)
+			if tagged {
+				Tags[body.Id] = tag
+			}
 			mtx.Unlock()
 		} else {
 			*b = append(*b, body)
+			if tagged {
+				Tags[body.Id] = tag
+			}
 		}
 
 		// Send body for tree creation
@@ -224,25 +554,65 @@ func readData(b *[]phys.Body, wg *sync.WaitGroup, mtx *sync.Mutex,
  * Run the sequential version of the program
  *
  * numIterations: Integer - number of time-steps to calculate
+ * in: Reader to read the bodies from
+ * out: Writer to write the positions into
  */
-func sequential(numIterations int) {
+func sequential(numIterations int, in io.Reader, out io.Writer) {
 
 	// Read in the physics bodies
-	dec := json.NewDecoder(os.Stdin)
+	dec := inputDecoder(in)
 	bodies := make([]phys.Body, 0)
 	readData(&bodies, nil, nil, dec, nil, nil)
+	if Deterministic {
+		engine.SortById(bodies)
+	}
 
 	// Slice to hold the data for each object
 	bodiesData := initData(bodies)
 
+	if DiagOutput != nil {
+		recordDiagnostics(0, bodies)
+	}
+	if Frames != nil {
+		Frames.record(0, bodies)
+	}
+	trajectory := newTrajectoryWriter(out, bodies, numIterations+1)
+	startFields(bodies)
+	recordFrame(trajectory, bodiesData, bodies)
+
 	// Calculate the changed position for each object numIterations number of times
 	for count := 0; count < numIterations; count++ {
-		seqProcess(bodies, bodiesData, false)
+		bodies = seqProcess(bodies, bodiesData, false)
+
+		if DiagOutput != nil {
+			recordDiagnostics(count+1, bodies)
+		}
+		if Frames != nil {
+			Frames.record(count+1, bodies)
+		}
+		recordFrame(trajectory, bodiesData, bodies)
+
+		// The last time-step is recorded once the output is encoded
+		if Profile != nil && count+1 < numIterations {
+			Profile.record(count+1, len(bodies))
+		}
 	}
 
 	// Output the data
-	enc := json.NewEncoder(os.Stdout)
-	_ = enc.Encode(bodiesData)
+	start := time.Now()
+	if trajectory != nil {
+		closeTrajectory(trajectory)
+	} else {
+		enc := json.NewEncoder(out)
+		_ = enc.Encode(bodiesData)
+	}
+	if Profile != nil {
+		Profile.add(&Profile.output, start)
+		Profile.record(numIterations, len(bodies))
+	}
+	if CheckpointPath != "" {
+		writeCheckpoint(bodies, numIterations)
+	}
 
 }
 
@@ -250,52 +620,62 @@ func sequential(numIterations int) {
  * GUI version to run the parallel code
  *
  * bodies: Slice of physics objects
- * tree: Pointer to a BHTree
- * cTree: Channel to pass to addToTree() to send back a built tree
+ * tree: Solver built from the bodies
+ * cTree: Channel to pass to addToTree() to send back a built solver
  * draw: Bool - draw the tree
+ *
+ * return: slice holding the bodies that were not absorbed by the boundary
  */
-func guiParallel(bodies []phys.Body, tree *qtree.BHTree, cTree chan *qtree.BHTree, draw bool) {
+func guiParallel(bodies []phys.Body, tree Solver, cTree chan Solver, draw bool) []phys.Body {
 
 	// Create a new channel of physics objects
 	cBodies := make(chan phys.Body, len(bodies))
 
+	if len(bodies) == 0 {
+		// Every body was absorbed, but the next frame still waits on a tree
+		close(cBodies)
+		go addToTree(cBodies, cTree)
+		return bodies
+	}
+
 	// Calculate the length of data each thread will operate on
-	sublength := len(bodies) / ThreadCount
+	// With fewer bodies than threads each gets one, leaving ThreadCount for when bodies are added back
+	workers := ThreadCount
+	sublength := len(bodies) / workers
 	if sublength == 0 {
-		ThreadCount = len(bodies)
+		workers = len(bodies)
 		sublength = 1
 	}
 
 	// Channel to signal when each thread is done
-	workersDone := make(chan bool, ThreadCount)
+	workersDone := make(chan bool, workers)
+	running := int32(workers)
 
 	// Start building the tree
 	go addToTree(cBodies, cTree)
 
 	// Send each thread to work on their respective subsections
-	for i := 0; i < ThreadCount; i++ {
+	for i := 0; i < workers; i++ {
 
 		min := sublength * i
 		var max int
-		if i == ThreadCount-1 {
+		if i == workers-1 {
 			max = len(bodies)
 		} else {
 			max = min + sublength
 		}
 
-		go parProcess(bodies[min:max], tree, nil, cBodies, workersDone)
+		go parProcess(bodies[min:max], tree, nil, cBodies, workersDone, &running)
 	}
 
 	// Wait until the threads are done
-	for {
-		if len(workersDone) == ThreadCount {
-			if draw {
-				tree.DrawTree()
-			}
-			return
-		}
-		runtime.Gosched()
+	for i := 0; i < workers; i++ {
+		<-workersDone
 	}
+	if draw {
+		drawSolver(tree)
+	}
+	return removeAbsorbed(bodies)
 
 }
 
@@ -303,17 +683,19 @@ func guiParallel(bodies []phys.Body, tree *qtree.BHTree, cTree chan *qtree.BHTre
  * Run the parallel version of the console program
  *
  * numIterations: Integer - number of time-steps to calculate
+ * in: Reader to read the bodies from
+ * out: Writer to write the positions into
  */
-func parallel(numIterations int) {
+func parallel(numIterations int, in io.Reader, out io.Writer) {
 
 	// Waitgroup and lock for reading in data
 	var mtx sync.Mutex
 	var wg sync.WaitGroup
 
-	// Stdin reader and channels for reader threads
-	dec := json.NewDecoder(os.Stdin)
+	// Input reader and channels for reader threads
+	dec := inputDecoder(in)
 	cBodies := make(chan phys.Body)             // readers send physics objects here so the tree can be built
-	cTree := make(chan *qtree.BHTree)           // Tree builder sends tree into here
+	cTree := make(chan Solver)                  // Tree builder sends tree into here
 	readersDone := make(chan bool, ThreadCount) // Used to sync up the readers
 
 	// Start building the tree
@@ -327,17 +709,38 @@ func parallel(numIterations int) {
 	}
 	wg.Wait()
 
+	// The readers append the bodies in whatever order they get them
+	if Deterministic {
+		engine.SortById(bodies)
+	}
+
 	//Data to hold updated positions
 	bodiesData := initData(bodies)
 
-	// Calculate subsection of slice that each thread is going to work on
-	sublength := len(bodies) / ThreadCount
-	if sublength == 0 {
-		ThreadCount = len(bodies)
-		sublength = 1
+	if DiagOutput != nil {
+		recordDiagnostics(0, bodies)
 	}
-
-	for count := 0; count < numIterations; count++ {
+	if Frames != nil {
+		Frames.record(0, bodies)
+	}
+	trajectory := newTrajectoryWriter(out, bodies, numIterations+1)
+	startFields(bodies)
+	recordFrame(trajectory, bodiesData, bodies)
+
+	// Whether a tree is being built that nothing has received from cTree yet
+	building := true
+
+	count := 0
+	for ; count < numIterations && len(bodies) > 0; count++ {
+
+		// Calculate subsection of slice that each thread is going to work on
+		// With fewer bodies than threads each gets one, leaving ThreadCount as it was set
+		workers := ThreadCount
+		sublength := len(bodies) / workers
+		if sublength == 0 {
+			workers = len(bodies)
+			sublength = 1
+		}
 
 		// Wait for tree to finish building --- Synchronous Barrier
 		tree := <-cTree
@@ -345,34 +748,75 @@ func parallel(numIterations int) {
 		// Data to hold updated positions
 		cBodies = make(chan phys.Body, len(bodies))
 		cData := make(chan phys.Body, len(bodies))
-		workersDone := make(chan bool, ThreadCount)
+		workersDone := make(chan bool, workers)
+		running := int32(workers)
 
-		// Start building the new tree
-		go addToTree(cBodies, cTree)
+		// Start building the new tree, unless there is no time-step left to use it
+		building = count+1 < numIterations
+		if building {
+			go addToTree(cBodies, cTree)
+		}
 
 		// Spawn off each thread to work on its part of the slice
-		for i := 0; i < ThreadCount; i++ {
+		for i := 0; i < workers; i++ {
 			min := sublength * i
 			var max int
-			if i == ThreadCount-1 {
+			if i == workers-1 {
 				max = len(bodies)
 			} else {
 				max = min + sublength
 			}
-			go parProcess(bodies[min:max], tree, cData, cBodies, workersDone)
+			go parProcess(bodies[min:max], tree, cData, cBodies, workersDone, &running)
 
 		}
 
 		// Read in the data for each body
 		for b := range cData {
+			start := time.Now()
 			bodiesData[b.Id]["Position"] = append(bodiesData[b.Id]["Position"].([][]float32),
 				[]float32{b.Position.X, b.Position.Y})
+			if Profile != nil {
+				Profile.add(&Profile.output, start)
+			}
+		}
+
+		bodies = removeAbsorbed(bodies)
+
+		if DiagOutput != nil {
+			recordDiagnostics(count+1, bodies)
+		}
+		if Frames != nil {
+			Frames.record(count+1, bodies)
+		}
+		recordFrame(trajectory, bodiesData, bodies)
+
+		// The last time-step is recorded once the output is encoded
+		if Profile != nil && count+1 < numIterations && len(bodies) > 0 {
+			Profile.record(count+1, len(bodies))
 		}
 	}
 
+	// A run that stops early, or takes no time-steps, still has a tree building
+	// Wait for it so it does not outlive the run
+	if building {
+		<-cTree
+	}
+
 	// Output the data
-	enc := json.NewEncoder(os.Stdout)
-	_ = enc.Encode(bodiesData)
+	start := time.Now()
+	if trajectory != nil {
+		closeTrajectory(trajectory)
+	} else {
+		enc := json.NewEncoder(out)
+		_ = enc.Encode(bodiesData)
+	}
+	if Profile != nil {
+		Profile.add(&Profile.output, start)
+		Profile.record(count, len(bodies))
+	}
+	if CheckpointPath != "" {
+		writeCheckpoint(bodies, count)
+	}
 
 }
 
@@ -385,13 +829,38 @@ func consoleMode(numIterations int) {
 
 	if ThreadCount == 0 {
 		// Run in sequential mode
-		sequential(numIterations)
+		sequential(numIterations, os.Stdin, os.Stdout)
 	} else {
 		// Run the parallel version
 		runtime.GOMAXPROCS(ThreadCount)
-		parallel(numIterations)
+		parallel(numIterations, os.Stdin, os.Stdout)
+	}
+
+	if Frames != nil {
+		Frames.close()
+	}
+}
+
+/*
+ * Start building a tree from the bodies for the next parallel GUI frame
+ *
+ * bodies: slice of physics bodies
+ * cTree: Channel the tree is sent into
+ * pending: whether a tree built from older bodies is waiting in cTree and must be cleared first
+ */
+func rebuildTree(bodies []phys.Body, cTree chan Solver, pending bool) {
+
+	if pending {
+		<-cTree
 	}
 
+	// Add the bodies to the tree
+	cBodies := make(chan phys.Body, len(bodies))
+	go addToTree(cBodies, cTree)
+	for i := 0; i < len(bodies); i++ {
+		cBodies <- bodies[i]
+	}
+	close(cBodies)
 }
 
 /*
@@ -414,15 +883,24 @@ func guiMode() {
 	rl.SetTargetFPS(60)
 
 	// Read in the physics bodies
-	dec := json.NewDecoder(os.Stdin)
+	dec := inputDecoder(os.Stdin)
 	bodies := make([]phys.Body, 0)
-	cTree := make(chan *qtree.BHTree)
+	cTree := make(chan Solver)
 	readData(&bodies, nil, nil, dec, nil, nil)
+	if Deterministic {
+		engine.SortById(bodies)
+	}
 
 	var drawTree = false
 	var parallelMode = false
 	var first = true
 	var setting = "Sequential (Press Space to Change)"
+	var step = 0
+	var diagnostics = recordDiagnostics(step, bodies)
+	View = newCamera()
+	edit := newEditor()
+	control := newClock()
+	drawing := newScene()
 
 	// GUI loop
 	for !rl.WindowShouldClose() {
@@ -430,7 +908,7 @@ func guiMode() {
 		rl.BeginDrawing()
 		rl.ClearBackground(rl.Black)
 
-		var tree *qtree.BHTree // Declare the tree object
+		var tree Solver // Declare the tree object
 
 		if rl.IsKeyPressed(rl.KeyB) {
 			// Check whether we should draw the tree or not
@@ -444,20 +922,8 @@ func guiMode() {
 			if parallelMode {
 
 				// This clears the previous tree that's waiting if this isn't the first switch to parallel
-				switch first {
-				case true:
-					first = false
-				case false:
-					<-cTree
-				}
-
-				// Add the bodies to the tree
-				cBodies := make(chan phys.Body, len(bodies))
-				go addToTree(cBodies, cTree)
-				for i := 0; i < len(bodies); i++ {
-					cBodies <- bodies[i]
-				}
-				close(cBodies)
+				rebuildTree(bodies, cTree, !first)
+				first = false
 
 				setting = "Parallel"
 			} else {
@@ -465,18 +931,42 @@ func guiMode() {
 			}
 		}
 
-		// Compute the physics
-		if parallelMode {
-			tree = <-cTree // --- Synchronous Barrier
-			guiParallel(bodies, tree, cTree, drawTree)
-		} else {
-			seqProcess(bodies, nil, drawTree)
+		// Move the camera before anything is drawn, so the tree and bodies line up
+		View.update(bodies)
+		drawing.update()
+
+		// The tree waiting for the next parallel step is out of date once the bodies are edited
+		var edited bool
+		if bodies, edited = edit.update(bodies); edited && parallelMode {
+			rebuildTree(bodies, cTree, true)
 		}
 
-		// Draw each object
-		for i := 0; i < len(bodies); i++ {
-			rl.DrawCircleLines(int32(bodies[i].Position.X), int32(bodies[i].Position.Y), bodies[i].Radius, rl.Green)
+		// Compute the physics, drawing the tree of the last step
+		steps := control.update(bodies)
+		for s := 0; s < steps; s++ {
+			draw := drawTree && s == steps-1
+			if parallelMode {
+				tree = <-cTree // --- Synchronous Barrier
+				bodies = guiParallel(bodies, tree, cTree, draw)
+			} else {
+				bodies = seqProcess(bodies, nil, draw)
+			}
+
+			step++
+			drawing.trails.Record(bodies)
+			if d := recordDiagnostics(step, bodies); d != nil {
+				diagnostics = d
+			}
+			if Profile != nil {
+				Profile.record(step, len(bodies))
+			}
 		}
+		if steps == 0 && drawTree {
+			drawSolver(buildSolver(bodies))
+		}
+
+		// Draw each object
+		drawing.draw(bodies)
 
 		// Print MetaData to Screen
 		frameTime := fmt.Sprintf("FrameTime: %.4fms", rl.GetFrameTime()*1000)
@@ -484,6 +974,13 @@ func guiMode() {
 		rl.DrawText(fps, int32(WindowWidth)-200, 10, 15, rl.White)
 		rl.DrawText(frameTime, int32(WindowWidth)-200, 35, 15, rl.White)
 		rl.DrawText(setting, 20, 10, 15, rl.White)
+		rl.DrawText(control.String(), 40+rl.MeasureText(setting, 15), 10, 15, rl.White)
+		rl.DrawText(fmt.Sprintf("Camera: %v, zoom %.2fx (F fit, C centre, T follow, R reset)",
+			cameraModes[View.mode], View.zoom), 20, 35, 15, rl.White)
+		edit.draw(bodies, 60)
+		for i, line := range diagnostics.Summary() {
+			rl.DrawText(line, int32(WindowWidth)-300, int32(60+25*i), 15, rl.White)
+		}
 
 		rl.EndDrawing()
 	}
@@ -493,9 +990,58 @@ func guiMode() {
 
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		benchMode(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		replayMode(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "svg" {
+		svgMode(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		serveMode(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "sweep" {
+		sweepMode(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		convertMode(os.Args[2:])
+		return
+	}
+
 	// Flag commands for CLI
 	wPtr := flag.Bool("w", false, "Run this program in GUI mode.")
 	iPtr := flag.Int("i", -1, "Number of updates to run.")
+	solverPtr := flag.String("solver", TreeSolver, "Gravity solver: tree, pm or treepm.")
+	gridPtr := flag.Int("grid", pm.DefaultGridSize, "Number of mesh cells per side for pm and treepm.")
+	diagPtr := flag.String("diag", "", "File to write diagnostics into.")
+	diagIntPtr := flag.Int("diagint", diag.DefaultInterval, "Number of time-steps between diagnostics.")
+	boundaryPtr := flag.String("boundary", "", "Boundary condition: open, reflective, periodic or absorbing.")
+	dPtr := flag.Bool("d", false, "Deterministic mode.")
+	dtPtr := flag.Float64("dt", phys.DefaultDt, "Timestep.")
+	trailPtr := flag.Int("trail", 0, "Number of past positions drawn behind each body.")
+	colourPtr := flag.String("colour", render.Plain.String(), "Colour map: none, speed, mass, energy, density or component.")
+	stylePtr := flag.String("style", render.Outline.String(), "Drawing style: outline, filled or additive.")
+	pngPtr := flag.String("png", "", "Directory to write a numbered PNG of each frame into.")
+	gifPtr := flag.String("gif", "", "File to write an animated GIF of the frames into.")
+	everyPtr := flag.Int("every", 1, "Number of time-steps between frames.")
+	cellsPtr := flag.Bool("cells", false, "Draw the tree cells in the frames.")
+	formatPtr := flag.String("format", JSONFormat, "Output format: json, binary, csv, npy or npz.")
+	precisionPtr := flag.Int("precision", 32, "Bits per number of binary, npy and npz output: 32 or 64.")
+	profPtr := flag.String("prof", "", "File to write per time-step phase times and tree statistics into.")
+	cpuPtr := flag.String("cpuprofile", "", "File to write a pprof CPU profile into.")
+	memPtr := flag.String("memprofile", "", "File to write a pprof heap profile into.")
+	tracePtr := flag.String("trace", "", "File to write a runtime trace into.")
+	projectPtr := flag.String("project", snapshot.XY.String(), "Plane to project 3D snapshots onto: xy, xz or yz.")
+	checkpointPtr := flag.String("checkpoint", "", "File to write the bodies into at the end of a console run.")
+	checkpointFormatPtr := flag.String("checkpointformat", JSONFormat, "Checkpoint format: json, tipsy or gadget.")
+	fieldsPtr := flag.String("fields", "", "Comma separated fields of each body to output.")
 
 	// Parse commands and error check the input
 	flag.Parse()
@@ -516,9 +1062,134 @@ func main() {
 		os.Exit(0)
 	}
 
+	SolverType = *solverPtr
+	GridSize = *gridPtr
+	Deterministic = *dPtr
+
+	if *boundaryPtr == "" {
+		*boundaryPtr = defaultBoundary(SolverType)
+	}
+
+	var err error
+	if BoundaryMode, err = phys.ParseBoundary(*boundaryPtr); err != nil {
+		fmt.Println(err)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if (SolverType != TreeSolver && SolverType != MeshSolver && SolverType != TreePMSolver) ||
+		GridSize <= 0 || GridSize&(GridSize-1) != 0 {
+		fmt.Printf("solver must be tree, pm or treepm and grid must be a power of two. "+
+			"Not [%v, %v]\n", SolverType, GridSize)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if *dtPtr <= 0 {
+		fmt.Printf("dt must be greater than 0. Not [%v]\n", *dtPtr)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+	Dt = float32(*dtPtr)
+
+	TrailLength = *trailPtr
+	if TrailLength < 0 {
+		fmt.Printf("trail must not be negative. Not [%v]\n", TrailLength)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+	if ColourBy, err = render.ParseColourMap(*colourPtr); err == nil {
+		DrawStyle, err = render.ParseStyle(*stylePtr)
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if (*pngPtr != "" || *gifPtr != "") && (*wPtr || (*pngPtr != "" && *gifPtr != "") || *everyPtr <= 0) {
+		fmt.Printf("png and gif can not be used together or in GUI mode, and every must be greater than 0. "+
+			"Not [%v, %v, %v]\n", *pngPtr, *gifPtr, *everyPtr)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if !validFormat(*formatPtr) || (*precisionPtr != 32 && *precisionPtr != 64) {
+		fmt.Printf("format must be json, binary, csv, npy or npz and precision must be 32 or 64. Not [%v, %v]\n",
+			*formatPtr, *precisionPtr)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+	OutputFormat, Precision = *formatPtr, *precisionPtr/8
+	if *fieldsPtr != "" {
+		if OutputFields, err = traj.ParseFields(*fieldsPtr); err != nil {
+			fmt.Println(err)
+			fmt.Println(usage)
+			os.Exit(0)
+		}
+	}
+
+	if Projection, err = snapshot.ParseProjection(*projectPtr); err == nil && *checkpointFormatPtr != JSONFormat {
+		_, err = snapshot.ParseFormat(*checkpointFormatPtr)
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+	CheckpointPath, CheckpointFormat = *checkpointPtr, *checkpointFormatPtr
+
+	DiagInterval = *diagIntPtr
+	if DiagInterval <= 0 {
+		fmt.Printf("diagint must be greater than 0. Not [%v]\n", DiagInterval)
+		fmt.Println(usage)
+		os.Exit(0)
+	}
+
+	if *diagPtr != "" {
+		diagFile, err := os.Create(*diagPtr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer diagFile.Close()
+		DiagOutput = json.NewEncoder(diagFile)
+	}
+
+	if *profPtr != "" {
+		profFile, err := os.Create(*profPtr)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer profFile.Close()
+		Profile = newProfiler(json.NewEncoder(profFile), true)
+	}
+
+	if *pngPtr != "" || *gifPtr != "" {
+		if Frames, err = newFrames(*pngPtr, *gifPtr, *everyPtr, *cellsPtr); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	stopProfiles, err := startProfiles(*cpuPtr, *tracePtr)
+	if err != nil {
+		stopProfiles()
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
 	if *wPtr {
 		guiMode()
 	} else {
 		consoleMode(*iPtr)
 	}
+
+	stopProfiles()
+	if *memPtr != "" {
+		if err := writeHeapProfile(*memPtr); err != nil {
+			fmt.Println(err)
+		}
+	}
 }
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"proj3/engine"
+	"proj3/phys"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files from the sequential driver.")
+
+// Trajectory of one body as written by the console drivers
+type trajectory struct {
+	Id       int
+	Position [][]float32
+}
+
+/*
+ * Set the global settings used by the drivers
+ */
+func setup(width, height, threads int) {
+	WindowWidth = width
+	WindowHeight = height
+	ThreadCount = threads
+	SolverType = TreeSolver
+	BoundaryMode = phys.Open
+	DiagOutput = nil
+	Deterministic = true
+	Dt = phys.DefaultDt
+	OutputFormat = JSONFormat
+	Precision = 4
+	CheckpointPath = ""
+	CheckpointFormat = JSONFormat
+	OutputFields = nil
+	stepFields = nil
+}
+
+/*
+ * Run the sequential (threads = 0) or parallel driver on the input
+ *
+ * return: the trajectory of each body
+ */
+func run(t *testing.T, input []byte, steps, threads int) []trajectory {
+
+	var out bytes.Buffer
+	if threads == 0 {
+		sequential(steps, bytes.NewReader(input), &out)
+	} else {
+		parallel(steps, bytes.NewReader(input), &out)
+	}
+
+	var result []trajectory
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("could not decode output: %v", err)
+	}
+
+	return result
+}
+
+/*
+ * Two bodies on a circular orbit keep their separation through every driver
+ */
+func TestDriversTwoBodyOrbit(t *testing.T) {
+
+	const mass = 10
+	const separation = 100.0
+	speed := math.Sqrt(phys.G * mass / (2 * separation))
+	input := fmt.Sprintf(
+		"{\"Command\":\"ADD\",\"Id\":0,\"Mass\":%v,\"Position\":[%v,250],\"Velocity\":[0,%v]}\n"+
+			"{\"Command\":\"ADD\",\"Id\":1,\"Mass\":%v,\"Position\":[%v,250],\"Velocity\":[0,%v]}\n",
+		mass, 250-separation/2, -speed, mass, 250+separation/2, speed)
+
+	for _, threads := range []int{0, 1, 2} {
+		setup(500, 500, threads)
+		result := run(t, []byte(input), 2000, threads)
+
+		if len(result) != 2 || len(result[0].Position) != 2001 {
+			t.Fatalf("threads = %v: unexpected output shape", threads)
+		}
+
+		for step := range result[0].Position {
+			p0, p1 := result[0].Position[step], result[1].Position[step]
+			r := math.Hypot(float64(p1[0]-p0[0]), float64(p1[1]-p0[1]))
+			if math.Abs(r-separation) > 0.02*separation {
+				t.Fatalf("threads = %v, step %v: separation = %v, want %v", threads, step, r, separation)
+			}
+		}
+	}
+}
+
+/*
+ * Running fewer bodies than threads, as absorption and the editor can leave, keeps the number of threads
+ * that was set for when bodies are added back
+ */
+func TestFewerBodiesThanThreads(t *testing.T) {
+
+	input := "{\"Command\":\"ADD\",\"Id\":0,\"Mass\":1,\"Position\":[200,250],\"Velocity\":[0,0]}\n" +
+		"{\"Command\":\"ADD\",\"Id\":1,\"Mass\":1,\"Position\":[300,250],\"Velocity\":[0,0]}\n"
+
+	setup(500, 500, 8)
+	if result := run(t, []byte(input), 5, 8); len(result) != 2 || ThreadCount != 8 {
+		t.Errorf("console run left %v bodies and %v threads, want 2 and 8", len(result), ThreadCount)
+	}
+
+	setup(500, 500, 8)
+	bodies := []phys.Body{phys.NewBody(1, 0, rl.NewVector2(200, 250), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 1, rl.NewVector2(300, 250), rl.NewVector2(0, 0))}
+	cTree := make(chan Solver)
+	bodies = guiParallel(bodies, buildSolver(bodies), cTree, false)
+	<-cTree
+	if len(bodies) != 2 || ThreadCount != 8 {
+		t.Errorf("GUI step left %v bodies and %v threads, want 2 and 8", len(bodies), ThreadCount)
+	}
+}
+
+/*
+ * A Plummer sphere matches the stored golden trajectories
+ * with the sequential driver and the parallel driver with any thread count
+ */
+func TestGoldenPlummer(t *testing.T) {
+
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden := filepath.Join("testdata", "plummer.golden.json")
+	const steps = 100
+
+	if *update {
+		setup(500, 500, 0)
+		data, _ := json.Marshal(run(t, input, steps, 0))
+		if err := ioutil.WriteFile(golden, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []trajectory
+	if err := json.Unmarshal(data, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, threads := range []int{0, 1, 2, 3, 8} {
+		setup(500, 500, threads)
+		got := run(t, input, steps, threads)
+
+		if len(got) != len(want) {
+			t.Fatalf("threads = %v: %v bodies, want %v", threads, len(got), len(want))
+		}
+
+		for i := range want {
+			if got[i].Id != want[i].Id || len(got[i].Position) != len(want[i].Position) {
+				t.Fatalf("threads = %v: body %v has the wrong Id or number of steps", threads, i)
+			}
+			for step := range want[i].Position {
+				g, w := got[i].Position[step], want[i].Position[step]
+				if math.Abs(float64(g[0]-w[0])) > 1e-3 || math.Abs(float64(g[1]-w[1])) > 1e-3 {
+					t.Fatalf("threads = %v: body %v at step %v is %v, want %v", threads, i, step, g, w)
+				}
+			}
+		}
+	}
+}
+
+/*
+ * In deterministic mode the parallel driver writes exactly the positions of the sequential driver
+ * with any thread count and solver
+ */
+func TestDeterministicDrivers(t *testing.T) {
+
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const steps = 50
+
+	for _, solver := range []string{TreeSolver, MeshSolver, TreePMSolver} {
+		boundary := phys.Periodic
+		if solver == TreeSolver {
+			boundary = phys.Open
+		}
+		setup(500, 500, 0)
+		SolverType, BoundaryMode = solver, boundary
+		want := run(t, input, steps, 0)
+
+		for threads := 1; threads <= 8; threads++ {
+			setup(500, 500, threads)
+			SolverType, BoundaryMode = solver, boundary
+			got := run(t, input, steps, threads)
+
+			if len(got) != len(want) {
+				t.Fatalf("%v, threads = %v: %v bodies, want %v", solver, threads, len(got), len(want))
+			}
+			for i := range want {
+				if got[i].Id != want[i].Id || len(got[i].Position) != len(want[i].Position) {
+					t.Fatalf("%v, threads = %v: body %v has the wrong Id or number of steps", solver, threads, i)
+				}
+				for step := range want[i].Position {
+					if g, w := got[i].Position[step], want[i].Position[step]; g[0] != w[0] || g[1] != w[1] {
+						t.Fatalf("%v, threads = %v: body %v at step %v is %v, want %v", solver, threads, i, step, g, w)
+					}
+				}
+			}
+		}
+	}
+}
+
+/*
+ * Without deterministic mode the tree is built in whatever order the threads finish,
+ * which only changes the rounding of the positions
+ */
+func TestNondeterministicParallel(t *testing.T) {
+
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const steps = 20
+
+	setup(500, 500, 0)
+	want := run(t, input, steps, 0)
+
+	for _, threads := range []int{2, 4, 8} {
+		setup(500, 500, threads)
+		Deterministic = false
+		got := run(t, input, steps, threads)
+
+		if len(got) != len(want) {
+			t.Fatalf("threads = %v: %v bodies, want %v", threads, len(got), len(want))
+		}
+		for i := range want {
+			if got[i].Id != want[i].Id || len(got[i].Position) != len(want[i].Position) {
+				t.Fatalf("threads = %v: body %v has the wrong Id or number of steps", threads, i)
+			}
+			for step := range want[i].Position {
+				g, w := got[i].Position[step], want[i].Position[step]
+				if math.Abs(float64(g[0]-w[0])) > 1e-2 || math.Abs(float64(g[1]-w[1])) > 1e-2 {
+					t.Fatalf("threads = %v: body %v at step %v is %v, want %v", threads, i, step, g, w)
+				}
+			}
+		}
+	}
+}
+
+/*
+ * The engine used by the server follows the golden trajectories of the drivers with any number of workers
+ */
+func TestEngineMatchesGolden(t *testing.T) {
+
+	setup(500, 500, 0)
+	initial, err := readInitial(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	golden, err := readTrajectory(filepath.Join("testdata", "plummer.golden.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 3} {
+		sim, err := engine.New(engine.NewConfig(500, 500), golden.Bodies(0, initial, Dt))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for step := 1; step < golden.Steps(); step++ {
+			sim.Advance(workers)
+			want := golden.Bodies(step, initial, Dt)
+			for i := range want {
+				if sim.Bodies[i].Position != want[i].Position {
+					t.Fatalf("workers = %v: body %v at step %v is %v, want %v",
+						workers, i, step, sim.Bodies[i].Position, want[i].Position)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"io"
+	"os"
+	"proj3/phys"
+	"proj3/render"
+	"proj3/traj"
+	"strconv"
+	"unicode"
+)
+
+const svgUsage = "Usage: ./sim svg [-o=FILE] [-input=FILE] [-step=INTEGER] [-dt=FLOAT] [-colour=NAME]\n" +
+	"\t [-style=outline|filled|additive] [-scale=FLOAT] [-minradius=FLOAT] [-velocity=FLOAT] [-cells] [-legend]\n" +
+	"\t [-stroke=FLOAT] [-background=COLOUR] [-cellcolour=COLOUR] [-arrowcolour=COLOUR] <state> <X> <Y>\n" +
+	"\t -o = File to write the SVG into. Defaults to Stdout.\n" +
+	"\t -input = Input file a trajectory started from, for the masses and components of the bodies.\n" +
+	"\t -step = Time-step of a trajectory to draw. Defaults to the last.\n" +
+	"\t -dt = Timestep of the run the trajectory came from, for the velocities. Defaults to 0.4.\n" +
+	"\t -colour, -style = As for a live run.\n" +
+	"\t -scale = Factor to draw the radius of each body at. Defaults to 1.\n" +
+	"\t -minradius = Smallest radius to draw a body at.\n" +
+	"\t -velocity = Length of the velocity arrows for each unit of speed. Defaults to 0, no arrows.\n" +
+	"\t -cells = Draw the Barnes-Hut tree cells.\n" +
+	"\t -legend = Draw the colour legend.\n" +
+	"\t -stroke = Width of the lines. Defaults to 1.\n" +
+	"\t -background, -cellcolour, -arrowcolour = SVG colours of the background (or none), tree cells and arrows.\n" +
+	"\t <state> = Input file, TIPSY or Gadget snapshot, or the output of a console run.\n" +
+	"\t <X> = The width of the window. Positive Integer.\n" +
+	"\t <Y> = The height of the window. Positive Integer."
+
+/*
+ * Write an SVG snapshot of a state from the command line
+ *
+ * args: command line arguments after "svg"
+ */
+func svgMode(args []string) {
+
+	opts := render.NewSVGOptions()
+	flags := flag.NewFlagSet("svg", flag.ExitOnError)
+	outPtr := flags.String("o", "", "File to write the SVG into.")
+	inputPtr := flags.String("input", "", "Input file the trajectory started from.")
+	stepPtr := flags.Int("step", -1, "Time-step of the trajectory to draw. -1 draws the last.")
+	dtPtr := flags.Float64("dt", phys.DefaultDt, "Timestep of the run.")
+	colourPtr := flags.String("colour", render.Plain.String(), "Colour map: none, speed, mass, energy, density or component.")
+	stylePtr := flags.String("style", render.Outline.String(), "Drawing style: outline, filled or additive.")
+	flags.Float64Var(&opts.RadiusScale, "scale", opts.RadiusScale, "Factor to draw the radius of each body at.")
+	flags.Float64Var(&opts.MinRadius, "minradius", opts.MinRadius, "Smallest radius to draw a body at.")
+	flags.Float64Var(&opts.Velocity, "velocity", opts.Velocity, "Length of the velocity arrows for each unit of speed.")
+	cellsPtr := flags.Bool("cells", false, "Draw the tree cells.")
+	flags.BoolVar(&opts.Legend, "legend", opts.Legend, "Draw the colour legend.")
+	flags.Float64Var(&opts.Stroke, "stroke", opts.Stroke, "Width of the lines.")
+	flags.StringVar(&opts.Background, "background", opts.Background, "Colour of the background.")
+	flags.StringVar(&opts.CellColour, "cellcolour", opts.CellColour, "Colour of the tree cells.")
+	flags.StringVar(&opts.ArrowColour, "arrowcolour", opts.ArrowColour, "Colour of the velocity arrows.")
+	flags.Usage = func() { fmt.Println(svgUsage) }
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 3 {
+		fmt.Println(svgUsage)
+		os.Exit(0)
+	}
+
+	WindowWidth, _ = strconv.Atoi(flags.Arg(1))
+	WindowHeight, _ = strconv.Atoi(flags.Arg(2))
+	if WindowWidth <= 0 || WindowHeight <= 0 || *dtPtr <= 0 || opts.RadiusScale < 0 || opts.MinRadius < 0 ||
+		opts.Velocity < 0 || opts.Stroke <= 0 {
+		fmt.Printf("X, Y, dt and stroke must be greater than 0, and scale, minradius and velocity must not be negative. "+
+			"Not [%v, %v, %v, %v, %v, %v, %v]\n", WindowWidth, WindowHeight, *dtPtr, opts.Stroke,
+			opts.RadiusScale, opts.MinRadius, opts.Velocity)
+		fmt.Println(svgUsage)
+		os.Exit(0)
+	}
+	Dt = float32(*dtPtr)
+
+	var err error
+	if opts.Colours, err = render.ParseColourMap(*colourPtr); err == nil {
+		opts.Style, err = render.ParseStyle(*stylePtr)
+	}
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(svgUsage)
+		os.Exit(0)
+	}
+	opts.Tags = Tags
+
+	var initial map[int]phys.Body
+	if *inputPtr != "" {
+		initial, err = readInitial(*inputPtr)
+	}
+	var bodies []phys.Body
+	if err == nil {
+		bodies, err = readState(flags.Arg(0), *stepPtr, initial)
+	}
+	if err == nil {
+		var cells []rl.Rectangle
+		if *cellsPtr {
+			cells = solverCells(buildSolver(bodies))
+		}
+		err = writeFile(*outPtr, func(w io.Writer) error {
+			return render.WriteSVG(w, bodies, cells, windowBox(), opts)
+		})
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+/*
+ * Read the bodies of a state, either an input file, a TIPSY or Gadget snapshot, or the JSON or binary output
+ * of a console run at a time-step
+ *
+ * path: file holding the state
+ * step: Integer - time-step of a console run to read. -1 reads the last
+ * initial: bodies the run started with by Id. May be nil
+ *
+ * return: slice of physics bodies, and an error if they could not be read
+ */
+func readState(path string, step int, initial map[int]phys.Body) ([]phys.Body, error) {
+
+	// Binary trajectories are read at the one frame, using the timestep of their header
+	if r, err := traj.Open(path); err != traj.ErrNotBinary {
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		if step < 0 {
+			step = r.Header.Frames - 1
+		}
+		return r.Bodies(step, initial)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Console output is a JSON array, while input files are a stream of objects and snapshots are read as one
+	in, err := inputReader(f)
+	if err != nil {
+		return nil, err
+	}
+	first, err := peekRune(in)
+	if err != nil {
+		return nil, err
+	}
+
+	if first != '[' {
+		bodies := make([]phys.Body, 0)
+		readData(&bodies, nil, nil, json.NewDecoder(in), nil, nil)
+		return bodies, nil
+	}
+
+	trajectory, err := traj.ReadJSON(in)
+	if err != nil {
+		return nil, err
+	}
+	if step < 0 {
+		step = trajectory.Steps() - 1
+	}
+	if step >= trajectory.Steps() {
+		return nil, fmt.Errorf("step must be less than the %v steps of %v. Not [%v]", trajectory.Steps(), path, step)
+	}
+
+	return trajectory.Bodies(step, initial, Dt), nil
+}
+
+/*
+ * Return the first rune of a reader that is not white space, without reading past it
+ */
+func peekRune(in *bufio.Reader) (rune, error) {
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(r) {
+			return r, in.UnreadRune()
+		}
+	}
+}
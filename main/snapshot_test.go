@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"proj3/traj"
+	"testing"
+)
+
+/*
+ * States are read from input files and from any time-step of a trajectory
+ */
+func TestReadState(t *testing.T) {
+
+	setup(500, 500, 0)
+	initial, err := readInitial(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input, err := readState(filepath.Join("testdata", "plummer.txt"), -1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, err := readState(filepath.Join("testdata", "plummer.golden.json"), 0, initial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(input) != len(initial) || len(start) != len(initial) {
+		t.Fatalf("read %v and %v bodies, want %v", len(input), len(start), len(initial))
+	}
+	for i := range start {
+		if start[i] != initial[start[i].Id] || input[i] != initial[input[i].Id] {
+			t.Errorf("body %v read as %+v and %+v, want %+v", i, input[i], start[i], initial[start[i].Id])
+		}
+	}
+
+	if _, err := readState(filepath.Join("testdata", "plummer.golden.json"), 100000, initial); err == nil {
+		t.Error("readState accepted a step past the end of the trajectory")
+	}
+}
+
+/*
+ * A binary trajectory of a console run holds the same states as its JSON output
+ */
+func TestBinaryOutput(t *testing.T) {
+
+	setup(500, 500, 2)
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	parallel(20, bytes.NewReader(input), &out)
+
+	OutputFormat = BinaryFormat
+	defer func() { OutputFormat = JSONFormat }()
+	var binary bytes.Buffer
+	parallel(20, bytes.NewReader(input), &binary)
+
+	r, err := traj.NewReader(bytes.NewReader(binary.Bytes()), int64(binary.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Frames != 21 || r.Header.Width != 500 || float32(r.Header.Dt) != Dt {
+		t.Errorf("header = %+v", r.Header)
+	}
+
+	trajectory, err := r.Trajectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var converted bytes.Buffer
+	if err := trajectory.WriteJSON(&converted); err != nil {
+		t.Fatal(err)
+	}
+	if converted.String() != out.String() {
+		t.Error("binary output converted to JSON differs from the JSON output")
+	}
+}
+
+/*
+ * The npy output of a console run holds the positions of its binary trajectory, and the csv output a row for each of them
+ */
+func TestTableOutput(t *testing.T) {
+
+	setup(500, 500, 2)
+	input, err := ioutil.ReadFile(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { OutputFormat = JSONFormat }()
+	output := make(map[string][]byte)
+	for _, format := range []string{BinaryFormat, CSVFormat, NPYFormat} {
+		OutputFormat = format
+		var out bytes.Buffer
+		parallel(20, bytes.NewReader(input), &out)
+		output[format] = out.Bytes()
+	}
+
+	r, err := traj.NewReader(bytes.NewReader(output[BinaryFormat]), int64(len(output[BinaryFormat])))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := len(r.Header.Ids)
+	columns := len(traj.Columns(traj.TableFields))
+
+	npy := output[NPYFormat]
+	data := 10 + int(binary.LittleEndian.Uint16(npy[8:]))
+	if want := data + 4*21*n*columns; len(npy) != want {
+		t.Fatalf("npy output is %v bytes, not %v", len(npy), want)
+	}
+	rows := 1
+	for k := 0; k < r.Header.Frames; k++ {
+		frame, err := r.Frame(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, pos := range frame {
+			offset := data + 4*columns*(k*n+i)
+			x := math.Float32frombits(binary.LittleEndian.Uint32(npy[offset:]))
+			y := math.Float32frombits(binary.LittleEndian.Uint32(npy[offset+4:]))
+			if !(x == pos.X && y == pos.Y) && !(math.IsNaN(float64(x)) && math.IsNaN(float64(pos.X))) {
+				t.Fatalf("frame %v body %v: npy (%v, %v), binary %v", k, i, x, y, pos)
+			}
+			if !math.IsNaN(float64(pos.X)) {
+				rows++
+			}
+		}
+	}
+
+	if lines := bytes.Count(output[CSVFormat], []byte("\n")); lines != rows {
+		t.Errorf("csv output has %v lines, not %v", lines, rows)
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"proj3/diag"
+	"proj3/engine"
+	"proj3/phys"
+	"proj3/qtree"
+	"runtime"
+	"strconv"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const sweepUsage = "Usage: ./sim sweep [-cpus=INTEGER] [-json=FILE] [-csv=FILE] <SPEC>\n" +
+	"\t -cpus = Number of goroutines all runs together calculate the forces with. Defaults to the number of CPUs.\n" +
+	"\t -json = File to write the summary into as JSON.\n" +
+	"\t -csv = File to write the summary into as CSV.\n" +
+	"\t <SPEC> = JSON file of the sweep. Every combination of its Theta, Dt, Softening and Threads lists is run."
+
+// Sweep specification. Every combination of the lists is run from the same initial conditions,
+// and an empty list runs only the default
+type sweepSpec struct {
+	Input     string    // Input file of the initial conditions
+	Width     int       // Width of the box
+	Height    int       // Height of the box
+	Steps     int       // Number of time-steps in each run
+	Solver    string    // Gravity solver: tree, pm or treepm. Defaults to tree
+	Grid      int       // Number of mesh cells per side for pm and treepm. Defaults to 64
+	Boundary  string    // Boundary condition. Defaults to open
+	Theta     []float32 // Opening angles of the tree. Defaults to 0.8
+	Dt        []float32 // Timesteps. Defaults to 0.4
+	Softening []float32 // Plummer softening lengths. Defaults to 0
+	Threads   []int     // Number of goroutines each run calculates the forces with. Defaults to 1
+}
+
+// One combination of a sweep
+type sweepJob struct {
+	Config  engine.Config
+	Threads int
+}
+
+// Summary of the diagnostics of one combination of a sweep
+type sweepResult struct {
+	Theta          float32
+	Dt             float32
+	Softening      float32
+	Threads        int
+	Steps          int
+	Bodies         int     // Number of bodies left at the end
+	EnergyDrift    float64 // Relative change of the total energy from the start to the end
+	MaxEnergyDrift float64 // Largest relative change of the total energy, checked every diag.DefaultInterval steps
+	VirialRatio    float64 // Virial ratio at the end
+	Runtime        float64 // Seconds spent stepping, not counting the diagnostics
+}
+
+/*
+ * Read a sweep specification, filling in the defaults
+ */
+func readSweepSpec(r io.Reader) (sweepSpec, error) {
+
+	spec := sweepSpec{Solver: engine.TreeSolver}
+	if err := json.NewDecoder(r).Decode(&spec); err != nil {
+		return spec, err
+	}
+
+	if spec.Boundary == "" {
+		spec.Boundary = defaultBoundary(spec.Solver)
+	}
+
+	if len(spec.Theta) == 0 {
+		spec.Theta = []float32{qtree.DefaultTheta}
+	}
+	if len(spec.Dt) == 0 {
+		spec.Dt = []float32{phys.DefaultDt}
+	}
+	if len(spec.Softening) == 0 {
+		spec.Softening = []float32{0}
+	}
+	if len(spec.Threads) == 0 {
+		spec.Threads = []int{1}
+	}
+
+	return spec, nil
+}
+
+/*
+ * Return every combination of the specification, varying the threads fastest and the theta slowest
+ */
+func (spec sweepSpec) jobs() ([]sweepJob, error) {
+
+	if spec.Steps <= 0 {
+		return nil, fmt.Errorf("steps must be greater than 0. Not [%v]", spec.Steps)
+	}
+	boundary, err := phys.ParseBoundary(spec.Boundary)
+	if err != nil {
+		return nil, err
+	}
+
+	config := engine.NewConfig(spec.Width, spec.Height)
+	config.Solver, config.Boundary = spec.Solver, boundary
+	if spec.Grid != 0 {
+		config.Grid = spec.Grid
+	}
+
+	jobs := make([]sweepJob, 0)
+	for _, theta := range spec.Theta {
+		for _, dt := range spec.Dt {
+			for _, softening := range spec.Softening {
+				for _, threads := range spec.Threads {
+					config.Theta, config.Dt, config.Softening = theta, dt, softening
+					if err := config.Check(); err != nil {
+						return nil, err
+					}
+					if threads <= 0 {
+						return nil, fmt.Errorf("threads must be greater than 0. Not [%v]", threads)
+					}
+					jobs = append(jobs, sweepJob{config, threads})
+				}
+			}
+		}
+	}
+
+	return jobs, nil
+}
+
+/*
+ * Run one combination of a sweep
+ *
+ * job: settings and number of goroutines of the run
+ * bodies: initial conditions, which are copied
+ * steps: number of time-steps to take
+ */
+func sweepRun(job sweepJob, bodies []phys.Body, steps int) sweepResult {
+
+	sim, _ := engine.New(job.Config, bodies)
+	start := sim.Diagnostics()
+
+	drift := func(d diag.Diagnostics) float64 {
+		if start.Energy == 0 {
+			return 0
+		}
+		return math.Abs((d.Energy - start.Energy) / start.Energy)
+	}
+
+	result := sweepResult{Theta: job.Config.Theta, Dt: job.Config.Dt, Softening: job.Config.Softening,
+		Threads: job.Threads, Steps: steps}
+
+	var elapsed time.Duration
+	for sim.Step < steps {
+		began := time.Now()
+		sim.Advance(job.Threads)
+		elapsed += time.Since(began)
+
+		if sim.Step%diag.DefaultInterval == 0 && sim.Step < steps {
+			result.MaxEnergyDrift = math.Max(result.MaxEnergyDrift, drift(sim.Diagnostics()))
+		}
+	}
+
+	end := sim.Diagnostics()
+	result.Bodies = end.Bodies
+	result.EnergyDrift = drift(end)
+	result.MaxEnergyDrift = math.Max(result.MaxEnergyDrift, result.EnergyDrift)
+	result.VirialRatio = end.VirialRatio
+	result.Runtime = elapsed.Seconds()
+
+	return result
+}
+
+/*
+ * Run every combination of a sweep, as many at once as the goroutine budget allows
+ * The runs are started in order, each once enough of the budget is free for its threads.
+ * A run with more threads than the whole budget waits for it all
+ *
+ * jobs: combinations to run
+ * bodies: initial conditions shared by every run
+ * steps: number of time-steps in each run
+ * cpus: number of goroutines all runs together calculate the forces with
+ * progress: called after each run finishes. May be nil
+ *
+ * return: the results in the order of the jobs
+ */
+func sweep(jobs []sweepJob, bodies []phys.Body, steps, cpus int, progress func(sweepResult)) []sweepResult {
+
+	results := make([]sweepResult, len(jobs))
+	free := cpus
+	var mtx sync.Mutex
+	released := sync.NewCond(&mtx)
+	var wg sync.WaitGroup
+
+	for i := range jobs {
+		n := jobs[i].Threads
+		if n > cpus {
+			n = cpus
+		}
+
+		mtx.Lock()
+		for free < n {
+			released.Wait()
+		}
+		free -= n
+		mtx.Unlock()
+
+		wg.Add(1)
+		go func(i, n int) {
+			defer wg.Done()
+			results[i] = sweepRun(jobs[i], bodies, steps)
+
+			mtx.Lock()
+			free += n
+			if progress != nil {
+				progress(results[i])
+			}
+			mtx.Unlock()
+			released.Broadcast()
+		}(i, n)
+	}
+	wg.Wait()
+
+	return results
+}
+
+/*
+ * Write the results as an aligned table with one row for each combination
+ */
+func writeSweepTable(w io.Writer, results []sweepResult) error {
+
+	out := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(out, "theta\tdt\tsoftening\tthreads\tsteps\tbodies\tenergy drift\tmax drift\tvirial ratio\truntime (s)\t")
+	for _, r := range results {
+		fmt.Fprintf(out, "%v\t%v\t%v\t%v\t%v\t%v\t%.3e\t%.3e\t%.4f\t%.3f\t\n", r.Theta, r.Dt, r.Softening,
+			r.Threads, r.Steps, r.Bodies, r.EnergyDrift, r.MaxEnergyDrift, r.VirialRatio, r.Runtime)
+	}
+
+	return out.Flush()
+}
+
+/*
+ * Write the results as CSV with one row for each combination
+ */
+func writeSweepCSV(w io.Writer, results []sweepResult) error {
+
+	out := csv.NewWriter(w)
+	_ = out.Write([]string{"theta", "dt", "softening", "threads", "steps", "bodies",
+		"energy_drift", "max_energy_drift", "virial_ratio", "runtime"})
+
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 64) }
+	f32 := func(v float32) string { return strconv.FormatFloat(float64(v), 'g', -1, 32) }
+	for _, r := range results {
+		_ = out.Write([]string{f32(r.Theta), f32(r.Dt), f32(r.Softening), strconv.Itoa(r.Threads),
+			strconv.Itoa(r.Steps), strconv.Itoa(r.Bodies), f(r.EnergyDrift), f(r.MaxEnergyDrift),
+			f(r.VirialRatio), f(r.Runtime)})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+/*
+ * Run a parameter sweep from the command line
+ *
+ * args: command line arguments after "sweep"
+ */
+func sweepMode(args []string) {
+
+	flags := flag.NewFlagSet("sweep", flag.ExitOnError)
+	cpusPtr := flags.Int("cpus", runtime.NumCPU(), "Number of goroutines all runs together calculate the forces with.")
+	jsonPtr := flags.String("json", "", "File to write the JSON summary into.")
+	csvPtr := flags.String("csv", "", "File to write the CSV summary into.")
+	flags.Usage = func() { fmt.Println(sweepUsage) }
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 || *cpusPtr <= 0 {
+		fmt.Println(sweepUsage)
+		os.Exit(0)
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	spec, err := readSweepSpec(f)
+	f.Close()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	jobs, err := spec.jobs()
+	if err != nil {
+		fmt.Println(err)
+		fmt.Println(sweepUsage)
+		os.Exit(0)
+	}
+
+	initial, err := readInitial(spec.Input)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	bodies := make([]phys.Body, 0, len(initial))
+	for _, b := range initial {
+		bodies = append(bodies, b)
+	}
+
+	done := 0
+	results := sweep(jobs, bodies, spec.Steps, *cpusPtr, func(r sweepResult) {
+		done++
+		fmt.Fprintf(os.Stderr, "Finished %v of %v: theta %v, dt %v, softening %v, %v threads\n",
+			done, len(jobs), r.Theta, r.Dt, r.Softening, r.Threads)
+	})
+
+	err = writeSweepTable(os.Stdout, results)
+	if err == nil && *jsonPtr != "" {
+		err = writeFile(*jsonPtr, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(results)
+		})
+	}
+	if err == nil && *csvPtr != "" {
+		err = writeFile(*csvPtr, func(w io.Writer) error { return writeSweepCSV(w, results) })
+	}
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
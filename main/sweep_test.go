@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"proj3/phys"
+	"proj3/qtree"
+	"strings"
+	"testing"
+)
+
+func TestSweepJobs(t *testing.T) {
+
+	spec, err := readSweepSpec(strings.NewReader(`{"Width": 500, "Height": 500, "Steps": 10,
+		"Dt": [0.2, 0.4], "Softening": [0, 1, 2], "Threads": [1, 4]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jobs, err := spec.jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 12 {
+		t.Fatalf("%v jobs, want 12", len(jobs))
+	}
+
+	// Missing lists take the default, and the threads vary fastest
+	first, last := jobs[0], jobs[11]
+	if first.Config.Theta != qtree.DefaultTheta || first.Config.Dt != 0.2 || first.Config.Softening != 0 ||
+		first.Threads != 1 || jobs[1].Threads != 4 {
+		t.Errorf("first jobs = %+v, %+v", first, jobs[1])
+	}
+	if last.Config.Dt != 0.4 || last.Config.Softening != 2 || last.Threads != 4 || last.Config.Boundary != phys.Open {
+		t.Errorf("last job = %+v", last)
+	}
+
+	// Mesh solvers default to a periodic box as on the command line
+	spec, _ = readSweepSpec(strings.NewReader(`{"Width": 500, "Height": 500, "Steps": 10, "Solver": "pm"}`))
+	if jobs, err = spec.jobs(); err != nil || jobs[0].Config.Boundary != phys.Periodic {
+		t.Errorf("pm jobs = %+v, %v, want a periodic boundary", jobs, err)
+	}
+
+	for _, bad := range []string{`{"Width": 500, "Height": 500}`, `{"Width": 500, "Height": 500, "Steps": 1, "Dt": [0]}`,
+		`{"Width": 500, "Height": 500, "Steps": 1, "Threads": [0]}`,
+		`{"Width": 500, "Height": 500, "Steps": 1, "Boundary": "sticky"}`} {
+		spec, _ := readSweepSpec(strings.NewReader(bad))
+		if _, err := spec.jobs(); err == nil {
+			t.Errorf("jobs accepted %v", bad)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+
+	initial, err := readInitial(filepath.Join("testdata", "plummer.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := make([]phys.Body, 0, len(initial))
+	for _, b := range initial {
+		bodies = append(bodies, b)
+	}
+
+	spec, _ := readSweepSpec(strings.NewReader(`{"Width": 500, "Height": 500, "Steps": 20,
+		"Theta": [0.5, 1], "Softening": [0, 5], "Threads": [1, 3]}`))
+	jobs, err := spec.jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	finished := 0
+	results := sweep(jobs, bodies, spec.Steps, 4, func(sweepResult) { finished++ })
+	if finished != len(jobs) {
+		t.Errorf("progress called %v times, want %v", finished, len(jobs))
+	}
+
+	for i, r := range results {
+		if r.Theta != jobs[i].Config.Theta || r.Softening != jobs[i].Config.Softening || r.Threads != jobs[i].Threads {
+			t.Errorf("result %v = %+v is not of job %+v", i, r, jobs[i])
+		}
+		if r.Steps != 20 || r.Bodies != 64 || r.VirialRatio <= 0 || r.MaxEnergyDrift < r.EnergyDrift {
+			t.Errorf("unexpected result %+v", r)
+		}
+	}
+
+	// The number of threads does not change the trajectory
+	for i := 0; i < len(results); i += 2 {
+		if results[i].EnergyDrift != results[i+1].EnergyDrift || results[i].VirialRatio != results[i+1].VirialRatio {
+			t.Errorf("results %v and %v differ only in threads but have drift %v and %v", i, i+1,
+				results[i].EnergyDrift, results[i+1].EnergyDrift)
+		}
+	}
+
+	// One CSV header and a row for each result
+	var out bytes.Buffer
+	if err := writeSweepCSV(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil || len(rows) != len(results)+1 || rows[8][0] != "1" || rows[8][2] != "5" {
+		t.Errorf("CSV rows = %v, %v", rows, err)
+	}
+
+	out.Reset()
+	if err := writeSweepTable(&out, results); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != len(results)+1 {
+		t.Errorf("table has %v lines, want %v", len(lines), len(results)+1)
+	}
+}
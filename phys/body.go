@@ -9,7 +9,7 @@ import (
 // Constants
 const G = 1              // Gravitational force - Not being realistic
 const MaxDistance = 2500 // Added so numbers don't blow up
-const dt = 0.4           // Timestep to multiply force and velocity with
+const DefaultDt = 0.4    // Default timestep to multiply force and velocity with
 const RadiusCoeff = 3
 
 // Body is a physics body which can be affected by gravity
@@ -35,19 +35,36 @@ func NewBody(mass float32, id int, pos, vel rl.Vector2) Body {
  * Adds the force due to the other body
  */
 func (b *Body) AddForce(other *Body) {
+	b.AddForceAt(other, raymath.Vector2Subtract(other.Position, b.Position))
+}
 
-	distance := raymath.Vector2Distance(b.Position, other.Position)
+/*
+ * Adds the force due to the other body at separation d
+ * Used when the separation is not simply the difference in positions,
+ * such as the nearest image in a periodic box
+ */
+func (b *Body) AddForceAt(other *Body, d rl.Vector2) {
+	b.AddSoftenedForceAt(other, d, 0)
+}
 
-	// Clamp the distance between the larger object's radius and MaxDistance
-	distance = raymath.Clamp(distance, float32(math.Max(float64(b.Radius),
-		float64(other.Radius))), MaxDistance)
+/*
+ * Adds the force due to the other body at separation d, Plummer softened
+ * The softening length weakens the force between bodies closer than it, as if they were spread out
+ *
+ * other: body applying the force
+ * d: separation from this body to the other body
+ * softening: softening length. 0 for the unsoftened force of AddForceAt
+ */
+func (b *Body) AddSoftenedForceAt(other *Body, d rl.Vector2, softening float32) {
+
+	distance := b.clampDistance(other, raymath.Vector2Length(d))
 
 	// Unit vector pointing in the direction of the applied force
-	force := raymath.Vector2Subtract(other.Position, b.Position)
+	force := d
 	raymath.Vector2Divide(&force, distance)
 
 	// Calculate the strength of the force
-	strength := G * other.Mass / (distance * distance)
+	strength := G * other.Mass / (distance * distance) * soften(distance, softening)
 
 	// Calculate the new force
 	raymath.Vector2Scale(&force, strength)
@@ -57,6 +74,96 @@ func (b *Body) AddForce(other *Body) {
 
 }
 
+/*
+ * Adds the short-range part of the force due to the other body for a
+ * TreePM split of the potential on scale rs
+ *
+ * other: body applying the force
+ * d: separation from this body to the other body
+ * rs: split scale between the long and short range forces
+ * softening: softening length, as for AddSoftenedForceAt
+ */
+func (b *Body) AddShortRangeForce(other *Body, d rl.Vector2, rs, softening float32) {
+
+	distance := b.clampDistance(other, raymath.Vector2Length(d))
+
+	// Unit vector pointing in the direction of the applied force
+	force := d
+	raymath.Vector2Divide(&force, distance)
+
+	// Strength of the force from the erfc(r/2rs)/r part of the potential
+	u := float64(distance / (2 * rs))
+	strength := G * other.Mass / (distance * distance) *
+		float32(math.Erfc(u)+2*u/math.Sqrt(math.Pi)*math.Exp(-u*u)) * soften(distance, softening)
+
+	// Add the force to this object
+	raymath.Vector2Scale(&force, strength)
+	b.Force = raymath.Vector2Add(b.Force, force)
+}
+
+/*
+ * Return the potential energy between this body and the other body at separation d
+ * The distance is clamped the same way as AddForceAt
+ */
+func (b *Body) PotentialEnergy(other *Body, d rl.Vector2) float32 {
+	return b.SoftenedPotentialEnergy(other, d, 0)
+}
+
+/*
+ * Return the Plummer softened potential energy between this body and the other body at separation d
+ *
+ * softening: softening length, as for AddSoftenedForceAt
+ */
+func (b *Body) SoftenedPotentialEnergy(other *Body, d rl.Vector2, softening float32) float32 {
+
+	distance := b.clampDistance(other, raymath.Vector2Length(d))
+	if softening > 0 {
+		distance = float32(math.Sqrt(float64(distance*distance + softening*softening)))
+	}
+
+	return -G * b.Mass * other.Mass / distance
+}
+
+/*
+ * Return the short-range part of the potential energy between this body and the other body for a
+ * TreePM split of the potential on scale rs, the potential of AddShortRangeForce
+ *
+ * softening: softening length, as for SoftenedPotentialEnergy
+ */
+func (b *Body) ShortRangePotentialEnergy(other *Body, d rl.Vector2, rs, softening float32) float32 {
+
+	distance := b.clampDistance(other, raymath.Vector2Length(d))
+	u := float64(distance / (2 * rs))
+	if softening > 0 {
+		distance = float32(math.Sqrt(float64(distance*distance + softening*softening)))
+	}
+
+	return -G * b.Mass * other.Mass / distance * float32(math.Erfc(u))
+}
+
+/*
+ * Return the factor Plummer softening scales the force at a distance by, (r^2 / (r^2 + e^2))^(3/2)
+ * Exactly 1 without softening, so unsoftened forces are unchanged
+ */
+func soften(distance, softening float32) float32 {
+
+	if softening <= 0 {
+		return 1
+	}
+
+	r2 := float64(distance * distance)
+	f := r2 / (r2 + float64(softening*softening))
+	return float32(f * math.Sqrt(f))
+}
+
+/*
+ * Clamp the distance between the larger object's radius and MaxDistance
+ */
+func (b *Body) clampDistance(other *Body, distance float32) float32 {
+	return raymath.Clamp(distance, float32(math.Max(float64(b.Radius),
+		float64(other.Radius))), MaxDistance)
+}
+
 /*
  * Zero out the force
  */
@@ -66,15 +173,15 @@ func (b *Body) ZeroForce() {
 
 /*
  * Apply the force to update this object's position
- * Uses Euler-Cromer method
+ * Uses Euler-Cromer method, which is the leapfrog method with the velocity half a timestep
+ * behind the position. It is time-reversible, see Kick
+ *
+ * dt: timestep. Negative to run backwards in time
  */
-func (b *Body) Update() rl.Vector2 {
-
-	// Multiply force by the timestep
-	raymath.Vector2Scale(&b.Force, dt)
+func (b *Body) Update(dt float32) rl.Vector2 {
 
 	// Update the velocity
-	b.Velocity = raymath.Vector2Add(b.Velocity, b.Force)
+	b.Kick(dt)
 	vel := b.Velocity              // Copy to scale velocity
 	raymath.Vector2Scale(&vel, dt) // Scale it by timestep
 
@@ -85,6 +192,20 @@ func (b *Body) Update() rl.Vector2 {
 
 }
 
+/*
+ * Apply the force to the velocity only, without moving the object
+ * Kicking every body with the force at its current position, then negating dt, makes
+ * the following updates retrace the previous ones backwards in time
+ *
+ * dt: timestep
+ */
+func (b *Body) Kick(dt float32) {
+
+	// Multiply force by the timestep
+	raymath.Vector2Scale(&b.Force, dt)
+	b.Velocity = raymath.Vector2Add(b.Velocity, b.Force)
+}
+
 /*
  * Combine the bodies and return a body representing their Center of Mass (COM)
  * and combined mass and velocity
@@ -103,3 +224,18 @@ func AddBody(b1 Body, b2 Body) Body {
 
 	return NewBody(m, -1, rl.NewVector2(x, y), rl.NewVector2(dx, dy))
 }
+
+/*
+ * Return the shortest periodic image of the separation d in a box of size period
+ */
+func MinimumImage(d rl.Vector2, period rl.Vector2) rl.Vector2 {
+
+	if period.X > 0 {
+		d.X -= period.X * float32(math.Round(float64(d.X/period.X)))
+	}
+	if period.Y > 0 {
+		d.Y -= period.Y * float32(math.Round(float64(d.Y/period.Y)))
+	}
+
+	return d
+}
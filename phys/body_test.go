@@ -0,0 +1,268 @@
+package phys
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/gen2brain/raylib-go/raymath"
+	"math"
+	"testing"
+)
+
+/*
+ * Check that two floats are within tol of each other
+ */
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestNewBody(t *testing.T) {
+
+	b := NewBody(2, 7, rl.NewVector2(1, 2), rl.NewVector2(3, 4))
+
+	if b.Radius != 2*RadiusCoeff {
+		t.Errorf("Radius = %v, want %v", b.Radius, 2*RadiusCoeff)
+	}
+	if b.Id != 7 || b.Position != rl.NewVector2(1, 2) || b.Velocity != rl.NewVector2(3, 4) {
+		t.Errorf("NewBody did not keep its inputs: %+v", b)
+	}
+	if b.Force != rl.NewVector2(0, 0) {
+		t.Errorf("Force = %v, want zero", b.Force)
+	}
+}
+
+func TestAddBody(t *testing.T) {
+
+	b1 := NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(2, 0))
+	b2 := NewBody(3, 1, rl.NewVector2(4, 8), rl.NewVector2(0, -2))
+	com := AddBody(b1, b2)
+
+	if com.Mass != 4 || com.Id != -1 {
+		t.Errorf("Mass, Id = %v, %v, want 4, -1", com.Mass, com.Id)
+	}
+	if com.Position != rl.NewVector2(3, 6) {
+		t.Errorf("Position = %v, want [3, 6]", com.Position)
+	}
+	if com.Velocity != rl.NewVector2(0.5, -1.5) {
+		t.Errorf("Velocity = %v, want [0.5, -1.5]", com.Velocity)
+	}
+}
+
+func TestAddForce(t *testing.T) {
+
+	b := NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(0, 0))
+	other := NewBody(2, 1, rl.NewVector2(0, 100), rl.NewVector2(0, 0))
+	b.AddForce(&other)
+
+	// Inverse square law towards the other body
+	if !near(float64(b.Force.X), 0, 1e-9) || !near(float64(b.Force.Y), G*2.0/(100*100), 1e-9) {
+		t.Errorf("Force = %v, want [0, %v]", b.Force, G*2.0/(100*100))
+	}
+
+	// Distances inside the larger radius are clamped to it
+	nearby := NewBody(2, 2, rl.NewVector2(1, 0), rl.NewVector2(0, 0))
+	b.ZeroForce()
+	b.AddForce(&nearby)
+	radius := float64(nearby.Radius)
+	if !near(float64(b.Force.X), G*2/(radius*radius)/radius, 1e-9) {
+		t.Errorf("Clamped force = %v, want [%v, 0]", b.Force, G*2/(radius*radius)/radius)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+
+	b := NewBody(1, 0, rl.NewVector2(10, 20), rl.NewVector2(1, -1))
+	b.Force = rl.NewVector2(0.5, 0.25)
+	pos := b.Update(DefaultDt)
+
+	// Euler-Cromer: the velocity is updated before the position
+	vx, vy := 1+0.5*DefaultDt, -1+0.25*DefaultDt
+	if !near(float64(b.Velocity.X), vx, 1e-6) || !near(float64(b.Velocity.Y), vy, 1e-6) {
+		t.Errorf("Velocity = %v, want [%v, %v]", b.Velocity, vx, vy)
+	}
+	if !near(float64(pos.X), 10+vx*DefaultDt, 1e-5) || !near(float64(pos.Y), 20+vy*DefaultDt, 1e-5) {
+		t.Errorf("Position = %v, want [%v, %v]", pos, 10+vx*DefaultDt, 20+vy*DefaultDt)
+	}
+	if pos != b.Position {
+		t.Errorf("Update returned %v, but the body is at %v", pos, b.Position)
+	}
+}
+
+func TestPotentialEnergy(t *testing.T) {
+
+	b := NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(0, 0))
+	other := NewBody(2, 1, rl.NewVector2(30, 40), rl.NewVector2(0, 0))
+
+	if w := b.PotentialEnergy(&other, rl.NewVector2(30, 40)); !near(float64(w), -G*2.0/50, 1e-7) {
+		t.Errorf("PotentialEnergy = %v, want %v", w, -G*2.0/50)
+	}
+}
+
+func TestSoftening(t *testing.T) {
+
+	b := NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(0, 0))
+	other := NewBody(2, 1, rl.NewVector2(30, 40), rl.NewVector2(0, 0))
+	d := rl.NewVector2(30, 40)
+
+	// No softening is exactly the unsoftened force
+	plain, soft := b, b
+	plain.AddForceAt(&other, d)
+	soft.AddSoftenedForceAt(&other, d, 0)
+	if plain.Force != soft.Force {
+		t.Errorf("Force with no softening = %v, want %v", soft.Force, plain.Force)
+	}
+
+	// Plummer softening: G m r / (r^2 + e^2)^(3/2)
+	soft.ZeroForce()
+	soft.AddSoftenedForceAt(&other, d, 50)
+	want := G * 2.0 * 50 / math.Pow(50*50+50*50, 1.5)
+	if !near(float64(raymath.Vector2Length(soft.Force)), want, 1e-9) {
+		t.Errorf("Softened force = %v, want a length of %v", raymath.Vector2Length(soft.Force), want)
+	}
+
+	if w := b.SoftenedPotentialEnergy(&other, d, 50); !near(float64(w), -G*2.0/math.Sqrt(2*50*50), 1e-7) {
+		t.Errorf("SoftenedPotentialEnergy = %v, want %v", w, -G*2.0/math.Sqrt(2*50*50))
+	}
+	if w := b.SoftenedPotentialEnergy(&other, d, 0); w != b.PotentialEnergy(&other, d) {
+		t.Errorf("PotentialEnergy with no softening = %v, want %v", w, b.PotentialEnergy(&other, d))
+	}
+}
+
+func TestMinimumImage(t *testing.T) {
+
+	period := rl.NewVector2(100, 50)
+
+	if d := MinimumImage(rl.NewVector2(90, -40), period); !near(float64(d.X), -10, 1e-5) || !near(float64(d.Y), 10, 1e-5) {
+		t.Errorf("MinimumImage = %v, want [-10, 10]", d)
+	}
+	if d := MinimumImage(rl.NewVector2(90, -40), rl.NewVector2(0, 0)); d != rl.NewVector2(90, -40) {
+		t.Errorf("MinimumImage without a period = %v, want [90, -40]", d)
+	}
+}
+
+func TestApplyBoundary(t *testing.T) {
+
+	box := rl.NewRectangle(0, 0, 100, 100)
+
+	b := NewBody(1, 0, rl.NewVector2(105, -5), rl.NewVector2(2, -3))
+	if !b.ApplyBoundary(Reflective, box) || b.Position != rl.NewVector2(95, 5) || b.Velocity != rl.NewVector2(-2, 3) {
+		t.Errorf("Reflective: %v %v, want [95, 5] [-2, 3]", b.Position, b.Velocity)
+	}
+
+	b = NewBody(1, 0, rl.NewVector2(105, -5), rl.NewVector2(2, -3))
+	if !b.ApplyBoundary(Periodic, box) || b.Position != rl.NewVector2(5, 95) {
+		t.Errorf("Periodic: %v, want [5, 95]", b.Position)
+	}
+
+	b = NewBody(1, 0, rl.NewVector2(105, 50), rl.NewVector2(2, -3))
+	if b.ApplyBoundary(Absorbing, box) {
+		t.Errorf("Absorbing: body outside the box was not absorbed")
+	}
+	if !b.ApplyBoundary(Open, box) || b.Position != rl.NewVector2(105, 50) {
+		t.Errorf("Open: %v, want [105, 50]", b.Position)
+	}
+}
+
+/*
+ * Two equal bodies on a circular orbit around their centre of mass
+ * keep their separation and orbit at the analytic angular velocity
+ */
+func TestTwoBodyCircularOrbit(t *testing.T) {
+
+	const mass = 10
+	const separation = 100.0
+
+	// Each body orbits the centre of mass at half the separation
+	speed := math.Sqrt(G * mass / (2 * separation))
+	omega := speed / (separation / 2)
+	period := 2 * math.Pi / omega
+	steps := int(period / DefaultDt)
+
+	b1 := NewBody(mass, 0, rl.NewVector2(-separation/2, 0), rl.NewVector2(0, float32(-speed)))
+	b2 := NewBody(mass, 1, rl.NewVector2(separation/2, 0), rl.NewVector2(0, float32(speed)))
+
+	for step := 1; step <= steps; step++ {
+		b1.AddForce(&b2)
+		b2.AddForce(&b1)
+		b1.Update(DefaultDt)
+		b2.Update(DefaultDt)
+		b1.ZeroForce()
+		b2.ZeroForce()
+
+		dx := float64(b2.Position.X - b1.Position.X)
+		dy := float64(b2.Position.Y - b1.Position.Y)
+		if r := math.Hypot(dx, dy); !near(r, separation, 0.02*separation) {
+			t.Fatalf("step %v: separation = %v, want %v", step, r, separation)
+		}
+
+		// Compare the phase of the orbit with the analytic solution
+		if step%100 == 0 {
+			phase := math.Atan2(dy, dx)
+			want := math.Remainder(omega*float64(step)*DefaultDt, 2*math.Pi)
+			if !near(math.Remainder(phase-want, 2*math.Pi), 0, 0.05) {
+				t.Fatalf("step %v: phase = %v, want %v", step, phase, want)
+			}
+		}
+	}
+
+	// Momentum is conserved, so the centre of mass stays at the origin
+	com := AddBody(b1, b2)
+	if !near(float64(com.Position.X), 0, 1e-2) || !near(float64(com.Position.Y), 0, 1e-2) {
+		t.Errorf("centre of mass drifted to %v", com.Position)
+	}
+}
+
+/*
+ * Kicking with the force at the current positions and negating the timestep
+ * takes a three-body system back through its earlier positions to the start
+ */
+func TestTimeReversal(t *testing.T) {
+
+	bodies := []Body{
+		NewBody(5, 0, rl.NewVector2(0, 0), rl.NewVector2(0.1, 0)),
+		NewBody(1, 1, rl.NewVector2(80, 0), rl.NewVector2(0, 0.25)),
+		NewBody(2, 2, rl.NewVector2(-30, 60), rl.NewVector2(-0.2, 0.1)),
+	}
+	start := make([]Body, len(bodies))
+	copy(start, bodies)
+
+	forces := func() {
+		for i := range bodies {
+			for j := range bodies {
+				if i != j {
+					bodies[i].AddForce(&bodies[j])
+				}
+			}
+		}
+	}
+
+	const steps = 500
+	dt := float32(DefaultDt)
+	for step := 0; step < steps; step++ {
+		forces()
+		for i := range bodies {
+			bodies[i].Update(dt)
+			bodies[i].ZeroForce()
+		}
+	}
+
+	forces()
+	for i := range bodies {
+		bodies[i].Kick(dt)
+		bodies[i].ZeroForce()
+	}
+	dt = -dt
+
+	for step := 0; step < steps; step++ {
+		forces()
+		for i := range bodies {
+			bodies[i].Update(dt)
+			bodies[i].ZeroForce()
+		}
+	}
+
+	for i := range bodies {
+		d := raymath.Vector2Distance(bodies[i].Position, start[i].Position)
+		if d > 1e-2 {
+			t.Errorf("body %v is %v from its starting position", i, d)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package phys
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+)
+
+// Boundary is the boundary condition applied to bodies after each update
+type Boundary int
+
+// Boundary condition modes
+const (
+	Open       Boundary = iota // Bodies can leave the box
+	Reflective                 // Bodies bounce off the walls of the box
+	Periodic                   // Bodies wrap around to the opposite side of the box
+	Absorbing                  // Bodies are removed when they leave the box
+)
+
+var boundaryNames = []string{"open", "reflective", "periodic", "absorbing"}
+
+/*
+ * Return the name of the boundary condition
+ */
+func (mode Boundary) String() string {
+	if mode < 0 || int(mode) >= len(boundaryNames) {
+		return fmt.Sprintf("Boundary(%d)", int(mode))
+	}
+	return boundaryNames[mode]
+}
+
+/*
+ * Return the boundary condition with the given name
+ */
+func ParseBoundary(name string) (Boundary, error) {
+	for i, n := range boundaryNames {
+		if n == name {
+			return Boundary(i), nil
+		}
+	}
+	return Open, fmt.Errorf("unknown boundary condition %q", name)
+}
+
+/*
+ * Apply the boundary condition to this body
+ *
+ * mode: boundary condition to apply
+ * box: Rectangle the boundary condition applies to
+ *
+ * return: false if the body left the box and was absorbed
+ */
+func (b *Body) ApplyBoundary(mode Boundary, box rl.Rectangle) bool {
+
+	switch mode {
+	case Reflective:
+		b.Position.X, b.Velocity.X = reflect(b.Position.X, b.Velocity.X, box.X, box.X+box.Width)
+		b.Position.Y, b.Velocity.Y = reflect(b.Position.Y, b.Velocity.Y, box.Y, box.Y+box.Height)
+
+	case Periodic:
+		b.Position.X = wrap(b.Position.X, box.X, box.Width)
+		b.Position.Y = wrap(b.Position.Y, box.Y, box.Height)
+
+	case Absorbing:
+		return b.Position.X >= box.X && b.Position.X <= box.X+box.Width &&
+			b.Position.Y >= box.Y && b.Position.Y <= box.Y+box.Height
+	}
+
+	return true
+}
+
+/*
+ * Reflect a coordinate and its velocity off the walls at min and max
+ */
+func reflect(x, v, min, max float32) (float32, float32) {
+
+	if x < min {
+		x = 2*min - x
+		v = float32(math.Abs(float64(v)))
+	} else if x > max {
+		x = 2*max - x
+		v = -float32(math.Abs(float64(v)))
+	}
+
+	// Clamp in case the body moved more than the width of the box
+	return float32(math.Max(math.Min(float64(x), float64(max)), float64(min))), v
+}
+
+/*
+ * Wrap a coordinate into [min, min + length)
+ */
+func wrap(x, min, length float32) float32 {
+
+	x = float32(math.Mod(float64(x-min), float64(length)))
+	if x < 0 {
+		x += length
+	}
+	if x >= length {
+		// Rounding can leave x equal to length
+		x = 0
+	}
+
+	return x + min
+}
@@ -0,0 +1,84 @@
+package pm
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+/*
+ * In-place iterative radix-2 Cooley-Tukey FFT
+ *
+ * data: slice of complex values. Length must be a power of two
+ * inverse: Bool - compute the inverse transform (not normalized)
+ */
+func fft(data []complex128, inverse bool) {
+
+	n := len(data)
+
+	// Reorder the data into bit-reversed order
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+
+		if i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+
+	// Combine the butterflies of increasing size
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(size))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even := data[start+k]
+				odd := data[start+k+size/2] * w
+				data[start+k] = even + odd
+				data[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+/*
+ * In-place 2D FFT of a square grid stored in row-major order
+ *
+ * data: slice of n*n complex values
+ * n: Integer - number of cells per side. Must be a power of two
+ * inverse: Bool - compute the inverse transform (normalized by 1/n^2)
+ */
+func fft2(data []complex128, n int, inverse bool) {
+
+	// Transform each row
+	for j := 0; j < n; j++ {
+		fft(data[j*n:(j+1)*n], inverse)
+	}
+
+	// Transform each column
+	column := make([]complex128, n)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			column[j] = data[j*n+i]
+		}
+		fft(column, inverse)
+		for j := 0; j < n; j++ {
+			data[j*n+i] = column[j]
+		}
+	}
+
+	if inverse {
+		scale := complex(1/float64(n*n), 0)
+		for i := range data {
+			data[i] *= scale
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package pm
+
+import (
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+/*
+ * Return n random complex values with a fixed seed
+ */
+func randomData(n int) []complex128 {
+
+	r := rand.New(rand.NewSource(1))
+	data := make([]complex128, n)
+	for i := range data {
+		data[i] = complex(r.Float64()-0.5, r.Float64()-0.5)
+	}
+
+	return data
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+
+	const n = 16
+	data := randomData(n)
+
+	want := make([]complex128, n)
+	for k := 0; k < n; k++ {
+		for j := 0; j < n; j++ {
+			want[k] += data[j] * cmplx.Rect(1, -2*math.Pi*float64(j*k)/n)
+		}
+	}
+
+	fft(data, false)
+	for k := range data {
+		if cmplx.Abs(data[k]-want[k]) > 1e-9 {
+			t.Fatalf("frequency %v = %v, want %v", k, data[k], want[k])
+		}
+	}
+}
+
+func TestFFT2RoundTrip(t *testing.T) {
+
+	for _, n := range []int{1, 2, 8, 64} {
+		data := randomData(n * n)
+		original := append([]complex128(nil), data...)
+
+		fft2(data, n, false)
+		fft2(data, n, true)
+		for i := range data {
+			if cmplx.Abs(data[i]-original[i]) > 1e-9 {
+				t.Fatalf("n = %v: cell %v = %v after a round trip, want %v", n, i, data[i], original[i])
+			}
+		}
+	}
+}
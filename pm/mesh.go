@@ -0,0 +1,233 @@
+package pm
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"proj3/phys"
+)
+
+const DefaultGridSize = 64 // Default number of mesh cells per side
+
+// Mesh is a particle-mesh (PM) gravity solver on a periodic box.
+// Mass is assigned to the mesh using cloud-in-cell (CIC) weighting, the
+// potential is found with an FFT and the forces are interpolated back with CIC
+type Mesh struct {
+	boundary  rl.Rectangle // Periodic box covered by the mesh
+	n         int          // Number of cells per side
+	hx        float64      // Width of a cell
+	hy        float64      // Height of a cell
+	rs        float64      // Long/short range split scale. 0 for a pure PM solve
+	density   []float64    // Surface density of each cell
+	potential []float64    // Potential of each cell
+	forceX    []float64    // X component of the force in each cell
+	forceY    []float64    // Y component of the force in each cell
+}
+
+/*
+ * Return a new Mesh covering the periodic box
+ *
+ * bound: Rectangle of the periodic box
+ * gridSize: Integer - number of cells per side. Rounded up to a power of two
+ */
+func NewMesh(bound rl.Rectangle, gridSize int) *Mesh {
+
+	n := 1
+	for n < gridSize {
+		n <<= 1
+	}
+
+	return &Mesh{bound, n,
+		float64(bound.Width) / float64(n), float64(bound.Height) / float64(n), 0,
+		make([]float64, n*n), nil, nil, nil}
+}
+
+/*
+ * Return the number of cells per side
+ */
+func (m *Mesh) GridSize() int {
+	return m.n
+}
+
+/*
+ * Return the size of the larger side of a cell
+ */
+func (m *Mesh) CellSize() float32 {
+	return float32(math.Max(m.hx, m.hy))
+}
+
+/*
+ * Assign the mass of a body to the mesh with cloud-in-cell weighting
+ */
+func (m *Mesh) Assign(body phys.Body) {
+
+	if body.Mass == 0 {
+		return
+	}
+
+	i0, j0, fx, fy := m.cell(body.Position)
+	i1, j1 := (i0+1)%m.n, (j0+1)%m.n
+
+	// Spread the mass over the four nearest cells
+	mass := float64(body.Mass) / (m.hx * m.hy)
+	m.density[j0*m.n+i0] += mass * (1 - fx) * (1 - fy)
+	m.density[j0*m.n+i1] += mass * fx * (1 - fy)
+	m.density[j1*m.n+i0] += mass * (1 - fx) * fy
+	m.density[j1*m.n+i1] += mass * fx * fy
+}
+
+/*
+ * Solve for the potential and the force on the mesh
+ * Must be called after all bodies are assigned and before CalculateForces
+ */
+func (m *Mesh) Solve() {
+
+	n := m.n
+
+	// Transform the density
+	data := make([]complex128, n*n)
+	for i, rho := range m.density {
+		data[i] = complex(rho, 0)
+	}
+	fft2(data, n, false)
+
+	// Apply the Green's function of a thin sheet: phi(k) = -2*pi*G*rho(k)/|k|
+	for j := 0; j < n; j++ {
+		ky := 2 * math.Pi * float64(frequency(j, n)) / float64(m.boundary.Height)
+		for i := 0; i < n; i++ {
+			kx := 2 * math.Pi * float64(frequency(i, n)) / float64(m.boundary.Width)
+			k := math.Hypot(kx, ky)
+
+			if k == 0 {
+				// Remove the mean density from the periodic box
+				data[j*n+i] = 0
+				continue
+			}
+
+			green := -2 * math.Pi * phys.G / k * longRangeFilter(k*m.rs)
+			data[j*n+i] *= complex(green, 0)
+		}
+	}
+
+	// Transform back to get the potential
+	fft2(data, n, true)
+	m.potential = make([]float64, n*n)
+	for i := range data {
+		m.potential[i] = real(data[i])
+	}
+
+	// Four point finite difference of the potential
+	m.forceX = make([]float64, n*n)
+	m.forceY = make([]float64, n*n)
+	for j := 0; j < n; j++ {
+		for i := 0; i < n; i++ {
+			m.forceX[j*n+i] = -(8*(m.at(i+1, j)-m.at(i-1, j)) -
+				(m.at(i+2, j) - m.at(i-2, j))) / (12 * m.hx)
+			m.forceY[j*n+i] = -(8*(m.at(i, j+1)-m.at(i, j-1)) -
+				(m.at(i, j+2) - m.at(i, j-2))) / (12 * m.hy)
+		}
+	}
+}
+
+/*
+ * Interpolate the mesh force onto the body with cloud-in-cell weighting
+ */
+func (m *Mesh) CalculateForces(body *phys.Body) {
+
+	if body.Mass == 0 {
+		return
+	}
+
+	i0, j0, fx, fy := m.cell(body.Position)
+	i1, j1 := (i0+1)%m.n, (j0+1)%m.n
+
+	w00 := (1 - fx) * (1 - fy)
+	w10 := fx * (1 - fy)
+	w01 := (1 - fx) * fy
+	w11 := fx * fy
+
+	forceX := m.forceX[j0*m.n+i0]*w00 + m.forceX[j0*m.n+i1]*w10 +
+		m.forceX[j1*m.n+i0]*w01 + m.forceX[j1*m.n+i1]*w11
+	forceY := m.forceY[j0*m.n+i0]*w00 + m.forceY[j0*m.n+i1]*w10 +
+		m.forceY[j1*m.n+i0]*w01 + m.forceY[j1*m.n+i1]*w11
+
+	body.Force.X += float32(forceX)
+	body.Force.Y += float32(forceY)
+}
+
+/*
+ * Interpolate the mesh potential onto the body with cloud-in-cell weighting
+ *
+ * return: the potential energy of the body with the mass on the mesh
+ */
+func (m *Mesh) PotentialEnergy(body *phys.Body) float32 {
+
+	i0, j0, fx, fy := m.cell(body.Position)
+	i1, j1 := i0+1, j0+1
+
+	potential := m.at(i0, j0)*(1-fx)*(1-fy) + m.at(i1, j0)*fx*(1-fy) +
+		m.at(i0, j1)*(1-fx)*fy + m.at(i1, j1)*fx*fy
+
+	return float32(potential) * body.Mass
+}
+
+/*
+ * Find the lower left cell used for CIC weighting of a position
+ *
+ * return: cell indices and the fractional distance from the cell centre
+ */
+func (m *Mesh) cell(pos rl.Vector2) (int, int, float64, float64) {
+
+	// Wrap the position into the periodic box
+	x := wrap(float64(pos.X-m.boundary.X), float64(m.boundary.Width))
+	y := wrap(float64(pos.Y-m.boundary.Y), float64(m.boundary.Height))
+
+	// Cell centres are at (i + 0.5) * h
+	gx := x/m.hx - 0.5
+	gy := y/m.hy - 0.5
+	fi := math.Floor(gx)
+	fj := math.Floor(gy)
+
+	i := (int(fi) + m.n) % m.n
+	j := (int(fj) + m.n) % m.n
+
+	return i, j, gx - fi, gy - fj
+}
+
+/*
+ * Return the potential of a cell with periodic indexing
+ */
+func (m *Mesh) at(i, j int) float64 {
+	i = (i%m.n + m.n) % m.n
+	j = (j%m.n + m.n) % m.n
+	return m.potential[j*m.n+i]
+}
+
+/*
+ * Return the signed frequency of FFT index i
+ */
+func frequency(i, n int) int {
+	if i < n/2 {
+		return i
+	}
+	return i - n
+}
+
+/*
+ * Wrap x into [0, length)
+ */
+func wrap(x, length float64) float64 {
+	x = math.Mod(x, length)
+	if x < 0 {
+		x += length
+	}
+	return x
+}
+
+/*
+ * Long range part of the thin sheet potential: erfc(x), the transform of the erf(r/2rs)/r part
+ * of the potential the short range forces leave out
+ * Equal to 1 for a pure PM solve (x = 0)
+ */
+func longRangeFilter(x float64) float64 {
+	return math.Erfc(x)
+}
@@ -0,0 +1,73 @@
+package pm
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"math/rand"
+	"proj3/phys"
+	"testing"
+)
+
+/*
+ * Return n bodies at random positions in the box with a fixed seed
+ */
+func randomBodies(n int, box rl.Rectangle) []phys.Body {
+
+	r := rand.New(rand.NewSource(1))
+	bodies := make([]phys.Body, n)
+	for i := 0; i < n; i++ {
+		pos := rl.NewVector2(box.X+r.Float32()*box.Width, box.Y+r.Float32()*box.Height)
+		bodies[i] = phys.NewBody(0.5+r.Float32(), i, pos, rl.NewVector2(0, 0))
+	}
+
+	return bodies
+}
+
+func TestAssignConservesMass(t *testing.T) {
+
+	box := rl.NewRectangle(-100, 50, 400, 200)
+	mesh := NewMesh(box, 32)
+
+	// Bodies outside the box are wrapped back into it
+	bodies := randomBodies(500, rl.NewRectangle(-300, -100, 800, 500))
+	total := 0.0
+	for _, b := range bodies {
+		mesh.Assign(b)
+		total += float64(b.Mass)
+	}
+
+	assigned := 0.0
+	for _, rho := range mesh.density {
+		if rho < 0 {
+			t.Fatalf("negative density %v", rho)
+		}
+		assigned += rho * mesh.hx * mesh.hy
+	}
+	if math.Abs(assigned-total) > 1e-9*total {
+		t.Errorf("mesh holds mass %v, want %v", assigned, total)
+	}
+}
+
+func TestMeshTwoBodyForce(t *testing.T) {
+
+	// Two bodies far apart on the mesh but close in a large box, so the periodic images barely pull
+	box := rl.NewRectangle(0, 0, 2000, 2000)
+	mesh := NewMesh(box, 256)
+	const separation = 100
+	a := phys.NewBody(10, 0, rl.NewVector2(1000-separation/2, 1000), rl.NewVector2(0, 0))
+	b := phys.NewBody(10, 1, rl.NewVector2(1000+separation/2, 1000), rl.NewVector2(0, 0))
+	mesh.Assign(a)
+	mesh.Assign(b)
+	mesh.Solve()
+
+	mesh.CalculateForces(&a)
+	mesh.CalculateForces(&b)
+
+	want := float64(phys.G) * 10 / (separation * separation)
+	if math.Abs(float64(a.Force.X)-want) > 0.05*want || math.Abs(float64(a.Force.Y)) > 0.01*want {
+		t.Errorf("force on a = %v, want [%v 0]", a.Force, want)
+	}
+	if math.Abs(float64(b.Force.X)+want) > 0.05*want || math.Abs(float64(b.Force.Y)) > 0.01*want {
+		t.Errorf("force on b = %v, want [%v 0]", b.Force, -want)
+	}
+}
@@ -0,0 +1,71 @@
+package pm
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/phys"
+	"proj3/qtree"
+)
+
+const splitScale = 1.25 // Split scale between the long and short range forces in cells
+
+// TreePM combines a Mesh for the long-range forces with a BHTree
+// for the short-range forces in a periodic box
+type TreePM struct {
+	Mesh *Mesh         // Long-range solver
+	Tree *qtree.BHTree // Short-range solver
+	rs   float32       // Split scale between the long and short range forces
+}
+
+/*
+ * Return a new TreePM solver covering the periodic box
+ *
+ * bound: Rectangle of the periodic box
+ * gridSize: Integer - number of mesh cells per side
+ */
+func NewTreePM(bound rl.Rectangle, gridSize int) *TreePM {
+
+	mesh := NewMesh(bound, gridSize)
+	rs := splitScale * mesh.CellSize()
+	mesh.rs = float64(rs)
+
+	return &TreePM{mesh, qtree.NewPeriodicBHTree(bound), rs}
+}
+
+/*
+ * Insert a new body into both the mesh and the tree
+ */
+func (t *TreePM) Insert(body phys.Body) {
+	t.Mesh.Assign(body)
+	t.Tree.Insert(body, 0)
+}
+
+/*
+ * Solve the long-range forces on the mesh
+ * Must be called after all bodies are inserted and before CalculateForces
+ */
+func (t *TreePM) Solve() {
+	t.Mesh.Solve()
+}
+
+/*
+ * Calculate the combined long and short range forces on the body
+ */
+func (t *TreePM) CalculateForces(body *phys.Body) {
+	t.Mesh.CalculateForces(body)
+	t.Tree.CalculateShortRangeForces(body, t.rs)
+}
+
+/*
+ * Return the potential energy of the body, from the long-range potential of the mesh and the
+ * short-range potential of the tree
+ */
+func (t *TreePM) PotentialEnergy(body *phys.Body) float32 {
+	return t.Mesh.PotentialEnergy(body) + t.Tree.ShortRangePotentialEnergy(body, t.rs)
+}
+
+/*
+ * Count the tree nodes the body interacts with for the short-range forces
+ */
+func (t *TreePM) CountInteractions(body *phys.Body) int {
+	return t.Tree.CountInteractions(body, t.rs)
+}
@@ -0,0 +1,43 @@
+package pm
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/gen2brain/raylib-go/raymath"
+	"math"
+	"proj3/qtree"
+	"testing"
+)
+
+func TestTreePMMatchesPeriodicTree(t *testing.T) {
+
+	// A cluster in the middle of the box, where the nearest image barely differs from every image
+	box := rl.NewRectangle(0, 0, 1000, 1000)
+	bodies := randomBodies(200, rl.NewRectangle(400, 400, 200, 200))
+
+	treePM := NewTreePM(box, 128)
+	tree := qtree.NewPeriodicBHTree(box)
+	tree.SetAccuracy(0, 0)
+	treePM.Tree.SetAccuracy(0, 0)
+	for _, b := range bodies {
+		treePM.Insert(b)
+		tree.Insert(b, 0)
+	}
+	treePM.Solve()
+
+	var errSum, forceSum float64
+	for i := range bodies {
+		split, whole := bodies[i], bodies[i]
+		treePM.CalculateForces(&split)
+		tree.CalculateForces(&whole)
+
+		errSum += float64(raymath.Vector2Length(raymath.Vector2Subtract(split.Force, whole.Force)))
+		forceSum += float64(raymath.Vector2Length(whole.Force))
+	}
+
+	if errSum > 0.02*forceSum {
+		t.Errorf("mean TreePM force error is %.2f%% of the tree force", 100*errSum/forceSum)
+	}
+	if math.IsNaN(errSum) {
+		t.Error("TreePM force is NaN")
+	}
+}
@@ -3,33 +3,58 @@ package qtree
 import (
 	rl "github.com/gen2brain/raylib-go/raylib"
 	"github.com/gen2brain/raylib-go/raymath"
+	"math"
 	"proj3/phys"
 )
 
-const theta = 0.8    // Theta value to determine level of accuracy
-const maxDepth = 800 // Helps avoid a stack overflow due to recurrsion
+const DefaultTheta = 0.8 // Theta value to determine level of accuracy
+const maxDepth = 800     // Helps avoid a stack overflow due to recurrsion
 // - Lower if FPS starts getting too low; Make higher if more accuracy is wanted
+const cutoff = 4.5 // Short-range TreePM forces are ignored beyond cutoff*rs
 
 // Barnes-Hut Tree (BHTree) is a QuadTree data structure
 // that is used to approximate forces acting on each other during N-Body simulations
 type BHTree struct {
-	boundary rl.Rectangle // Boundary of the BHTree
-	body     phys.Body    // Holds the bodies that belong in this BHTree
-	divided  bool         // Whether this BHTree has subdivided or not
-	nw       *BHTree      // Top Left of quadrant
-	ne       *BHTree      // Top right of quadrant
-	sw       *BHTree      // Bottom Left of quadrant
-	se       *BHTree      // Bottom Right of quadrant
+	boundary  rl.Rectangle // Boundary of the BHTree
+	period    rl.Vector2   // Size of the periodic box. Zero for open boundaries
+	theta     float32      // Opening angle below which a node is approximated by its COM
+	softening float32      // Plummer softening length of the forces and potential
+	body      phys.Body    // Holds the bodies that belong in this BHTree
+	divided   bool         // Whether this BHTree has subdivided or not
+	nw        *BHTree      // Top Left of quadrant
+	ne        *BHTree      // Top right of quadrant
+	sw        *BHTree      // Bottom Left of quadrant
+	se        *BHTree      // Bottom Right of quadrant
 }
 
 /*
  * Return a new BHTree
  */
 func NewBHTree(bound rl.Rectangle) *BHTree {
-	return &BHTree{bound, phys.Body{}, false,
+	return &BHTree{bound, rl.NewVector2(0, 0), DefaultTheta, 0, phys.Body{}, false,
 		nil, nil, nil, nil}
 }
 
+/*
+ * Return a new BHTree for a periodic box
+ * Forces are calculated from the nearest periodic image of each node
+ */
+func NewPeriodicBHTree(bound rl.Rectangle) *BHTree {
+	return &BHTree{bound, rl.NewVector2(bound.Width, bound.Height), DefaultTheta, 0, phys.Body{}, false,
+		nil, nil, nil, nil}
+}
+
+/*
+ * Set the accuracy of the forces and potential. Must be called before any body is inserted
+ *
+ * theta: opening angle below which a node is approximated by its COM. 0 sums every body directly
+ * softening: Plummer softening length. 0 for no softening
+ */
+func (q *BHTree) SetAccuracy(theta, softening float32) {
+	q.theta = theta
+	q.softening = softening
+}
+
 /*
  * Insert a new body into the BHTree
  *
@@ -116,19 +141,22 @@ func (q *BHTree) CalculateForces(body *phys.Body) {
 		return
 	}
 
+	// Separation to the nearest image of this node
+	separation := phys.MinimumImage(raymath.Vector2Subtract(q.body.Position, body.Position), q.period)
+
 	if !q.divided {
 		// External node - calculate full force
-		body.AddForce(&q.body)
+		body.AddSoftenedForceAt(&q.body, separation, q.softening)
 		return
 	}
 
 	// Get parameters to determine whether this body is sufficiently far away
 	s := q.boundary.Width
-	d := raymath.Vector2Distance(q.body.Position, body.Position)
+	d := raymath.Vector2Length(separation)
 
-	if s/d < theta {
+	if s/d < q.theta {
 		// This node is sufficiently far away to approximate using COM
-		body.AddForce(&q.body)
+		body.AddSoftenedForceAt(&q.body, separation, q.softening)
 	} else {
 		// Not sufficiently far away - calculate for each body
 		q.nw.CalculateForces(body)
@@ -138,18 +166,182 @@ func (q *BHTree) CalculateForces(body *phys.Body) {
 	}
 }
 
+/*
+ * Calculate the short-range TreePM forces on the body
+ *
+ * body: body to calculate the forces on
+ * rs: split scale between the long and short range forces
+ */
+func (q *BHTree) CalculateShortRangeForces(body *phys.Body, rs float32) {
+
+	if body.Mass == 0 || q.body.Mass == 0 || q.body.Id == body.Id {
+		// No need to calculate force
+		return
+	}
+
+	// Skip nodes that are entirely beyond the cutoff radius
+	if q.beyondCutoff(body, rs) {
+		return
+	}
+
+	d := phys.MinimumImage(raymath.Vector2Subtract(q.body.Position, body.Position), q.period)
+
+	if !q.divided {
+		// External node - calculate full force
+		body.AddShortRangeForce(&q.body, d, rs, q.softening)
+		return
+	}
+
+	// Get parameters to determine whether this body is sufficiently far away
+	s := q.boundary.Width
+
+	if s/raymath.Vector2Length(d) < q.theta {
+		// This node is sufficiently far away to approximate using COM
+		body.AddShortRangeForce(&q.body, d, rs, q.softening)
+	} else {
+		// Not sufficiently far away - calculate for each body
+		q.nw.CalculateShortRangeForces(body, rs)
+		q.ne.CalculateShortRangeForces(body, rs)
+		q.sw.CalculateShortRangeForces(body, rs)
+		q.se.CalculateShortRangeForces(body, rs)
+	}
+}
+
+/*
+ * Calculate the potential energy of the body due to the entire tree
+ */
+func (q *BHTree) PotentialEnergy(body *phys.Body) float32 {
+
+	if body.Mass == 0 || q.body.Mass == 0 || q.body.Id == body.Id {
+		return 0
+	}
+
+	// Separation to the nearest image of this node
+	separation := phys.MinimumImage(raymath.Vector2Subtract(q.body.Position, body.Position), q.period)
+
+	if !q.divided {
+		// External node - calculate the full potential
+		return body.SoftenedPotentialEnergy(&q.body, separation, q.softening)
+	}
+
+	if q.boundary.Width/raymath.Vector2Length(separation) < q.theta {
+		// Sufficiently far away to approximate using COM
+		// The COM radius grows with its mass, so it is left out of the distance clamp
+		com := q.body
+		com.Radius = 0
+		return body.SoftenedPotentialEnergy(&com, separation, q.softening)
+	}
+
+	return q.nw.PotentialEnergy(body) + q.ne.PotentialEnergy(body) +
+		q.sw.PotentialEnergy(body) + q.se.PotentialEnergy(body)
+}
+
+/*
+ * Calculate the short-range TreePM potential energy of the body due to the entire tree
+ *
+ * rs: split scale between the long and short range potential
+ */
+func (q *BHTree) ShortRangePotentialEnergy(body *phys.Body, rs float32) float32 {
+
+	if body.Mass == 0 || q.body.Mass == 0 || q.body.Id == body.Id || q.beyondCutoff(body, rs) {
+		return 0
+	}
+
+	separation := phys.MinimumImage(raymath.Vector2Subtract(q.body.Position, body.Position), q.period)
+
+	if !q.divided {
+		// External node - calculate the full potential
+		return body.ShortRangePotentialEnergy(&q.body, separation, rs, q.softening)
+	}
+
+	if q.boundary.Width/raymath.Vector2Length(separation) < q.theta {
+		// Sufficiently far away to approximate using COM, as for PotentialEnergy
+		com := q.body
+		com.Radius = 0
+		return body.ShortRangePotentialEnergy(&com, separation, rs, q.softening)
+	}
+
+	return q.nw.ShortRangePotentialEnergy(body, rs) + q.ne.ShortRangePotentialEnergy(body, rs) +
+		q.sw.ShortRangePotentialEnergy(body, rs) + q.se.ShortRangePotentialEnergy(body, rs)
+}
+
+/*
+ * Count the nodes the body interacts with when its forces are calculated
+ *
+ * body: body to count the interactions of
+ * rs: split scale of a TreePM solver. 0 to count every interaction of CalculateForces
+ */
+func (q *BHTree) CountInteractions(body *phys.Body, rs float32) int {
+
+	if body.Mass == 0 || q.body.Mass == 0 || q.body.Id == body.Id {
+		return 0
+	}
+
+	if rs > 0 && q.beyondCutoff(body, rs) {
+		return 0
+	}
+
+	separation := phys.MinimumImage(raymath.Vector2Subtract(q.body.Position, body.Position), q.period)
+	if !q.divided || q.boundary.Width/raymath.Vector2Length(separation) < q.theta {
+		return 1
+	}
+
+	return q.nw.CountInteractions(body, rs) + q.ne.CountInteractions(body, rs) +
+		q.sw.CountInteractions(body, rs) + q.se.CountInteractions(body, rs)
+}
+
+/*
+ * Return the depth of the deepest node and the number of nodes in the tree
+ */
+func (q *BHTree) Stats() (int, int) {
+
+	if !q.divided {
+		return 1, 1
+	}
+
+	depth, nodes := 0, 1
+	for _, child := range []*BHTree{q.nw, q.ne, q.sw, q.se} {
+		d, n := child.Stats()
+		if d > depth {
+			depth = d
+		}
+		nodes += n
+	}
+
+	return depth + 1, nodes
+}
+
 /*
  * Draw the tree to the screen in GUI mode
+ *
+ * toScreen: function mapping a position onto the window. Must only pan and zoom
  */
-func (q *BHTree) DrawTree() {
+func (q *BHTree) DrawTree(toScreen func(rl.Vector2) rl.Vector2) {
 
-	rl.DrawRectangleLinesEx(q.boundary, 1, rl.Blue)
+	min := toScreen(rl.NewVector2(q.boundary.X, q.boundary.Y))
+	max := toScreen(rl.NewVector2(q.boundary.X+q.boundary.Width, q.boundary.Y+q.boundary.Height))
+	rl.DrawRectangleLinesEx(rl.NewRectangle(min.X, min.Y, max.X-min.X, max.Y-min.Y), 1, rl.Blue)
 
 	if q.divided {
-		q.nw.DrawTree()
-		q.ne.DrawTree()
-		q.sw.DrawTree()
-		q.se.DrawTree()
+		q.nw.DrawTree(toScreen)
+		q.ne.DrawTree(toScreen)
+		q.sw.DrawTree(toScreen)
+		q.se.DrawTree(toScreen)
+	}
+}
+
+/*
+ * Call visit with the boundary of this node and of every node below it
+ */
+func (q *BHTree) Cells(visit func(rl.Rectangle)) {
+
+	visit(q.boundary)
+
+	if q.divided {
+		q.nw.Cells(visit)
+		q.ne.Cells(visit)
+		q.sw.Cells(visit)
+		q.se.Cells(visit)
 	}
 }
 
@@ -173,10 +365,28 @@ func (q *BHTree) subdivide() {
 	q.ne = NewBHTree(ne)
 	q.se = NewBHTree(sw)
 	q.sw = NewBHTree(se)
+	for _, child := range []*BHTree{q.nw, q.ne, q.sw, q.se} {
+		child.period = q.period
+		child.theta = q.theta
+		child.softening = q.softening
+	}
 
 	q.divided = true
 }
 
+/*
+ * Check whether every point of this node is further than the TreePM cutoff from the body
+ */
+func (q *BHTree) beyondCutoff(body *phys.Body, rs float32) bool {
+
+	centre := rl.NewVector2(q.boundary.X+q.boundary.Width/2, q.boundary.Y+q.boundary.Height/2)
+	offset := phys.MinimumImage(raymath.Vector2Subtract(centre, body.Position), q.period)
+	nearX := float32(math.Max(math.Abs(float64(offset.X))-float64(q.boundary.Width/2), 0))
+	nearY := float32(math.Max(math.Abs(float64(offset.Y))-float64(q.boundary.Height/2), 0))
+
+	return nearX*nearX+nearY*nearY > cutoff*cutoff*rs*rs
+}
+
 /*
  * Contains checks to see if a body is in the correct rectangle
  */
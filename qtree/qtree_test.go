@@ -0,0 +1,229 @@
+package qtree
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"github.com/gen2brain/raylib-go/raymath"
+	"math"
+	"math/rand"
+	"proj3/phys"
+	"testing"
+)
+
+/*
+ * Return n bodies at random positions in the box with a fixed seed
+ */
+func randomBodies(n int, box rl.Rectangle) []phys.Body {
+
+	r := rand.New(rand.NewSource(1))
+	bodies := make([]phys.Body, n)
+	for i := 0; i < n; i++ {
+		pos := rl.NewVector2(box.X+r.Float32()*box.Width, box.Y+r.Float32()*box.Height)
+		bodies[i] = phys.NewBody(0.5+r.Float32(), i, pos, rl.NewVector2(0, 0))
+	}
+
+	return bodies
+}
+
+/*
+ * Insert every body into a new BHTree
+ */
+func buildTree(bodies []phys.Body, box rl.Rectangle) *BHTree {
+
+	tree := NewBHTree(box)
+	for i := 0; i < len(bodies); i++ {
+		tree.Insert(bodies[i], 0)
+	}
+
+	return tree
+}
+
+func TestInsertCentreOfMass(t *testing.T) {
+
+	box := rl.NewRectangle(0, 0, 1000, 1000)
+	bodies := randomBodies(200, box)
+	tree := buildTree(bodies, box)
+
+	// The root holds the combined mass and centre of mass of every body
+	com := bodies[0]
+	for i := 1; i < len(bodies); i++ {
+		com = phys.AddBody(com, bodies[i])
+	}
+
+	if math.Abs(float64(tree.body.Mass-com.Mass)) > 1e-3 {
+		t.Errorf("root mass = %v, want %v", tree.body.Mass, com.Mass)
+	}
+	if math.Abs(float64(tree.body.Position.X-com.Position.X)) > 1e-2 ||
+		math.Abs(float64(tree.body.Position.Y-com.Position.Y)) > 1e-2 {
+		t.Errorf("root centre of mass = %v, want %v", tree.body.Position, com.Position)
+	}
+	if !tree.divided {
+		t.Errorf("root did not subdivide")
+	}
+}
+
+func TestInsertSingleBody(t *testing.T) {
+
+	tree := NewBHTree(rl.NewRectangle(0, 0, 100, 100))
+	body := phys.NewBody(2, 5, rl.NewVector2(10, 20), rl.NewVector2(0, 0))
+	tree.Insert(body, 0)
+
+	if tree.divided || tree.body != body {
+		t.Errorf("single body should make an external node holding it, got %+v", tree.body)
+	}
+}
+
+/*
+ * With theta the tree forces stay close to the direct sum
+ */
+func TestCalculateForcesMatchesDirectSum(t *testing.T) {
+
+	box := rl.NewRectangle(0, 0, 1000, 1000)
+	bodies := randomBodies(100, box)
+	tree := buildTree(bodies, box)
+
+	var worst float64
+	for i := 0; i < len(bodies); i++ {
+		direct := bodies[i]
+		for j := 0; j < len(bodies); j++ {
+			if i != j {
+				direct.AddForce(&bodies[j])
+			}
+		}
+
+		approx := bodies[i]
+		tree.CalculateForces(&approx)
+
+		diff := math.Hypot(float64(approx.Force.X-direct.Force.X), float64(approx.Force.Y-direct.Force.Y))
+		size := math.Hypot(float64(direct.Force.X), float64(direct.Force.Y))
+		worst = math.Max(worst, diff/size)
+	}
+
+	if worst > 0.5 {
+		t.Errorf("largest relative force error = %v", worst)
+	}
+}
+
+/*
+ * With theta 0 every node is opened, so the tree sums every body directly, softened or not
+ */
+func TestSetAccuracy(t *testing.T) {
+
+	box := rl.NewRectangle(0, 0, 1000, 1000)
+	bodies := randomBodies(50, box)
+
+	for _, softening := range []float32{0, 20} {
+		tree := NewBHTree(box)
+		tree.SetAccuracy(0, softening)
+		for i := 0; i < len(bodies); i++ {
+			tree.Insert(bodies[i], 0)
+		}
+
+		for i := 0; i < len(bodies); i++ {
+			direct := bodies[i]
+			var potential float64
+			for j := 0; j < len(bodies); j++ {
+				if i != j {
+					d := raymath.Vector2Subtract(bodies[j].Position, bodies[i].Position)
+					direct.AddSoftenedForceAt(&bodies[j], d, softening)
+					potential += float64(bodies[i].SoftenedPotentialEnergy(&bodies[j], d, softening))
+				}
+			}
+
+			approx := bodies[i]
+			tree.CalculateForces(&approx)
+			diff := math.Hypot(float64(approx.Force.X-direct.Force.X), float64(approx.Force.Y-direct.Force.Y))
+			size := math.Hypot(float64(direct.Force.X), float64(direct.Force.Y))
+			if diff > 1e-4*size {
+				t.Fatalf("softening %v: tree force %v, direct sum %v", softening, approx.Force, direct.Force)
+			}
+			if w := float64(tree.PotentialEnergy(&bodies[i])); math.Abs(w-potential) > 1e-4*math.Abs(potential) {
+				t.Fatalf("softening %v: tree potential %v, direct sum %v", softening, w, potential)
+			}
+		}
+	}
+}
+
+func TestCalculateForcesSkipsSelf(t *testing.T) {
+
+	tree := NewBHTree(rl.NewRectangle(0, 0, 100, 100))
+	body := phys.NewBody(2, 5, rl.NewVector2(10, 20), rl.NewVector2(0, 0))
+	tree.Insert(body, 0)
+	tree.CalculateForces(&body)
+
+	if body.Force != rl.NewVector2(0, 0) {
+		t.Errorf("body felt a force from itself: %v", body.Force)
+	}
+}
+
+/*
+ * The figure-eight three-body orbit (Chenciner & Montgomery, 2000)
+ * returns to its initial positions after one period
+ */
+func TestFigureEight(t *testing.T) {
+
+	// Scale the G = m = 1 solution so the clamped radii are small compared to the orbit
+	const length = 100.0
+	const mass = 1.0
+	const period = 6.32591398
+	speed := math.Sqrt(phys.G * mass / length)
+	timeScale := math.Sqrt(length * length * length / (phys.G * mass))
+
+	centre := rl.NewVector2(500, 500)
+	pos := [][2]float64{{-0.97000436, 0.24308753}, {0.97000436, -0.24308753}, {0, 0}}
+	vel := [][2]float64{{0.4662036850, 0.4323657300}, {0.4662036850, 0.4323657300}, {-0.93240737, -0.86473146}}
+
+	bodies := make([]phys.Body, 3)
+	for i := range bodies {
+		bodies[i] = phys.NewBody(mass, i,
+			rl.NewVector2(centre.X+float32(pos[i][0]*length), centre.Y+float32(pos[i][1]*length)),
+			rl.NewVector2(float32(vel[i][0]*speed), float32(vel[i][1]*speed)))
+	}
+	start := make([]phys.Body, 3)
+	copy(start, bodies)
+
+	// Number of steps in one period with dt = 0.4
+	steps := int(math.Round(period * timeScale / phys.DefaultDt))
+	box := rl.NewRectangle(0, 0, 1000, 1000)
+
+	for step := 0; step < steps; step++ {
+		tree := buildTree(bodies, box)
+		for i := range bodies {
+			tree.CalculateForces(&bodies[i])
+		}
+		for i := range bodies {
+			bodies[i].Update(phys.DefaultDt)
+			bodies[i].ZeroForce()
+		}
+	}
+
+	for i := range bodies {
+		d := math.Hypot(float64(bodies[i].Position.X-start[i].Position.X),
+			float64(bodies[i].Position.Y-start[i].Position.Y))
+		if d > 0.01*length {
+			t.Errorf("body %v is %v from its starting position after one period", i, d)
+		}
+	}
+}
+
+func TestCells(t *testing.T) {
+
+	box := rl.NewRectangle(0, 0, 1000, 1000)
+	tree := buildTree(randomBodies(200, box), box)
+
+	// Every node is visited once, and the children tile the root
+	cells := 0
+	var area float32
+	tree.Cells(func(cell rl.Rectangle) {
+		cells++
+		if cell.Width == box.Width/2 {
+			area += cell.Width * cell.Height
+		}
+	})
+
+	if _, nodes := tree.Stats(); cells != nodes {
+		t.Errorf("visited %v cells, want %v", cells, nodes)
+	}
+	if area != box.Width*box.Height {
+		t.Errorf("children of the root cover %v, want %v", area, box.Width*box.Height)
+	}
+}
@@ -0,0 +1,105 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Canvas is an image that bodies, trails and tree cells are drawn onto without a window
+type Canvas struct {
+	*image.RGBA
+	Additive bool // Whether colours add onto the image instead of being blended over it
+}
+
+/*
+ * Return a canvas of the given size filled with a colour
+ */
+func NewCanvas(width, height int, background color.RGBA) *Canvas {
+	c := &Canvas{RGBA: image.NewRGBA(image.Rect(0, 0, width, height))}
+	for i := 0; i < len(c.Pix); i += 4 {
+		c.Pix[i], c.Pix[i+1], c.Pix[i+2], c.Pix[i+3] = background.R, background.G, background.B, background.A
+	}
+	return c
+}
+
+/*
+ * Draw a pixel with the colour's alpha as its opacity
+ * Pixels outside the canvas are skipped
+ */
+func (c *Canvas) Plot(x, y int, col color.RGBA) {
+
+	if !(image.Point{x, y}.In(c.Rect)) {
+		return
+	}
+
+	i := c.PixOffset(x, y)
+	a := float64(col.A) / 255
+	for k, v := range []uint8{col.R, col.G, col.B} {
+		dst := float64(c.Pix[i+k])
+		if c.Additive {
+			dst += a * float64(v)
+		} else {
+			dst = a*float64(v) + (1-a)*dst
+		}
+		c.Pix[i+k] = uint8(math.Min(dst+0.5, 255))
+	}
+	c.Pix[i+3] = 255
+}
+
+/*
+ * Draw a line between two points
+ */
+func (c *Canvas) Line(x0, y0, x1, y1 float64, col color.RGBA) {
+
+	steps := int(math.Ceil(math.Max(math.Abs(x1-x0), math.Abs(y1-y0))))
+	if steps == 0 {
+		c.Plot(int(math.Floor(x0)), int(math.Floor(y0)), col)
+		return
+	}
+
+	// Lines far outside the canvas are skipped instead of stepped along
+	if steps > 4*(c.Rect.Dx()+c.Rect.Dy()) {
+		return
+	}
+
+	for s := 0; s <= steps; s++ {
+		t := float64(s) / float64(steps)
+		c.Plot(int(math.Floor(x0+t*(x1-x0))), int(math.Floor(y0+t*(y1-y0))), col)
+	}
+}
+
+/*
+ * Draw a circle, either filled or as a one pixel outline
+ * Circles smaller than a pixel are drawn as a single pixel
+ */
+func (c *Canvas) Circle(x, y, radius float64, col color.RGBA, filled bool) {
+
+	if radius < 1 {
+		c.Plot(int(math.Floor(x)), int(math.Floor(y)), col)
+		return
+	}
+
+	// Only the part of the bounding square on the canvas is visited
+	minX, maxX := int(math.Max(math.Floor(x-radius-1), 0)), int(math.Min(math.Ceil(x+radius+1), float64(c.Rect.Dx()-1)))
+	minY, maxY := int(math.Max(math.Floor(y-radius-1), 0)), int(math.Min(math.Ceil(y+radius+1), float64(c.Rect.Dy()-1)))
+
+	for py := minY; py <= maxY; py++ {
+		for px := minX; px <= maxX; px++ {
+			d := math.Hypot(float64(px)+0.5-x, float64(py)+0.5-y)
+			if (filled && d <= radius) || (!filled && math.Abs(d-radius) <= 0.5) {
+				c.Plot(px, py, col)
+			}
+		}
+	}
+}
+
+/*
+ * Draw the outline of a rectangle
+ */
+func (c *Canvas) Rectangle(x, y, width, height float64, col color.RGBA) {
+	c.Line(x, y, x+width, y, col)
+	c.Line(x, y+height, x+width, y+height, col)
+	c.Line(x, y, x, y+height, col)
+	c.Line(x+width, y, x+width, y+height, col)
+}
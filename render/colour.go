@@ -0,0 +1,216 @@
+package render
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"proj3/phys"
+	"sort"
+)
+
+// Number of grid cells across the longer side of the bodies when estimating the local density
+const DensityCells = 32
+
+// Colours from low to high values
+var ramp = []rl.Color{
+	rl.NewColor(40, 80, 255, 255),
+	rl.NewColor(0, 200, 255, 255),
+	rl.NewColor(0, 230, 120, 255),
+	rl.NewColor(255, 230, 0, 255),
+	rl.NewColor(255, 60, 30, 255),
+}
+
+// Colours of the components, in order of their names
+var tagColours = []rl.Color{rl.Green, rl.SkyBlue, rl.Orange, rl.Pink, rl.Purple, rl.Maroon, rl.Gold, rl.Lime}
+
+// Colour of bodies without a component
+var Untagged = rl.Gray
+
+// Legend describes what the colours of a colour map stand for
+type Legend struct {
+	Map     ColourMap  // Colour map the legend is for
+	Min     float64    // Value at the low end of the ramp
+	Max     float64    // Value at the high end of the ramp
+	Log     bool       // Whether the ramp is logarithmic
+	Tags    []string   // Names of the components, for the component colour map
+	Colours []rl.Color // Colour of each component
+}
+
+/*
+ * Return the colour a fraction of the way along the ramp
+ */
+func Ramp(f float64) rl.Color {
+
+	f = math.Min(math.Max(f, 0), 1) * float64(len(ramp)-1)
+	i := int(math.Min(f, float64(len(ramp)-2)))
+	t := f - float64(i)
+
+	mix := func(a, b uint8) uint8 { return uint8(float64(a) + t*(float64(b)-float64(a)) + 0.5) }
+	return rl.NewColor(mix(ramp[i].R, ramp[i+1].R), mix(ramp[i].G, ramp[i+1].G), mix(ramp[i].B, ramp[i+1].B), 255)
+}
+
+/*
+ * Colour the bodies by a colour map
+ * Values are spread over the ramp between the smallest and largest of them,
+ * logarithmically when they are all positive and span more than a factor of ten
+ *
+ * m: colour map to use
+ * bodies: slice of physics bodies
+ * tags: component of each body by Id. Only used by the component colour map
+ *
+ * return: the colour of each body, and the legend of the colours
+ */
+func Colours(m ColourMap, bodies []phys.Body, tags map[int]string) ([]rl.Color, Legend) {
+
+	colours := make([]rl.Color, len(bodies))
+	legend := Legend{Map: m}
+
+	switch m {
+	case Plain:
+		for i := range colours {
+			colours[i] = rl.Green
+		}
+
+	case Tag:
+		legend.Tags = tagNames(bodies, tags)
+		index := make(map[string]rl.Color, len(legend.Tags))
+		next := 0
+		for _, tag := range legend.Tags {
+			c := Untagged
+			if tag != "" {
+				c = tagColours[next%len(tagColours)]
+				next++
+			}
+			index[tag] = c
+			legend.Colours = append(legend.Colours, c)
+		}
+		for i := range bodies {
+			colours[i] = index[tags[bodies[i].Id]]
+		}
+
+	default:
+		values := quantity(m, bodies)
+		if len(values) == 0 {
+			return colours, legend
+		}
+
+		legend.Min, legend.Max = values[0], values[0]
+		for _, v := range values {
+			legend.Min, legend.Max = math.Min(legend.Min, v), math.Max(legend.Max, v)
+		}
+		legend.Log = legend.Min > 0 && legend.Max > 10*legend.Min
+
+		for i, v := range values {
+			colours[i] = Ramp(legend.Fraction(v))
+		}
+	}
+
+	return colours, legend
+}
+
+/*
+ * Return how far along the ramp a value is
+ */
+func (l Legend) Fraction(v float64) float64 {
+
+	lo, hi := l.Min, l.Max
+	if l.Log {
+		v, lo, hi = math.Log(v), math.Log(lo), math.Log(hi)
+	}
+	if hi <= lo {
+		return 0.5
+	}
+
+	return (v - lo) / (hi - lo)
+}
+
+/*
+ * Return the quantity a colour map shows for each body
+ */
+func quantity(m ColourMap, bodies []phys.Body) []float64 {
+
+	if m == Density {
+		return LocalDensity(bodies)
+	}
+
+	values := make([]float64, len(bodies))
+	for i := range bodies {
+		v2 := float64(bodies[i].Velocity.X*bodies[i].Velocity.X + bodies[i].Velocity.Y*bodies[i].Velocity.Y)
+		switch m {
+		case Speed:
+			values[i] = math.Sqrt(v2)
+		case Mass:
+			values[i] = float64(bodies[i].Mass)
+		case Kinetic:
+			values[i] = 0.5 * float64(bodies[i].Mass) * v2
+		}
+	}
+
+	return values
+}
+
+/*
+ * Return the mass density around each body
+ * The bodies are binned into a grid of DensityCells across their longer side,
+ * and each body gets the mass of its own and the eight surrounding cells over their area
+ *
+ * bodies: slice of physics bodies
+ *
+ * return: the density around each body
+ */
+func LocalDensity(bodies []phys.Body) []float64 {
+
+	density := make([]float64, len(bodies))
+	if len(bodies) == 0 {
+		return density
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for i := range bodies {
+		x, y := float64(bodies[i].Position.X), float64(bodies[i].Position.Y)
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	cell := math.Max(math.Max(maxX-minX, maxY-minY)/DensityCells, 1)
+
+	// Mass in each cell
+	cellOf := func(b *phys.Body) [2]int {
+		return [2]int{int((float64(b.Position.X) - minX) / cell), int((float64(b.Position.Y) - minY) / cell)}
+	}
+	grid := make(map[[2]int]float64)
+	for i := range bodies {
+		grid[cellOf(&bodies[i])] += float64(bodies[i].Mass)
+	}
+
+	for i := range bodies {
+		c := cellOf(&bodies[i])
+		var mass float64
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				mass += grid[[2]int{c[0] + dx, c[1] + dy}]
+			}
+		}
+		density[i] = mass / (9 * cell * cell)
+	}
+
+	return density
+}
+
+/*
+ * Return the sorted names of the components of the bodies, including "" if any body has none
+ */
+func tagNames(bodies []phys.Body, tags map[int]string) []string {
+
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for i := range bodies {
+		tag := tags[bodies[i].Id]
+		if !seen[tag] {
+			seen[tag] = true
+			names = append(names, tag)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,110 @@
+package render
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"math"
+	"proj3/phys"
+	"testing"
+)
+
+func TestParseColourMap(t *testing.T) {
+	for _, m := range []ColourMap{Plain, Speed, Mass, Kinetic, Density, Tag} {
+		if got, err := ParseColourMap(m.String()); err != nil || got != m {
+			t.Errorf("ParseColourMap(%q) = %v, %v", m.String(), got, err)
+		}
+	}
+	if _, err := ParseColourMap("rainbow"); err == nil {
+		t.Error("ParseColourMap accepted an unknown name")
+	}
+	if Tag.Next() != Plain {
+		t.Errorf("Tag.Next() = %v, want %v", Tag.Next(), Plain)
+	}
+}
+
+func TestRamp(t *testing.T) {
+	if Ramp(0) != ramp[0] || Ramp(1) != ramp[len(ramp)-1] {
+		t.Errorf("Ramp ends = %v, %v, want %v, %v", Ramp(0), Ramp(1), ramp[0], ramp[len(ramp)-1])
+	}
+	if Ramp(-1) != Ramp(0) || Ramp(2) != Ramp(1) {
+		t.Error("Ramp is not clamped to [0, 1]")
+	}
+}
+
+func TestColoursBySpeed(t *testing.T) {
+
+	bodies := []phys.Body{
+		phys.NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(1, 0)),
+		phys.NewBody(1, 1, rl.NewVector2(0, 0), rl.NewVector2(0, 3)),
+		phys.NewBody(1, 2, rl.NewVector2(0, 0), rl.NewVector2(3, 4)),
+	}
+	colours, legend := Colours(Speed, bodies, nil)
+
+	if legend.Min != 1 || legend.Max != 5 || legend.Log {
+		t.Errorf("legend = %+v, want a linear range [1, 5]", legend)
+	}
+	if colours[0] != Ramp(0) || colours[1] != Ramp(0.5) || colours[2] != Ramp(1) {
+		t.Errorf("colours = %v", colours)
+	}
+}
+
+func TestColoursLog(t *testing.T) {
+
+	bodies := []phys.Body{
+		phys.NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+		phys.NewBody(10, 1, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+		phys.NewBody(100, 2, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+	}
+	colours, legend := Colours(Mass, bodies, nil)
+
+	if !legend.Log {
+		t.Error("masses spanning a factor of 100 are not on a log scale")
+	}
+	if math.Abs(legend.Fraction(10)-0.5) > 1e-9 || colours[1] != Ramp(0.5) {
+		t.Errorf("Fraction(10) = %v, want 0.5", legend.Fraction(10))
+	}
+}
+
+func TestColoursByTag(t *testing.T) {
+
+	bodies := []phys.Body{
+		phys.NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 1, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 2, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 3, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+	}
+	tags := map[int]string{0: "satellite", 1: "host", 2: "satellite"}
+	colours, legend := Colours(Tag, bodies, tags)
+
+	want := []string{"", "host", "satellite"}
+	if len(legend.Tags) != len(want) {
+		t.Fatalf("Tags = %v, want %v", legend.Tags, want)
+	}
+	for i := range want {
+		if legend.Tags[i] != want[i] {
+			t.Fatalf("Tags = %v, want %v", legend.Tags, want)
+		}
+	}
+	if colours[0] != tagColours[1] || colours[1] != tagColours[0] || colours[2] != colours[0] || colours[3] != Untagged {
+		t.Errorf("colours = %v", colours)
+	}
+}
+
+func TestLocalDensity(t *testing.T) {
+
+	// A tight cluster and a lone body far from it
+	bodies := make([]phys.Body, 0)
+	for i := 0; i < 10; i++ {
+		bodies = append(bodies, phys.NewBody(1, i, rl.NewVector2(float32(i%3), float32(i/3)), rl.NewVector2(0, 0)))
+	}
+	bodies = append(bodies, phys.NewBody(1, 10, rl.NewVector2(1000, 1000), rl.NewVector2(0, 0)))
+
+	density := LocalDensity(bodies)
+	if density[0] <= density[10] {
+		t.Errorf("cluster density %v is not above the lone body's %v", density[0], density[10])
+	}
+
+	cell := 1000.0 / DensityCells
+	if math.Abs(density[10]-1/(9*cell*cell)) > 1e-12 {
+		t.Errorf("lone density = %v, want %v", density[10], 1/(9*cell*cell))
+	}
+}
@@ -0,0 +1,88 @@
+package render
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"image/color"
+	"proj3/phys"
+)
+
+// Colour of the tree cells
+var CellColour = color.RGBA{0, 121, 241, 255}
+
+// Renderer draws frames of the simulation onto images without a window
+type Renderer struct {
+	Width   int            // Width of the images in pixels
+	Height  int            // Height of the images in pixels
+	Box     rl.Rectangle   // Part of the simulation shown in the images
+	Colours ColourMap      // Quantity the bodies are coloured by
+	Style   Style          // How the bodies are drawn
+	Trails  *Trails        // Last positions of the bodies, drawn behind them
+	Tags    map[int]string // Scenario component of each body by Id
+}
+
+/*
+ * Return the image position of a simulation position
+ */
+func (r *Renderer) toImage(pos rl.Vector2) (float64, float64) {
+	return float64(pos.X-r.Box.X) * float64(r.Width) / float64(r.Box.Width),
+		float64(pos.Y-r.Box.Y) * float64(r.Height) / float64(r.Box.Height)
+}
+
+/*
+ * Draw a frame of the bodies on a black background
+ *
+ * bodies: slice of physics bodies
+ * cells: tree cells to draw under the bodies. nil draws none
+ *
+ * return: the canvas holding the frame
+ */
+func (r *Renderer) Draw(bodies []phys.Body, cells []rl.Rectangle) *Canvas {
+
+	canvas := NewCanvas(r.Width, r.Height, color.RGBA{0, 0, 0, 255})
+	scale := float64(r.Width) / float64(r.Box.Width)
+
+	for _, cell := range cells {
+		x, y := r.toImage(rl.NewVector2(cell.X, cell.Y))
+		canvas.Rectangle(x, y, float64(cell.Width)*scale, float64(cell.Height)*float64(r.Height)/float64(r.Box.Height),
+			CellColour)
+	}
+
+	colours, _ := Colours(r.Colours, bodies, r.Tags)
+
+	if r.Trails != nil {
+		for i := range bodies {
+			points := r.Trails.Points(bodies[i].Id)
+			for j := 1; j < len(points); j++ {
+				if Continuous(points[j-1], points[j], r.Box) {
+					x0, y0 := r.toImage(points[j-1])
+					x1, y1 := r.toImage(points[j])
+					canvas.Line(x0, y0, x1, y1, withAlpha(colours[i], Fade(j, len(points))))
+				}
+			}
+		}
+	}
+
+	canvas.Additive = r.Style == Additive
+	for i := range bodies {
+		x, y := r.toImage(bodies[i].Position)
+		radius := float64(bodies[i].Radius) * scale
+		switch r.Style {
+		case Outline:
+			canvas.Circle(x, y, radius, withAlpha(colours[i], 1), false)
+		case Filled:
+			canvas.Circle(x, y, radius, withAlpha(colours[i], 1), true)
+		case Additive:
+			canvas.Circle(x, y, radius, withAlpha(colours[i], AdditiveAlpha), true)
+		}
+	}
+	canvas.Additive = false
+
+	return canvas
+}
+
+/*
+ * Return an image colour with the opacity of a fraction of the raylib colour's
+ */
+func withAlpha(c rl.Color, alpha float32) color.RGBA {
+	return color.RGBA{c.R, c.G, c.B, uint8(float32(c.A)*alpha + 0.5)}
+}
@@ -0,0 +1,50 @@
+package render
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"image/color"
+	"proj3/phys"
+	"testing"
+)
+
+func TestCanvasBlend(t *testing.T) {
+
+	c := NewCanvas(2, 1, color.RGBA{0, 0, 0, 255})
+	c.Plot(0, 0, color.RGBA{200, 100, 0, 128})
+	c.Plot(0, 0, color.RGBA{200, 100, 0, 128})
+	c.Additive = true
+	c.Plot(1, 0, color.RGBA{200, 100, 0, 255})
+	c.Plot(1, 0, color.RGBA{200, 100, 0, 255})
+	c.Plot(5, 5, color.RGBA{255, 255, 255, 255})
+
+	if got := c.RGBAAt(0, 0); got.R != 150 || got.G != 75 {
+		t.Errorf("blended pixel = %v, want R 150, G 75", got)
+	}
+	if got := c.RGBAAt(1, 0); got.R != 255 || got.G != 200 {
+		t.Errorf("added pixel = %v, want R 255, G 200", got)
+	}
+}
+
+func TestRendererDraw(t *testing.T) {
+
+	r := &Renderer{Width: 200, Height: 100, Box: rl.NewRectangle(0, 0, 400, 200)}
+	bodies := []phys.Body{phys.NewBody(5, 0, rl.NewVector2(100, 100), rl.NewVector2(0, 0))}
+	canvas := r.Draw(bodies, []rl.Rectangle{rl.NewRectangle(0, 0, 200, 200)})
+
+	// The body of radius 15 is drawn at half scale around (50, 50)
+	green := withAlpha(rl.Green, 1)
+	if canvas.RGBAAt(57, 50) != green || canvas.RGBAAt(50, 50) != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("outline pixels = %v, %v", canvas.RGBAAt(57, 50), canvas.RGBAAt(50, 50))
+	}
+
+	// The cell covers the left half of the image
+	if canvas.RGBAAt(100, 30) != CellColour {
+		t.Errorf("cell edge pixel = %v, want %v", canvas.RGBAAt(100, 30), CellColour)
+	}
+
+	r.Style = Filled
+	canvas = r.Draw(bodies, nil)
+	if canvas.RGBAAt(50, 50) != green {
+		t.Errorf("filled centre pixel = %v, want %v", canvas.RGBAAt(50, 50), green)
+	}
+}
@@ -0,0 +1,225 @@
+package render
+
+import (
+	"bufio"
+	"compress/lzw"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Delay between the frames of an animated GIF, in hundredths of a second
+const GIFDelay = 4
+
+// FrameWriter writes the frames of a run
+type FrameWriter interface {
+	WriteFrame(img image.Image) error
+	Close() error
+}
+
+// PNGWriter writes each frame into its own numbered PNG file
+type PNGWriter struct {
+	Dir   string // Directory the files are written into
+	count int    // Number of frames written
+}
+
+/*
+ * Return a writer of numbered PNG files, creating the directory if it does not exist
+ */
+func NewPNGWriter(dir string) (*PNGWriter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &PNGWriter{Dir: dir}, nil
+}
+
+/*
+ * Write a frame into the next file, frame_00000.png onwards
+ */
+func (w *PNGWriter) WriteFrame(img image.Image) error {
+
+	f, err := os.Create(filepath.Join(w.Dir, fmt.Sprintf("frame_%05d.png", w.count)))
+	if err != nil {
+		return err
+	}
+	w.count++
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/*
+ * Nothing is left to write once every frame has its own file
+ */
+func (w *PNGWriter) Close() error {
+	return nil
+}
+
+// GIFWriter streams the frames into a single animated GIF as they are drawn, so a run of any length holds
+// only the frame being written. Every frame must be the size of the first
+type GIFWriter struct {
+	Path   string          // File the animation is written into
+	file   *os.File        // The open file
+	out    *bufio.Writer   // Buffer of the file
+	bounds image.Rectangle // Bounds of the first frame, the size of the animation
+	frames int             // Number of frames written
+}
+
+/*
+ * Return a writer of an animated GIF, creating the file
+ */
+func NewGIFWriter(path string) (*GIFWriter, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &GIFWriter{Path: path, file: f, out: bufio.NewWriter(f)}, nil
+}
+
+/*
+ * Write a frame to the animation, reduced to the web safe palette
+ * The header is written with the first frame, which sets the size of the animation
+ */
+func (w *GIFWriter) WriteFrame(img image.Image) error {
+
+	// The web safe palette has six levels of each channel with blue changing fastest,
+	// so the nearest colour is found directly instead of searching the palette
+	frame := image.NewPaletted(img.Bounds(), palette.WebSafe)
+	level := func(v uint32) int { return int((v>>8)+25) / 51 }
+	for y := frame.Rect.Min.Y; y < frame.Rect.Max.Y; y++ {
+		for x := frame.Rect.Min.X; x < frame.Rect.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			frame.SetColorIndex(x, y, uint8(36*level(r)+6*level(g)+level(b)))
+		}
+	}
+
+	if w.frames == 0 {
+		w.bounds = frame.Rect
+		if w.bounds.Dx() > 0xffff || w.bounds.Dy() > 0xffff {
+			return fmt.Errorf("a GIF can not be %vx%v", w.bounds.Dx(), w.bounds.Dy())
+		}
+		w.writeHeader()
+	} else if frame.Rect != w.bounds {
+		return fmt.Errorf("frame %v is %v, not the %v of the first frame", w.frames, frame.Rect, w.bounds)
+	}
+
+	if err := w.writeImage(frame); err != nil {
+		return err
+	}
+	w.frames++
+
+	// Hand each frame to the file as it is written
+	return w.out.Flush()
+}
+
+/*
+ * Write the GIF header, the web safe palette as the global colour table and the extension
+ * that loops the animation forever
+ */
+func (w *GIFWriter) writeHeader() {
+
+	var screen [13]byte
+	copy(screen[:], "GIF89a")
+	binary.LittleEndian.PutUint16(screen[6:], uint16(w.bounds.Dx()))
+	binary.LittleEndian.PutUint16(screen[8:], uint16(w.bounds.Dy()))
+	screen[10] = 0xf7 // Global colour table of 256 colours, 8 bits per channel
+	w.out.Write(screen[:])
+
+	// The 216 web safe colours padded with black
+	table := make([]byte, 3*256)
+	for i, c := range palette.WebSafe {
+		r, g, b, _ := c.RGBA()
+		table[3*i], table[3*i+1], table[3*i+2] = byte(r>>8), byte(g>>8), byte(b>>8)
+	}
+	w.out.Write(table)
+
+	w.out.Write([]byte{0x21, 0xff, 0x0b})
+	w.out.WriteString("NETSCAPE2.0")
+	w.out.Write([]byte{0x03, 0x01, 0x00, 0x00, 0x00})
+}
+
+/*
+ * Write a frame as its delay and an LZW compressed image using the global colour table
+ */
+func (w *GIFWriter) writeImage(frame *image.Paletted) error {
+
+	control := []byte{0x21, 0xf9, 0x04, 0x00, 0, 0, 0x00, 0x00}
+	binary.LittleEndian.PutUint16(control[4:], GIFDelay)
+	w.out.Write(control)
+
+	var descriptor [10]byte
+	descriptor[0] = 0x2c
+	binary.LittleEndian.PutUint16(descriptor[5:], uint16(w.bounds.Dx()))
+	binary.LittleEndian.PutUint16(descriptor[7:], uint16(w.bounds.Dy()))
+	w.out.Write(descriptor[:])
+
+	// Codes start at 8 bits, and the data is split into sub-blocks ending with an empty one
+	w.out.WriteByte(8)
+	blocks := &gifBlocks{out: w.out}
+	lzwOut := lzw.NewWriter(blocks, lzw.LSB, 8)
+	if _, err := lzwOut.Write(frame.Pix); err != nil {
+		return err
+	}
+	if err := lzwOut.Close(); err != nil {
+		return err
+	}
+	blocks.flush()
+	return w.out.WriteByte(0x00)
+}
+
+/*
+ * Finish the animation and close the file
+ */
+func (w *GIFWriter) Close() error {
+
+	if w.frames > 0 {
+		w.out.WriteByte(0x3b)
+	}
+	err := w.out.Flush()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && w.frames == 0 {
+		err = fmt.Errorf("no frames were written into %v", w.Path)
+	}
+	return err
+}
+
+// gifBlocks splits image data into the sub-blocks of at most 255 bytes a GIF stores it in
+type gifBlocks struct {
+	out *bufio.Writer // Writer of the animation
+	buf [255]byte     // Data of the sub-block being filled
+	n   int           // Number of bytes in buf
+}
+
+/*
+ * Add data to the sub-blocks, writing each once it is full
+ */
+func (b *gifBlocks) Write(p []byte) (int, error) {
+	for _, c := range p {
+		b.buf[b.n] = c
+		b.n++
+		if b.n == len(b.buf) {
+			b.flush()
+		}
+	}
+	return len(p), nil
+}
+
+/*
+ * Write the sub-block being filled, if it holds anything
+ */
+func (b *gifBlocks) flush() {
+	if b.n > 0 {
+		b.out.WriteByte(byte(b.n))
+		b.out.Write(b.buf[:b.n])
+		b.n = 0
+	}
+}
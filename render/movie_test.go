@@ -0,0 +1,107 @@
+package render
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPNGWriter(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "frames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewPNGWriter(filepath.Join(dir, "run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := w.WriteFrame(NewCanvas(4, 3, color.RGBA{0, 0, 0, 255})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "run", "frame_00001.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 4, 3) {
+		t.Errorf("bounds = %v, want 4x3", img.Bounds())
+	}
+}
+
+func TestGIFWriter(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "frames")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Every colour in the palette is kept exactly
+	canvas := NewCanvas(len(palette.WebSafe), 1, color.RGBA{0, 0, 0, 255})
+	for i, c := range palette.WebSafe {
+		canvas.Set(i, 0, c)
+	}
+
+	path := filepath.Join(dir, "run.gif")
+	w, err := NewGIFWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(0)
+	for i := 0; i < 3; i++ {
+		if err := w.WriteFrame(canvas); err != nil {
+			t.Fatal(err)
+		}
+
+		// Each frame is in the file before the next is drawn
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() <= size {
+			t.Errorf("file is %v bytes after frame %v, was %v", info.Size(), i, size)
+		}
+		size = info.Size()
+	}
+	if err := w.WriteFrame(NewCanvas(2, 2, color.RGBA{0, 0, 0, 255})); err == nil {
+		t.Error("WriteFrame accepted a frame of another size")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 || anim.LoopCount != 0 || anim.Delay[2] != GIFDelay {
+		t.Fatalf("%v frames, loop count %v and delays %v, want 3, 0 and %v", len(anim.Image), anim.LoopCount,
+			anim.Delay, GIFDelay)
+	}
+	for i, c := range palette.WebSafe {
+		if got := anim.Image[0].ColorIndexAt(i, 0); int(got) != i {
+			t.Errorf("colour %v has index %v, want %v", c, got, i)
+		}
+	}
+}
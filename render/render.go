@@ -0,0 +1,93 @@
+package render
+
+import (
+	"fmt"
+)
+
+// ColourMap is the quantity bodies are coloured by
+type ColourMap int
+
+// Colour maps
+const (
+	Plain   ColourMap = iota // Every body is green
+	Speed                    // Speed of the body
+	Mass                     // Mass of the body
+	Kinetic                  // Kinetic energy of the body
+	Density                  // Mass density around the body
+	Tag                      // Scenario component the body was generated in
+)
+
+var colourMapNames = []string{"none", "speed", "mass", "energy", "density", "component"}
+
+/*
+ * Return the name of the colour map
+ */
+func (c ColourMap) String() string {
+	if c < 0 || int(c) >= len(colourMapNames) {
+		return fmt.Sprintf("ColourMap(%d)", int(c))
+	}
+	return colourMapNames[c]
+}
+
+/*
+ * Return the colour map with the given name
+ */
+func ParseColourMap(name string) (ColourMap, error) {
+	for i, n := range colourMapNames {
+		if n == name {
+			return ColourMap(i), nil
+		}
+	}
+	return Plain, fmt.Errorf("unknown colour map %q", name)
+}
+
+/*
+ * Return the colour map after this one, going back to the first after the last
+ */
+func (c ColourMap) Next() ColourMap {
+	return (c + 1) % ColourMap(len(colourMapNames))
+}
+
+// Opacity of bodies drawn with the additive style
+const AdditiveAlpha = 0.35
+
+// Style is how bodies are drawn
+type Style int
+
+// Drawing styles
+const (
+	Outline  Style = iota // Circle outlines
+	Filled                // Filled circles
+	Additive              // Translucent filled circles whose colours add up, so dense clusters glow
+)
+
+var styleNames = []string{"outline", "filled", "additive"}
+
+/*
+ * Return the name of the style
+ */
+func (s Style) String() string {
+	if s < 0 || int(s) >= len(styleNames) {
+		return fmt.Sprintf("Style(%d)", int(s))
+	}
+	return styleNames[s]
+}
+
+/*
+ * Return the style with the given name
+ */
+func ParseStyle(name string) (Style, error) {
+	for i, n := range styleNames {
+		if n == name {
+			return Style(i), nil
+		}
+	}
+	return Outline, fmt.Errorf("unknown style %q", name)
+}
+
+/*
+ * Return the style after this one, going back to the first after the last
+ */
+func (s Style) Next() Style {
+	return (s + 1) % Style(len(styleNames))
+}
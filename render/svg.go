@@ -0,0 +1,181 @@
+package render
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"io"
+	"math"
+	"proj3/phys"
+	"strings"
+)
+
+// SVGOptions sets what a snapshot shows and how it is styled
+type SVGOptions struct {
+	Colours     ColourMap      // Quantity the bodies are coloured by
+	Style       Style          // How the bodies are drawn. Additive uses the screen blend mode
+	Tags        map[int]string // Scenario component of each body by Id
+	RadiusScale float64        // Factor the radius of each body is drawn at
+	MinRadius   float64        // Smallest radius a body is drawn at, so light bodies stay visible
+	Velocity    float64        // Length of the velocity arrows for each unit of speed. 0 draws none
+	Stroke      float64        // Width of the lines
+	Background  string         // Colour of the background, or "none"
+	CellColour  string         // Colour of the tree cells
+	ArrowColour string         // Colour of the velocity arrows
+	Legend      bool           // Whether to draw the colour legend
+}
+
+/*
+ * Return the options of a snapshot drawn like the GUI
+ */
+func NewSVGOptions() SVGOptions {
+	return SVGOptions{RadiusScale: 1, Stroke: 1, Background: "black", CellColour: "#0079f1", ArrowColour: "white"}
+}
+
+/*
+ * Return the SVG colour of a raylib colour
+ */
+func hex(c rl.Color) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+/*
+ * Write a snapshot of the bodies as an SVG in simulation units
+ *
+ * w: Writer to write the SVG into
+ * bodies: slice of physics bodies
+ * cells: tree cells to draw under the bodies. nil draws none
+ * box: part of the simulation the SVG shows
+ * opts: what to show and how to style it
+ *
+ * return: an error if the SVG could not be written
+ */
+func WriteSVG(w io.Writer, bodies []phys.Body, cells []rl.Rectangle, box rl.Rectangle, opts SVGOptions) error {
+
+	// The colours come from the user, so are escaped like any other text
+	background, cellColour, arrowColour := escapeXML(opts.Background), escapeXML(opts.CellColour),
+		escapeXML(opts.ArrowColour)
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%v\" height=\"%v\" viewBox=\"%v %v %v %v\" "+
+		"font-family=\"sans-serif\" font-size=\"12\">\n", box.Width, box.Height, box.X, box.Y, box.Width, box.Height)
+	if opts.Background != "none" {
+		fmt.Fprintf(&svg, "<rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\" fill=\"%v\"/>\n",
+			box.X, box.Y, box.Width, box.Height, background)
+	}
+
+	if len(cells) > 0 {
+		fmt.Fprintf(&svg, "<g id=\"cells\" fill=\"none\" stroke=\"%v\" stroke-width=\"%v\">\n", cellColour, opts.Stroke)
+		for _, c := range cells {
+			fmt.Fprintf(&svg, "<rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\"/>\n", c.X, c.Y, c.Width, c.Height)
+		}
+		svg.WriteString("</g>\n")
+	}
+
+	colours, legend := Colours(opts.Colours, bodies, opts.Tags)
+
+	// Bodies in the filled styles are painted, outlines are stroked
+	switch opts.Style {
+	case Outline:
+		fmt.Fprintf(&svg, "<g id=\"bodies\" fill=\"none\" stroke-width=\"%v\">\n", opts.Stroke)
+	case Filled:
+		svg.WriteString("<g id=\"bodies\" stroke=\"none\">\n")
+	case Additive:
+		fmt.Fprintf(&svg, "<g id=\"bodies\" stroke=\"none\" fill-opacity=\"%v\" style=\"isolation:isolate\">\n", AdditiveAlpha)
+	}
+	for i := range bodies {
+		radius := math.Max(float64(bodies[i].Radius)*opts.RadiusScale, opts.MinRadius)
+		paint := fmt.Sprintf("fill=\"%v\"", hex(colours[i]))
+		switch opts.Style {
+		case Outline:
+			paint = fmt.Sprintf("stroke=\"%v\"", hex(colours[i]))
+		case Additive:
+			paint += " style=\"mix-blend-mode:screen\""
+		}
+		fmt.Fprintf(&svg, "<circle cx=\"%v\" cy=\"%v\" r=\"%.4g\" %v/>\n",
+			bodies[i].Position.X, bodies[i].Position.Y, radius, paint)
+	}
+	svg.WriteString("</g>\n")
+
+	if opts.Velocity > 0 {
+		fmt.Fprintf(&svg, "<defs><marker id=\"arrow\" viewBox=\"0 0 10 10\" refX=\"10\" refY=\"5\" markerWidth=\"6\" "+
+			"markerHeight=\"6\" orient=\"auto\"><path d=\"M0,0 L10,5 L0,10 z\" fill=\"%v\"/></marker></defs>\n",
+			arrowColour)
+		fmt.Fprintf(&svg, "<g id=\"velocities\" stroke=\"%v\" stroke-width=\"%v\" marker-end=\"url(#arrow)\">\n",
+			arrowColour, opts.Stroke)
+		for i := range bodies {
+			if bodies[i].Velocity.X == 0 && bodies[i].Velocity.Y == 0 {
+				continue
+			}
+			fmt.Fprintf(&svg, "<line x1=\"%v\" y1=\"%v\" x2=\"%.4f\" y2=\"%.4f\"/>\n",
+				bodies[i].Position.X, bodies[i].Position.Y,
+				float64(bodies[i].Position.X)+float64(bodies[i].Velocity.X)*opts.Velocity,
+				float64(bodies[i].Position.Y)+float64(bodies[i].Velocity.Y)*opts.Velocity)
+		}
+		svg.WriteString("</g>\n")
+	}
+
+	if opts.Legend {
+		writeSVGLegend(&svg, legend, box, opts)
+	}
+
+	svg.WriteString("</svg>\n")
+	_, err := io.WriteString(w, svg.String())
+	return err
+}
+
+/*
+ * Write the legend of the colours into the bottom left corner of the SVG
+ */
+func writeSVGLegend(svg *strings.Builder, legend Legend, box rl.Rectangle, opts SVGOptions) {
+
+	text := "white"
+	if opts.Background != "black" && opts.Background != "none" {
+		text = "black"
+	}
+	x, bottom := float64(box.X)+20, float64(box.Y+box.Height)-20
+	fmt.Fprintf(svg, "<g id=\"legend\" fill=\"%v\">\n", text)
+
+	switch legend.Map {
+	case Tag:
+		for i := len(legend.Tags) - 1; i >= 0; i-- {
+			tag := legend.Tags[i]
+			if tag == "" {
+				tag = "(none)"
+			}
+			y := bottom - float64(len(legend.Tags)-1-i)*18
+			fmt.Fprintf(svg, "<rect x=\"%v\" y=\"%v\" width=\"12\" height=\"12\" fill=\"%v\"/>\n",
+				x, y-12, hex(legend.Colours[i]))
+			fmt.Fprintf(svg, "<text x=\"%v\" y=\"%v\">%v</text>\n", x+18, y-1, escapeXML(tag))
+		}
+		bottom -= float64(len(legend.Tags))*18 + 4
+
+	case Speed, Mass, Kinetic, Density:
+		const width = 200
+		svg.WriteString("<linearGradient id=\"ramp\">")
+		for i := range ramp {
+			fmt.Fprintf(svg, "<stop offset=\"%v\" stop-color=\"%v\"/>", float64(i)/float64(len(ramp)-1), hex(ramp[i]))
+		}
+		svg.WriteString("</linearGradient>\n")
+		fmt.Fprintf(svg, "<rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"12\" fill=\"url(#ramp)\"/>\n", x, bottom-12, width)
+		fmt.Fprintf(svg, "<text x=\"%v\" y=\"%v\">%.3g</text>\n", x, bottom-16, legend.Min)
+		fmt.Fprintf(svg, "<text x=\"%v\" y=\"%v\" text-anchor=\"end\">%.3g</text>\n", x+width, bottom-16, legend.Max)
+		if legend.Log {
+			fmt.Fprintf(svg, "<text x=\"%v\" y=\"%v\" text-anchor=\"middle\">log</text>\n", x+width/2, bottom-16)
+		}
+		bottom -= 32
+
+	default:
+		svg.WriteString("</g>\n")
+		return
+	}
+
+	fmt.Fprintf(svg, "<text x=\"%v\" y=\"%v\">%v</text>\n", x, bottom-4, legend.Map)
+	svg.WriteString("</g>\n")
+}
+
+/*
+ * Return text with the characters XML reserves escaped
+ */
+func escapeXML(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\"", "&quot;").Replace(s)
+}
@@ -0,0 +1,128 @@
+package render
+
+import (
+	"encoding/xml"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"io"
+	"proj3/phys"
+	"strings"
+	"testing"
+)
+
+/*
+ * Return the number of each element in an SVG, failing if it is not well formed XML
+ */
+func countElements(t *testing.T, svg string) map[string]int {
+
+	counts := make(map[string]int)
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return counts
+		}
+		if err != nil {
+			t.Fatalf("SVG is not well formed: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			counts[start.Name.Local]++
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+
+	bodies := []phys.Body{
+		phys.NewBody(1, 0, rl.NewVector2(10, 10), rl.NewVector2(1, 0)),
+		phys.NewBody(2, 1, rl.NewVector2(50, 20), rl.NewVector2(0, 0)),
+	}
+	cells := []rl.Rectangle{rl.NewRectangle(0, 0, 100, 100), rl.NewRectangle(0, 0, 50, 50)}
+	opts := NewSVGOptions()
+	opts.Velocity = 10
+	opts.Colours = Tag
+	opts.Tags = map[int]string{0: "a<b"}
+	opts.Legend = true
+
+	var svg strings.Builder
+	if err := WriteSVG(&svg, bodies, cells, rl.NewRectangle(0, 0, 100, 100), opts); err != nil {
+		t.Fatal(err)
+	}
+	counts := countElements(t, svg.String())
+
+	// Background, two cells and two legend entries; one arrow as the second body is at rest
+	if counts["circle"] != 2 || counts["rect"] != 5 || counts["line"] != 1 {
+		t.Errorf("elements = %v", counts)
+	}
+	if !strings.Contains(svg.String(), `x2="20.0000" y2="10.0000"`) {
+		t.Error("velocity arrow does not end at the position plus 10 times the velocity")
+	}
+	if !strings.Contains(svg.String(), `r="3" stroke="#00e430"`) {
+		t.Error("first body is not a green outline of radius 3")
+	}
+}
+
+func TestWriteSVGMinRadius(t *testing.T) {
+
+	bodies := []phys.Body{phys.NewBody(0.01, 0, rl.NewVector2(10, 10), rl.NewVector2(0, 0))}
+	opts := NewSVGOptions()
+	opts.Style = Filled
+	opts.MinRadius = 2
+	opts.Background = "none"
+
+	var svg strings.Builder
+	if err := WriteSVG(&svg, bodies, nil, rl.NewRectangle(0, 0, 100, 100), opts); err != nil {
+		t.Fatal(err)
+	}
+	counts := countElements(t, svg.String())
+
+	if counts["rect"] != 0 || counts["line"] != 0 {
+		t.Errorf("elements = %v, want no background or arrows", counts)
+	}
+	if !strings.Contains(svg.String(), `r="2" fill="#00e430"`) {
+		t.Errorf("light body is not filled at the smallest radius: %v", svg.String())
+	}
+}
+
+func TestWriteSVGEscapesColours(t *testing.T) {
+
+	bodies := []phys.Body{phys.NewBody(1, 0, rl.NewVector2(10, 10), rl.NewVector2(1, 0))}
+	cells := []rl.Rectangle{rl.NewRectangle(0, 0, 100, 100)}
+	opts := NewSVGOptions()
+	opts.Velocity = 10
+	opts.Background = `red" onload="alert(1)`
+	opts.CellColour = `<blue>`
+	opts.ArrowColour = `a&b`
+
+	var svg strings.Builder
+	if err := WriteSVG(&svg, bodies, cells, rl.NewRectangle(0, 0, 100, 100), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each colour is one attribute value, not extra attributes or elements
+	want := map[string]bool{opts.Background: false, opts.CellColour: false, opts.ArrowColour: false}
+	dec := xml.NewDecoder(strings.NewReader(svg.String()))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("SVG is not well formed: %v", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "onload" {
+					t.Errorf("background added an attribute to %v", start.Name.Local)
+				}
+				if _, ok := want[attr.Value]; ok {
+					want[attr.Value] = true
+				}
+			}
+		}
+	}
+	for colour, found := range want {
+		if !found {
+			t.Errorf("colour %q is not an attribute value", colour)
+		}
+	}
+}
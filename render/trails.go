@@ -0,0 +1,75 @@
+package render
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/phys"
+)
+
+// Trails holds the last positions of each body
+type Trails struct {
+	Length int                  // Number of positions kept for each body. 0 keeps none
+	points map[int][]rl.Vector2 // Positions of each body by Id, oldest first
+}
+
+/*
+ * Return trails keeping the last length positions of each body
+ */
+func NewTrails(length int) *Trails {
+	return &Trails{Length: length, points: make(map[int][]rl.Vector2)}
+}
+
+/*
+ * Add the current position of each body to its trail
+ * Trails of bodies that are gone, because they merged or were absorbed, are dropped
+ *
+ * bodies: slice of physics bodies
+ */
+func (t *Trails) Record(bodies []phys.Body) {
+
+	if t.Length <= 0 {
+		t.Clear()
+		return
+	}
+
+	points := make(map[int][]rl.Vector2, len(bodies))
+	for i := range bodies {
+		trail := append(t.points[bodies[i].Id], bodies[i].Position)
+		if len(trail) > t.Length {
+			trail = trail[len(trail)-t.Length:]
+		}
+		points[bodies[i].Id] = trail
+	}
+
+	t.points = points
+}
+
+/*
+ * Return the positions of a body, oldest first
+ */
+func (t *Trails) Points(id int) []rl.Vector2 {
+	return t.points[id]
+}
+
+/*
+ * Forget every position
+ */
+func (t *Trails) Clear() {
+	t.points = make(map[int][]rl.Vector2)
+}
+
+/*
+ * Return the opacity of the segment ending at point i of a trail of n points
+ * Older segments fade out
+ */
+func Fade(i, n int) float32 {
+	return float32(i) / float32(n)
+}
+
+/*
+ * Return whether a trail runs continuously between two positions
+ * A jump of more than half the box is a body wrapping around a periodic boundary
+ */
+func Continuous(a, b rl.Vector2, box rl.Rectangle) bool {
+	dx, dy := b.X-a.X, b.Y-a.Y
+	return dx*dx <= box.Width*box.Width/4 && dy*dy <= box.Height*box.Height/4
+}
@@ -0,0 +1,47 @@
+package render
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/phys"
+	"testing"
+)
+
+func TestTrails(t *testing.T) {
+
+	trails := NewTrails(3)
+	bodies := []phys.Body{
+		phys.NewBody(1, 0, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+		phys.NewBody(1, 1, rl.NewVector2(0, 0), rl.NewVector2(0, 0)),
+	}
+	for step := 0; step < 5; step++ {
+		bodies[0].Position.X = float32(step)
+		trails.Record(bodies)
+	}
+
+	points := trails.Points(0)
+	if len(points) != 3 || points[0].X != 2 || points[2].X != 4 {
+		t.Errorf("Points(0) = %v, want the last 3 positions", points)
+	}
+
+	// The trail of a merged body is dropped
+	trails.Record(bodies[:1])
+	if len(trails.Points(1)) != 0 {
+		t.Errorf("Points(1) = %v, want none", trails.Points(1))
+	}
+
+	trails.Length = 0
+	trails.Record(bodies)
+	if len(trails.Points(0)) != 0 {
+		t.Errorf("Points(0) = %v after turning trails off", trails.Points(0))
+	}
+}
+
+func TestContinuous(t *testing.T) {
+	box := rl.NewRectangle(0, 0, 100, 100)
+	if !Continuous(rl.NewVector2(10, 10), rl.NewVector2(12, 9), box) {
+		t.Error("a small step is not continuous")
+	}
+	if Continuous(rl.NewVector2(1, 50), rl.NewVector2(99, 50), box) {
+		t.Error("wrapping around the box is continuous")
+	}
+}
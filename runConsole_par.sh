@@ -1,3 +1,3 @@
 #!/bin/bash
 
-go run main/sim.go -i=300 960 540 6 < main/test_data.txt
+go run ./main -i=300 960 540 6 < main/test_data.txt
@@ -1,3 +1,3 @@
 #!/bin/bash
 
-go run main/sim.go -w 960 540 8 < main/test_data.txt
+go run ./main -w 960 540 8 < main/test_data.txt
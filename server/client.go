@@ -0,0 +1,211 @@
+package server
+
+// Browser client served at /. It draws the stream of the chosen run on a canvas and drives the REST API
+const clientHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>N-Body Simulation</title>
+<style>
+body { background: #111; color: #ddd; font: 14px sans-serif; margin: 12px; }
+canvas { background: black; display: block; margin-top: 8px; max-width: 100%; cursor: crosshair; }
+input { width: 5em; }
+#error { color: #f66; }
+</style>
+</head>
+<body>
+<div>
+run <select id="runs"></select>
+<button id="create">New run</button>
+<button id="delete">Delete run</button>
+<span id="scheduler"></span>
+</div>
+<div>
+<button id="toggle">Resume</button>
+<button id="step">Step</button>
+dt <input id="dt" type="number" step="0.05">
+steps/s <input id="rate" type="number" step="1" min="1">
+boundary <select id="boundary">
+<option>open</option><option>reflective</option><option>periodic</option><option>absorbing</option>
+</select>
+new body mass <input id="mass" type="number" value="1" step="0.5" min="0.01">
+<span id="status"></span> <span id="error"></span>
+</div>
+<canvas id="view"></canvas>
+<div>Click the canvas to add a body, dragging to give it a velocity. Shift+click a body to remove it.</div>
+<script>
+"use strict";
+const canvas = document.getElementById("view");
+const ctx = canvas.getContext("2d");
+let state = null;
+let drag = null;
+let runId = null;
+let socket = null;
+
+function request(method, url, body) {
+  return fetch(url, {
+    method: method,
+    headers: {"Content-Type": "application/json"},
+    body: body === undefined ? undefined : JSON.stringify(body)
+  }).then(r => r.status === 204 ? null : r.json().then(v => {
+    document.getElementById("error").textContent = v && v.Error ? v.Error : "";
+    return v;
+  }));
+}
+
+function api(method, path, body) {
+  return request(method, "/api/runs/" + encodeURIComponent(runId) + path, body);
+}
+
+function refreshRuns() {
+  request("GET", "/api/scheduler").then(s => {
+    document.getElementById("scheduler").textContent =
+      s.Busy + "/" + s.Limit + " goroutines busy, " + s.Active + " running";
+  });
+  return request("GET", "/api/runs").then(runs => {
+    const select = document.getElementById("runs");
+    select.innerHTML = "";
+    for (const r of runs) {
+      const option = document.createElement("option");
+      option.value = r.Id;
+      option.textContent = r.Id + " (" + r.Count + " bodies" + (r.Paused ? ", paused)" : ")");
+      select.appendChild(option);
+    }
+    if (!runs.some(r => r.Id === runId)) {
+      choose(runs.length > 0 ? runs[0].Id : null);
+    }
+    select.value = runId;
+  });
+}
+
+function choose(id) {
+  runId = id;
+  state = null;
+  if (socket) {
+    socket.onclose = null;
+    socket.close();
+    socket = null;
+  }
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  if (runId !== null) {
+    connect();
+  }
+}
+
+function draw() {
+  if (!state) {
+    return;
+  }
+  if (canvas.width !== state.Width || canvas.height !== state.Height) {
+    canvas.width = state.Width;
+    canvas.height = state.Height;
+  }
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  ctx.strokeStyle = "#00e430";
+  for (const b of state.Bodies || []) {
+    ctx.beginPath();
+    ctx.arc(b.Position[0], b.Position[1], Math.max(b.Mass * 3, 0.5), 0, 2 * Math.PI);
+    ctx.stroke();
+  }
+  if (drag) {
+    ctx.strokeStyle = "#66bfff";
+    ctx.beginPath();
+    ctx.moveTo(drag.x, drag.y);
+    ctx.lineTo(drag.toX, drag.toY);
+    ctx.stroke();
+  }
+  document.getElementById("status").textContent =
+    "step " + state.Step + ", t = " + state.Time.toFixed(1) + ", " + state.Count + " bodies, " +
+    state.Solver + ", " + state.Granted + "/" + state.Workers + " goroutines" + (state.Paused ? ", paused" : "");
+  document.getElementById("toggle").textContent = state.Paused ? "Resume" : "Pause";
+}
+
+function showSettings(s) {
+  document.getElementById("dt").value = s.Dt;
+  document.getElementById("rate").value = s.Rate;
+  document.getElementById("boundary").value = s.Boundary;
+}
+
+function connect() {
+  const ws = new WebSocket((location.protocol === "https:" ? "wss://" : "ws://") + location.host +
+    "/api/runs/" + encodeURIComponent(runId) + "/stream");
+  socket = ws;
+  let first = true;
+  ws.onmessage = e => {
+    state = JSON.parse(e.data);
+    if (first) {
+      showSettings(state);
+      first = false;
+    }
+    draw();
+  };
+  // A closed stream is reopened, or another run chosen if this one was deleted
+  ws.onclose = () => {
+    socket = null;
+    setTimeout(() => refreshRuns().then(() => {
+      if (!socket && runId !== null) {
+        connect();
+      }
+    }), 1000);
+  };
+}
+
+function position(e) {
+  const rect = canvas.getBoundingClientRect();
+  return [(e.clientX - rect.left) * canvas.width / rect.width, (e.clientY - rect.top) * canvas.height / rect.height];
+}
+
+canvas.addEventListener("mousedown", e => {
+  const [x, y] = position(e);
+  if (e.shiftKey && state) {
+    for (const b of state.Bodies || []) {
+      if (Math.hypot(b.Position[0] - x, b.Position[1] - y) <= Math.max(b.Mass * 3, 5)) {
+        api("DELETE", "/bodies/" + b.Id);
+        return;
+      }
+    }
+    return;
+  }
+  drag = {x: x, y: y, toX: x, toY: y};
+});
+canvas.addEventListener("mousemove", e => {
+  if (drag) {
+    [drag.toX, drag.toY] = position(e);
+    draw();
+  }
+});
+canvas.addEventListener("mouseup", () => {
+  if (!drag) {
+    return;
+  }
+  const mass = parseFloat(document.getElementById("mass").value);
+  api("POST", "/bodies", [{Mass: mass, Position: [drag.x, drag.y],
+    Velocity: [(drag.toX - drag.x) * 0.02, (drag.toY - drag.y) * 0.02]}]);
+  drag = null;
+});
+
+document.getElementById("runs").onchange = e => choose(e.target.value);
+document.getElementById("create").onclick = () =>
+  request("POST", "/api/runs", {Width: canvas.width || 960, Height: canvas.height || 540}).then(r => {
+    if (r && r.Id) {
+      choose(r.Id);
+      refreshRuns();
+    }
+  });
+document.getElementById("delete").onclick = () => {
+  if (runId !== null) {
+    api("DELETE", "").then(refreshRuns);
+  }
+};
+document.getElementById("toggle").onclick = () => api("POST", state && state.Paused ? "/resume" : "/pause");
+document.getElementById("step").onclick = () => api("POST", "/step");
+document.getElementById("dt").onchange = e => api("PATCH", "", {Dt: parseFloat(e.target.value)});
+document.getElementById("rate").onchange = e => api("PATCH", "", {Rate: parseFloat(e.target.value)});
+document.getElementById("boundary").onchange = e => api("PATCH", "", {Boundary: e.target.value});
+
+refreshRuns();
+setInterval(refreshRuns, 2000);
+</script>
+</body>
+</html>
+`
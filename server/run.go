@@ -0,0 +1,432 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/engine"
+	"proj3/phys"
+	"sync"
+	"time"
+)
+
+// Default number of time-steps a run takes each second
+const DefaultRate = 30
+
+// Largest number of time-steps a run can take each second
+const MaxRate = 1000
+
+// Body is the JSON form of a body, as in the input files
+type Body struct {
+	Id       int
+	Mass     float32
+	Position [2]float32
+	Velocity [2]float32
+}
+
+// NewBody is a body to add to a run. A missing Id is given the next free one
+type NewBody struct {
+	Id       *int
+	Mass     float32
+	Position [2]float32
+	Velocity [2]float32
+}
+
+// Params are the settings of a run that can be changed while it runs. Missing settings are left as they are
+type Params struct {
+	Solver   *string  // Gravity solver: tree, pm or treepm
+	Grid     *int     // Number of mesh cells per side for pm and treepm
+	Boundary *string  // Boundary condition: open, reflective, periodic or absorbing
+	Dt       *float32 // Timestep
+	Rate     *float64 // Number of time-steps taken each second
+	Workers  *int     // Number of goroutines the forces are calculated with
+	Paused   *bool    // Whether the run is paused
+}
+
+// State is the JSON form of a run at a time-step
+type State struct {
+	Id       string `json:",omitempty"`
+	Step     int
+	Time     float64
+	Paused   bool
+	Rate     float64
+	Workers  int
+	Granted  int // Number of goroutines the scheduler gave the last time-step
+	Width    int
+	Height   int
+	Solver   string
+	Grid     int
+	Boundary string
+	Dt       float32
+	Count    int    // Number of bodies
+	Bodies   []Body `json:",omitempty"`
+}
+
+// Run is a simulation stepped in the background and streamed to its subscribers
+type Run struct {
+	mtx         sync.Mutex
+	sim         *engine.Sim
+	paused      bool
+	rate        float64
+	workers     int
+	granted     int
+	id          string               // Id the run is served under
+	sched       *Scheduler           // Scheduler the goroutines are shared by. Unlimited if nil
+	active      bool                 // Whether the run is counted as running by the scheduler
+	subscribers map[chan []byte]bool // Channels each step is sent into
+	stop        chan bool            // Closed to stop the run
+}
+
+/*
+ * Return a paused run of the bodies
+ *
+ * config: settings of the simulation
+ * bodies: bodies to start with
+ * workers: number of goroutines the forces are calculated with
+ */
+func NewRun(config engine.Config, bodies []NewBody, workers int) (*Run, error) {
+
+	sim, err := engine.New(config, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Run{sim: sim, paused: true, rate: DefaultRate, workers: workers,
+		subscribers: make(map[chan []byte]bool), stop: make(chan bool)}
+	if _, err := r.Add(bodies); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+/*
+ * Start stepping the run in the background
+ */
+func (r *Run) Start() {
+
+	r.mtx.Lock()
+	r.schedule()
+	r.mtx.Unlock()
+
+	go r.loop()
+}
+
+/*
+ * Stop the run and close the channels of its subscribers
+ */
+func (r *Run) Stop() {
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	close(r.stop)
+	r.schedule()
+	for c := range r.subscribers {
+		close(c)
+	}
+	r.subscribers = make(map[chan []byte]bool)
+}
+
+/*
+ * Return whether the run has been stopped. Must be called holding the lock
+ */
+func (r *Run) stopped() bool {
+	select {
+	case <-r.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+ * Count the run as running with the scheduler while it is neither paused nor stopped. Must be called holding the lock
+ */
+func (r *Run) schedule() {
+
+	running := r.sched != nil && !r.paused && !r.stopped()
+	if running && !r.active {
+		r.sched.join()
+	} else if !running && r.active {
+		r.sched.leave()
+	}
+	r.active = running
+}
+
+/*
+ * Step the run at its rate until it is stopped
+ */
+func (r *Run) loop() {
+	for {
+		r.mtx.Lock()
+		period := time.Duration(float64(time.Second) / r.rate)
+		r.mtx.Unlock()
+
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(period):
+			r.tick(false)
+		}
+	}
+}
+
+/*
+ * Take a time-step, unless the run is paused and the step is not forced, and send it to the subscribers
+ *
+ * force: whether to step a paused run
+ */
+func (r *Run) tick(force bool) {
+
+	r.mtx.Lock()
+	if r.paused && !force {
+		r.mtx.Unlock()
+		return
+	}
+	workers, sched := r.workers, r.sched
+	r.mtx.Unlock()
+
+	// Wait for the goroutines without the lock, so the run can be read and changed meanwhile
+	if sched != nil {
+		workers = sched.Acquire(workers)
+		defer sched.Release(workers)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.paused && !force {
+		return
+	}
+	r.sim.Advance(workers)
+	r.granted = workers
+
+	if len(r.subscribers) > 0 {
+		r.broadcast()
+	}
+}
+
+/*
+ * Take a single time-step, even if the run is paused
+ */
+func (r *Run) Step() State {
+	r.tick(true)
+	return r.State(false)
+}
+
+/*
+ * Send the state to every subscriber. Must be called holding the lock
+ * A subscriber still holding an older state has it replaced, so slow clients skip steps
+ */
+func (r *Run) broadcast() {
+
+	message, err := json.Marshal(r.state(true))
+	if err != nil {
+		return
+	}
+
+	for c := range r.subscribers {
+		select {
+		case c <- message:
+		default:
+			select {
+			case <-c:
+			default:
+			}
+			c <- message
+		}
+	}
+}
+
+/*
+ * Return a channel each step is sent into, starting with the current one
+ */
+func (r *Run) Subscribe() chan []byte {
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	c := make(chan []byte, 1)
+	if message, err := json.Marshal(r.state(true)); err == nil {
+		c <- message
+	}
+
+	// A stopped run sends nothing more
+	select {
+	case <-r.stop:
+		close(c)
+	default:
+		r.subscribers[c] = true
+	}
+
+	return c
+}
+
+/*
+ * Stop sending steps into a channel
+ */
+func (r *Run) Unsubscribe(c chan []byte) {
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.subscribers[c] {
+		delete(r.subscribers, c)
+		close(c)
+	}
+}
+
+/*
+ * Return the state of the run
+ *
+ * bodies: whether to include every body
+ */
+func (r *Run) State(bodies bool) State {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	return r.state(bodies)
+}
+
+/*
+ * Return the state of the run. Must be called holding the lock
+ */
+func (r *Run) state(bodies bool) State {
+
+	c := r.sim.Config
+	s := State{Id: r.id, Step: r.sim.Step, Time: r.sim.Time, Paused: r.paused, Rate: r.rate, Workers: r.workers,
+		Granted: r.granted, Width: c.Width, Height: c.Height, Solver: c.Solver, Grid: c.Grid,
+		Boundary: c.Boundary.String(), Dt: c.Dt, Count: len(r.sim.Bodies)}
+
+	if bodies {
+		s.Bodies = make([]Body, len(r.sim.Bodies))
+		for i, b := range r.sim.Bodies {
+			s.Bodies[i] = Body{b.Id, b.Mass, [2]float32{b.Position.X, b.Position.Y},
+				[2]float32{b.Velocity.X, b.Velocity.Y}}
+		}
+	}
+
+	return s
+}
+
+/*
+ * Change the settings of the run. Nothing is changed if any setting is invalid
+ */
+func (r *Run) Set(p Params) error {
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	config := r.sim.Config
+	if p.Solver != nil {
+		config.Solver = *p.Solver
+	}
+	if p.Grid != nil {
+		config.Grid = *p.Grid
+	}
+	if p.Boundary != nil {
+		boundary, err := phys.ParseBoundary(*p.Boundary)
+		if err != nil {
+			return err
+		}
+		config.Boundary = boundary
+	}
+	if p.Dt != nil {
+		config.Dt = *p.Dt
+	}
+	if err := config.Check(); err != nil {
+		return err
+	}
+
+	rate, workers := r.rate, r.workers
+	if p.Rate != nil {
+		rate = *p.Rate
+	}
+	if p.Workers != nil {
+		workers = *p.Workers
+	}
+	if rate <= 0 || rate > MaxRate || workers < 1 {
+		return fmt.Errorf("rate must be greater than 0 and at most %v, and workers greater than 0. Not [%v, %v]",
+			MaxRate, rate, workers)
+	}
+
+	r.sim.Config, r.rate, r.workers = config, rate, workers
+	if p.Paused != nil {
+		r.paused = *p.Paused
+		r.schedule()
+	}
+
+	return nil
+}
+
+/*
+ * Add bodies to the run. Nothing is added if any body is invalid
+ *
+ * return: the Ids of the added bodies
+ */
+func (r *Run) Add(bodies []NewBody) ([]int, error) {
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// Check every body against the run and each other before adding any
+	added := make([]phys.Body, 0, len(bodies))
+	taken := make(map[int]bool)
+	next := r.sim.NextId()
+	for _, b := range bodies {
+		id := next
+		if b.Id != nil {
+			id = *b.Id
+		}
+		if b.Mass <= 0 || id < 0 || taken[id] || r.has(id) {
+			return nil, fmt.Errorf("mass must be greater than 0 and Id must be unused and not negative. Not [%v, %v]",
+				b.Mass, id)
+		}
+		taken[id] = true
+		if id >= next {
+			next = id + 1
+		}
+		added = append(added, phys.NewBody(b.Mass, id, rl.NewVector2(b.Position[0], b.Position[1]),
+			rl.NewVector2(b.Velocity[0], b.Velocity[1])))
+	}
+
+	ids := make([]int, len(added))
+	for i := range added {
+		_ = r.sim.Add(added[i])
+		ids[i] = added[i].Id
+	}
+
+	if len(r.subscribers) > 0 {
+		r.broadcast()
+	}
+	return ids, nil
+}
+
+/*
+ * Return whether the run has a body with the Id. Must be called holding the lock
+ */
+func (r *Run) has(id int) bool {
+	for i := range r.sim.Bodies {
+		if r.sim.Bodies[i].Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Remove the body with the Id
+ *
+ * return: whether there was a body to remove
+ */
+func (r *Run) Remove(id int) bool {
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	removed := r.sim.Remove(id)
+	if removed && len(r.subscribers) > 0 {
+		r.broadcast()
+	}
+	return removed
+}
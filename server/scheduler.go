@@ -0,0 +1,129 @@
+package server
+
+import "sync"
+
+// Scheduler shares a limited number of goroutines between the runs of a server. Each time-step takes
+// its share of them before calculating the forces and gives them back after, and steps waiting for
+// goroutines are served in the order they asked, so every running run gets its turn
+type Scheduler struct {
+	mtx    sync.Mutex
+	limit  int      // Number of goroutines shared between the runs
+	free   int      // Number of goroutines not taken by a step
+	active int      // Number of runs that are not paused
+	queue  []*grant // Steps waiting for goroutines, in the order they asked
+}
+
+// grant is a step waiting for goroutines
+type grant struct {
+	n     int       // Number of goroutines the step is waiting for
+	ready chan bool // Closed once the goroutines are taken for the step
+}
+
+// SchedulerState is the JSON form of a scheduler
+type SchedulerState struct {
+	Limit   int // Number of goroutines shared between the runs
+	Busy    int // Number of goroutines taken by steps
+	Active  int // Number of runs that are not paused
+	Waiting int // Number of steps waiting for goroutines
+}
+
+/*
+ * Return a scheduler sharing a number of goroutines
+ *
+ * limit: number of goroutines all runs together calculate the forces with. Must be greater than 0
+ */
+func NewScheduler(limit int) *Scheduler {
+	return &Scheduler{limit: limit, free: limit}
+}
+
+/*
+ * Return the state of the scheduler
+ */
+func (s *Scheduler) State() SchedulerState {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return SchedulerState{Limit: s.limit, Busy: s.limit - s.free, Active: s.active, Waiting: len(s.queue)}
+}
+
+/*
+ * Count a run as running, so it is given a share of the goroutines
+ */
+func (s *Scheduler) join() {
+	s.mtx.Lock()
+	s.active++
+	s.mtx.Unlock()
+}
+
+/*
+ * Stop counting a run as running
+ */
+func (s *Scheduler) leave() {
+	s.mtx.Lock()
+	s.active--
+	s.mtx.Unlock()
+}
+
+/*
+ * Return the number of goroutines a step is given. Must be called holding the lock
+ * Each running run is given an equal share of the limit, but never less than one goroutine or more than it asked for
+ *
+ * want: number of goroutines the run asked for
+ */
+func (s *Scheduler) share(want int) int {
+
+	runs := s.active
+	if runs < 1 {
+		runs = 1
+	}
+	n := s.limit / runs
+	if n < 1 {
+		n = 1
+	}
+	if want < n {
+		n = want
+	}
+
+	return n
+}
+
+/*
+ * Take goroutines for a time-step, waiting until they are free. They must be given back with Release
+ *
+ * want: number of goroutines the run asked for
+ * return: the number of goroutines taken
+ */
+func (s *Scheduler) Acquire(want int) int {
+
+	s.mtx.Lock()
+	n := s.share(want)
+	if len(s.queue) == 0 && s.free >= n {
+		s.free -= n
+		s.mtx.Unlock()
+		return n
+	}
+
+	g := &grant{n: n, ready: make(chan bool)}
+	s.queue = append(s.queue, g)
+	s.mtx.Unlock()
+
+	<-g.ready
+	return n
+}
+
+/*
+ * Give back goroutines taken by Acquire, handing them to the waiting steps in order
+ *
+ * n: number of goroutines to give back
+ */
+func (s *Scheduler) Release(n int) {
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.free += n
+	for len(s.queue) > 0 && s.queue[0].n <= s.free {
+		s.free -= s.queue[0].n
+		close(s.queue[0].ready)
+		s.queue = s.queue[1:]
+	}
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"proj3/engine"
+	"testing"
+	"time"
+)
+
+func TestShare(t *testing.T) {
+
+	s := NewScheduler(8)
+	cases := []struct {
+		active, want, n int
+	}{
+		{0, 16, 8},
+		{1, 3, 3},
+		{2, 16, 4},
+		{3, 16, 2},
+		{20, 16, 1},
+	}
+	for _, c := range cases {
+		s.active = c.active
+		if n := s.share(c.want); n != c.n {
+			t.Errorf("share(%v) with %v active runs = %v, want %v", c.want, c.active, n, c.n)
+		}
+	}
+}
+
+func TestAcquireInOrder(t *testing.T) {
+
+	s := NewScheduler(4)
+	s.active = 1
+	if n := s.Acquire(4); n != 4 {
+		t.Fatalf("Acquire(4) = %v, want 4", n)
+	}
+
+	// Steps waiting for goroutines are served in the order they asked
+	order := make(chan int, 2)
+	for i := 1; i <= 2; i++ {
+		go func(i int) {
+			n := s.Acquire(3)
+			order <- i
+			time.Sleep(10 * time.Millisecond)
+			s.Release(n)
+		}(i)
+		for s.State().Waiting != i {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	s.Release(4)
+	if first, second := <-order, <-order; first != 1 || second != 2 {
+		t.Errorf("steps served in order %v, %v, want 1, 2", first, second)
+	}
+	for s.State().Busy != 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPausedRunsLeave(t *testing.T) {
+
+	sched := NewScheduler(4)
+	run, err := NewRun(engine.NewConfig(100, 100), nil, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run.sched = sched
+	run.Start()
+
+	running, paused := false, true
+	_ = run.Set(Params{Paused: &running})
+	if sched.State().Active != 1 {
+		t.Errorf("active = %v after resuming, want 1", sched.State().Active)
+	}
+	_ = run.Set(Params{Paused: &paused})
+	if sched.State().Active != 0 {
+		t.Errorf("active = %v after pausing, want 0", sched.State().Active)
+	}
+	_ = run.Set(Params{Paused: &running})
+	run.Stop()
+	if sched.State().Active != 0 {
+		t.Errorf("active = %v after stopping, want 0", sched.State().Active)
+	}
+}
@@ -0,0 +1,431 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"proj3/engine"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server serves runs over HTTP, with a REST API, a WebSocket stream of each run and a browser client.
+// The runs share the goroutines of a scheduler
+type Server struct {
+	mtx     sync.Mutex
+	runs    map[string]*Run
+	order   []string // Ids of the runs in the order they were added
+	next    int      // Number for the Id of the next run added without one
+	workers int      // Number of goroutines runs created without a number calculate the forces with
+	sched   *Scheduler
+}
+
+// RunRequest creates a run. A missing Id is given the next free number, and missing settings take the
+// defaults of engine.NewConfig
+type RunRequest struct {
+	Id     string
+	Width  int
+	Height int
+	Params
+	Bodies []NewBody
+}
+
+// Error is the JSON body of a failed request
+type Error struct {
+	Error string
+}
+
+// errConflict is the error of adding a run under an Id already in use
+type errConflict struct {
+	id string
+}
+
+func (e errConflict) Error() string {
+	return fmt.Sprintf("a run already has Id %v", e.id)
+}
+
+/*
+ * Return a server without any runs
+ *
+ * cpus: number of goroutines all runs together calculate the forces with. Must be greater than 0
+ * workers: number of goroutines runs created without a number calculate the forces with
+ */
+func New(cpus, workers int) *Server {
+	return &Server{runs: make(map[string]*Run), next: 1, workers: workers, sched: NewScheduler(cpus)}
+}
+
+/*
+ * Start serving a run, sharing the goroutines of the server with it
+ *
+ * id: Id to serve the run under. An empty Id is given the next free number
+ * run: run to serve. Must not be started or served by another server
+ * return: the Id of the run
+ */
+func (s *Server) Add(id string, run *Run) (string, error) {
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if id == "" {
+		for s.runs[strconv.Itoa(s.next)] != nil {
+			s.next++
+		}
+		id = strconv.Itoa(s.next)
+		s.next++
+	}
+	if strings.ContainsAny(id, "/?#") {
+		return "", fmt.Errorf("run Id must not contain /, ? or #. Not [%v]", id)
+	}
+	if s.runs[id] != nil {
+		return "", errConflict{id}
+	}
+
+	run.mtx.Lock()
+	run.id, run.sched = id, s.sched
+	run.mtx.Unlock()
+
+	s.runs[id] = run
+	s.order = append(s.order, id)
+	run.Start()
+
+	return id, nil
+}
+
+/*
+ * Return the run with the Id, or nil if there is none
+ */
+func (s *Server) Run(id string) *Run {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.runs[id]
+}
+
+/*
+ * Return every run in the order they were added
+ */
+func (s *Server) Runs() []*Run {
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	runs := make([]*Run, len(s.order))
+	for i, id := range s.order {
+		runs[i] = s.runs[id]
+	}
+	return runs
+}
+
+/*
+ * Stop and remove the run with the Id
+ *
+ * return: whether there was a run to remove
+ */
+func (s *Server) Delete(id string) bool {
+
+	s.mtx.Lock()
+	run := s.runs[id]
+	if run != nil {
+		delete(s.runs, id)
+		for i := range s.order {
+			if s.order[i] == id {
+				s.order = append(s.order[:i], s.order[i+1:]...)
+				break
+			}
+		}
+	}
+	s.mtx.Unlock()
+
+	if run == nil {
+		return false
+	}
+	run.Stop()
+	return true
+}
+
+/*
+ * Stop and remove every run
+ */
+func (s *Server) Close() {
+
+	s.mtx.Lock()
+	ids := append([]string(nil), s.order...)
+	s.mtx.Unlock()
+
+	for _, id := range ids {
+		s.Delete(id)
+	}
+}
+
+/*
+ * Return the handler of every route
+ *
+ *   GET    /                               browser client
+ *   GET    /api/scheduler                  state of the scheduler
+ *   GET    /api/runs                       state of every run without its bodies
+ *   POST   /api/runs                       create a run
+ *   GET    /api/runs/{id}                  state of the run without its bodies
+ *   PATCH  /api/runs/{id}                  change the settings of the run, including pausing it
+ *   DELETE /api/runs/{id}                  stop and remove the run
+ *   POST   /api/runs/{id}/pause            pause the run
+ *   POST   /api/runs/{id}/resume           resume the run
+ *   POST   /api/runs/{id}/step             take a single time-step
+ *   GET    /api/runs/{id}/snapshot         state of the run with its bodies
+ *   POST   /api/runs/{id}/bodies           add an array of bodies
+ *   DELETE /api/runs/{id}/bodies/{body}    remove a body
+ *   GET    /api/runs/{id}/stream           WebSocket stream of the state with its bodies after every step
+ */
+func (s *Server) Handler() http.Handler {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write([]byte(clientHTML))
+	})
+	mux.HandleFunc("/api/scheduler", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v is not allowed", r.Method))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.sched.State())
+	})
+	mux.HandleFunc("/api/runs", s.handleRuns)
+	mux.HandleFunc("/api/runs/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/runs/")
+		id, action := path, ""
+		if i := strings.Index(path, "/"); i >= 0 {
+			id, action = path[:i], path[i+1:]
+		}
+
+		run := s.Run(id)
+		if run == nil {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no run has Id %v", id))
+			return
+		}
+		if action == "" {
+			s.handleRun(w, r, id, run)
+			return
+		}
+		s.handleRunAction(w, r, run, action)
+	})
+
+	return mux
+}
+
+/*
+ * Serve the collection of runs
+ */
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		runs := s.Runs()
+		states := make([]State, len(runs))
+		for i, run := range runs {
+			states[i] = run.State(false)
+		}
+		writeJSON(w, http.StatusOK, states)
+
+	case http.MethodPost:
+		var req RunRequest
+		if !readJSON(w, r, &req) {
+			return
+		}
+		run, err := s.newRun(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, err := s.Add(req.Id, run); err != nil {
+			status := http.StatusBadRequest
+			if _, ok := err.(errConflict); ok {
+				status = http.StatusConflict
+			}
+			writeError(w, status, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, run.State(false))
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v is not allowed", r.Method))
+	}
+}
+
+/*
+ * Return a run from a request to create one
+ */
+func (s *Server) newRun(req RunRequest) (*Run, error) {
+
+	run, err := NewRun(engine.NewConfig(req.Width, req.Height), req.Bodies, s.workers)
+	if err != nil {
+		return nil, err
+	}
+	if err := run.Set(req.Params); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+/*
+ * Serve a run itself
+ *
+ * id: Id of the run
+ * run: run with the Id
+ */
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request, id string, run *Run) {
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, run.State(false))
+
+	case http.MethodPatch:
+		var p Params
+		if !readJSON(w, r, &p) {
+			return
+		}
+		if err := run.Set(p); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, run.State(false))
+
+	case http.MethodDelete:
+		if !s.Delete(id) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no run has Id %v", id))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %v is not allowed", r.Method))
+	}
+}
+
+/*
+ * Serve an action on a run
+ *
+ * run: run the action is on
+ * action: path after the run, such as "pause" or "bodies/3"
+ */
+func (s *Server) handleRunAction(w http.ResponseWriter, r *http.Request, run *Run, action string) {
+
+	paused, running := true, false
+	switch {
+	case action == "pause" && r.Method == http.MethodPost:
+		_ = run.Set(Params{Paused: &paused})
+		writeJSON(w, http.StatusOK, run.State(false))
+
+	case action == "resume" && r.Method == http.MethodPost:
+		_ = run.Set(Params{Paused: &running})
+		writeJSON(w, http.StatusOK, run.State(false))
+
+	case action == "step" && r.Method == http.MethodPost:
+		writeJSON(w, http.StatusOK, run.Step())
+
+	case action == "snapshot" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, run.State(true))
+
+	case action == "bodies" && r.Method == http.MethodPost:
+		var bodies []NewBody
+		if !readJSON(w, r, &bodies) {
+			return
+		}
+		ids, err := run.Add(bodies)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, ids)
+
+	case strings.HasPrefix(action, "bodies/") && r.Method == http.MethodDelete:
+		id, err := strconv.Atoi(strings.TrimPrefix(action, "bodies/"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if !run.Remove(id) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("no body has Id %v", id))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case action == "stream" && r.Method == http.MethodGet:
+		stream(w, r, run)
+
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("no route for %v %v", r.Method, r.URL.Path))
+	}
+}
+
+/*
+ * Stream the steps of a run over a WebSocket until either end closes it
+ */
+func stream(w http.ResponseWriter, r *http.Request, run *Run) {
+
+	conn, err := upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Messages from the client are only read to notice it closing
+	closed := make(chan bool)
+	go func() {
+		for {
+			if _, _, err := conn.ReadFrame(); err != nil {
+				close(closed)
+				return
+			}
+		}
+	}()
+
+	steps := run.Subscribe()
+	defer run.Unsubscribe(steps)
+	for {
+		select {
+		case message, ok := <-steps:
+			if !ok {
+				return
+			}
+			if err := conn.WriteText(message); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+/*
+ * Decode the JSON body of a request, writing an error response if it is invalid
+ *
+ * return: whether the body was decoded
+ */
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return false
+	}
+	return true
+}
+
+/*
+ * Write a JSON response
+ */
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+/*
+ * Write an error as a JSON response
+ */
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, Error{err.Error()})
+}
@@ -0,0 +1,205 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"proj3/engine"
+	"strings"
+	"testing"
+)
+
+/*
+ * Return a server of a paused run of two bodies with Id 1
+ */
+func newTestServer(t *testing.T) (*Server, *Run) {
+
+	zero, one := 0, 1
+	run, err := NewRun(engine.NewConfig(500, 500), []NewBody{
+		{Id: &zero, Mass: 1, Position: [2]float32{200, 250}, Velocity: [2]float32{0, -0.05}},
+		{Id: &one, Mass: 1, Position: [2]float32{300, 250}, Velocity: [2]float32{0, 0.05}},
+	}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(4, 2)
+	if id, err := s.Add("", run); err != nil || id != "1" {
+		t.Fatalf("Add = %v, %v, want 1", id, err)
+	}
+	t.Cleanup(s.Close)
+	return s, run
+}
+
+/*
+ * Send a request to the server and decode the JSON response into v, checking its status
+ */
+func call(t *testing.T, srv *httptest.Server, method, path, body string, status int, v interface{}) {
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(resp.Body)
+		t.Fatalf("%v %v: status = %v, want %v: %v", method, path, resp.StatusCode, status, buf.String())
+	}
+	if v != nil {
+		if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+			t.Fatalf("%v %v: %v", method, path, err)
+		}
+	}
+}
+
+func TestAPI(t *testing.T) {
+
+	s, _ := newTestServer(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	var state State
+	call(t, srv, "GET", "/api/runs/1", "", http.StatusOK, &state)
+	if state.Id != "1" || !state.Paused || state.Count != 2 || state.Solver != engine.TreeSolver || len(state.Bodies) != 0 {
+		t.Errorf("state = %+v", state)
+	}
+
+	// Bodies without an Id are given the next one
+	var ids []int
+	call(t, srv, "POST", "/api/runs/1/bodies", `[{"Mass":2,"Position":[250,100]},{"Id":7,"Mass":1}]`,
+		http.StatusCreated, &ids)
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 7 {
+		t.Errorf("ids = %v, want [2 7]", ids)
+	}
+	call(t, srv, "POST", "/api/runs/1/bodies", `[{"Id":7,"Mass":1}]`, http.StatusBadRequest, nil)
+	call(t, srv, "DELETE", "/api/runs/1/bodies/7", "", http.StatusNoContent, nil)
+	call(t, srv, "DELETE", "/api/runs/1/bodies/7", "", http.StatusNotFound, nil)
+
+	call(t, srv, "POST", "/api/runs/1/step", "", http.StatusOK, &state)
+	if state.Step != 1 {
+		t.Errorf("step = %v after stepping, want 1", state.Step)
+	}
+
+	call(t, srv, "PATCH", "/api/runs/1", `{"Dt":0.2,"Boundary":"periodic","Rate":10}`, http.StatusOK, &state)
+	if state.Dt != 0.2 || state.Boundary != "periodic" || state.Rate != 10 {
+		t.Errorf("state = %+v after setting the parameters", state)
+	}
+	call(t, srv, "PATCH", "/api/runs/1", `{"Dt":0.3,"Solver":"direct"}`, http.StatusBadRequest, nil)
+
+	call(t, srv, "GET", "/api/runs/1/snapshot", "", http.StatusOK, &state)
+	if state.Dt != 0.2 || len(state.Bodies) != 3 || state.Bodies[2].Id != 2 || state.Bodies[2].Mass != 2 {
+		t.Errorf("snapshot = %+v", state)
+	}
+
+	call(t, srv, "GET", "/api/runs/2", "", http.StatusNotFound, nil)
+	call(t, srv, "PUT", "/api/runs/1", "", http.StatusMethodNotAllowed, nil)
+
+	resp, err := srv.Client().Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("client Content-Type = %v", resp.Header.Get("Content-Type"))
+	}
+}
+
+func TestRuns(t *testing.T) {
+
+	s, _ := newTestServer(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	// New runs take the next number unless they are given an Id
+	var state State
+	call(t, srv, "POST", "/api/runs", `{"Width":100,"Height":50,"Solver":"pm","Bodies":[{"Mass":1}]}`,
+		http.StatusCreated, &state)
+	if state.Id != "2" || state.Step != 0 || state.Width != 100 || state.Solver != "pm" || state.Workers != 2 ||
+		state.Count != 1 {
+		t.Errorf("new run = %+v", state)
+	}
+	call(t, srv, "POST", "/api/runs", `{"Id":"big","Width":100,"Height":100,"Workers":8}`, http.StatusCreated, &state)
+	if state.Id != "big" || state.Workers != 8 {
+		t.Errorf("new run = %+v", state)
+	}
+	call(t, srv, "POST", "/api/runs", `{"Id":"big","Width":100,"Height":100}`, http.StatusConflict, nil)
+	call(t, srv, "POST", "/api/runs", `{"Id":"a/b","Width":100,"Height":100}`, http.StatusBadRequest, nil)
+	call(t, srv, "POST", "/api/runs", `{"Width":0}`, http.StatusBadRequest, nil)
+
+	// Runs are independent of each other
+	call(t, srv, "POST", "/api/runs/big/step", "", http.StatusOK, &state)
+	if state.Step != 1 || state.Granted != 4 {
+		t.Errorf("stepped run = %+v, want step 1 with the 4 goroutines of the scheduler", state)
+	}
+
+	var states []State
+	call(t, srv, "GET", "/api/runs", "", http.StatusOK, &states)
+	if len(states) != 3 || states[0].Id != "1" || states[1].Id != "2" || states[2].Id != "big" ||
+		states[0].Step != 0 || len(states[0].Bodies) != 0 {
+		t.Errorf("runs = %+v", states)
+	}
+
+	call(t, srv, "POST", "/api/runs/2/resume", "", http.StatusOK, &state)
+	var sched SchedulerState
+	call(t, srv, "GET", "/api/scheduler", "", http.StatusOK, &sched)
+	if sched.Limit != 4 || sched.Active != 1 {
+		t.Errorf("scheduler = %+v, want a limit of 4 with 1 active run", sched)
+	}
+
+	call(t, srv, "DELETE", "/api/runs/2", "", http.StatusNoContent, nil)
+	call(t, srv, "DELETE", "/api/runs/2", "", http.StatusNotFound, nil)
+	call(t, srv, "GET", "/api/scheduler", "", http.StatusOK, &sched)
+	if sched.Active != 0 {
+		t.Errorf("scheduler = %+v after deleting the running run", sched)
+	}
+	call(t, srv, "GET", "/api/runs", "", http.StatusOK, &states)
+	if len(states) != 2 || states[1].Id != "big" {
+		t.Errorf("runs = %+v after deleting run 2", states)
+	}
+}
+
+func TestStream(t *testing.T) {
+
+	s, run := newTestServer(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	conn, in := dial(t, srv, "/api/runs/1/stream")
+	defer conn.Close()
+
+	// The current state comes first, then every step
+	var state State
+	for want := 0; want <= 2; want++ {
+		if want > 0 {
+			run.Step()
+		}
+		opcode, payload := readServerFrame(t, in)
+		if err := json.Unmarshal(payload, &state); err != nil || opcode != opText {
+			t.Fatalf("frame %v: opcode %v, %v", want, opcode, err)
+		}
+		if state.Step != want || len(state.Bodies) != 2 {
+			t.Errorf("streamed step %v with %v bodies, want step %v with 2", state.Step, len(state.Bodies), want)
+		}
+	}
+
+	// Pings are answered, and closing is acknowledged
+	if err := writeClientFrame(conn, opPing, []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if opcode, payload := readServerFrame(t, in); opcode != opPong || string(payload) != "hi" {
+		t.Errorf("ping answered with %v %q", opcode, payload)
+	}
+	if err := writeClientFrame(conn, opClose, nil); err != nil {
+		t.Fatal(err)
+	}
+	if opcode, _ := readServerFrame(t, in); opcode != opClose {
+		t.Errorf("close answered with %v", opcode)
+	}
+}
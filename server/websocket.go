@@ -0,0 +1,199 @@
+package server
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Key the handshake appends to the client's key (RFC 6455)
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Largest message accepted from a client
+const maxMessage = 1 << 20
+
+// WebSocket frame opcodes
+const (
+	opText   = 0x1
+	opBinary = 0x2
+	opClose  = 0x8
+	opPing   = 0x9
+	opPong   = 0xA
+)
+
+// wsConn is the server end of a WebSocket connection
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mtx  sync.Mutex // Held while writing a frame
+}
+
+/*
+ * Return the Sec-WebSocket-Accept value for a client's key
+ */
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+/*
+ * Return whether a comma separated header holds the token, ignoring case
+ */
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range strings.Split(h.Get(name), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+ * Upgrade an HTTP request to a WebSocket connection
+ * An error response is written if the request is not a WebSocket handshake
+ */
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerHas(r.Header, "Upgrade", "websocket") ||
+		!headerHas(r.Header, "Connection", "upgrade") || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "expected a WebSocket handshake", http.StatusBadRequest)
+		return nil, errors.New("not a WebSocket handshake")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSockets are not supported", http.StatusInternalServerError)
+		return nil, errors.New("connection can not be hijacked")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %v\r\n\r\n", acceptKey(key))
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &wsConn{conn: conn, rw: rw}, nil
+}
+
+/*
+ * Write a single unfragmented frame. Frames from the server are not masked
+ */
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	header := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n < 1<<16:
+		header = append(header, 126, byte(n>>8), byte(n))
+	default:
+		header = append(header, 127)
+		header = append(header, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+/*
+ * Send a text message
+ */
+func (c *wsConn) WriteText(message []byte) error {
+	return c.writeFrame(opText, message)
+}
+
+/*
+ * Read the next frame from the client, answering pings
+ *
+ * return: the opcode and unmasked payload of the frame, or an error once the connection is closed
+ */
+func (c *wsConn) ReadFrame() (byte, []byte, error) {
+
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+			return 0, nil, err
+		}
+		opcode := head[0] & 0x0F
+		masked := head[1]&0x80 != 0
+
+		n := uint64(head[1] & 0x7F)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		if n > maxMessage {
+			return 0, nil, fmt.Errorf("message of %v bytes is too large", n)
+		}
+
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+				return 0, nil, err
+			}
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.rw, payload); err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+		case opClose:
+			_ = c.writeFrame(opClose, nil)
+			return opClose, payload, io.EOF
+		case opPong:
+		default:
+			return opcode, payload, nil
+		}
+	}
+}
+
+/*
+ * Close the connection, telling the client first
+ */
+func (c *wsConn) Close() error {
+	_ = c.writeFrame(opClose, nil)
+	return c.conn.Close()
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// Example handshake from RFC 6455
+	if got := acceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("acceptKey = %v", got)
+	}
+}
+
+/*
+ * Open a WebSocket to a test server, returning the connection and a reader past the handshake
+ */
+func dial(t *testing.T, srv *httptest.Server, path string) (net.Conn, *bufio.Reader) {
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(conn, "GET %v HTTP/1.1\r\nHost: test\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n", path)
+
+	in := bufio.NewReader(conn)
+	status, err := in.ReadString('\n')
+	if err != nil || !strings.HasPrefix(status, "HTTP/1.1 101") {
+		t.Fatalf("handshake status = %q, %v", status, err)
+	}
+	accepted := false
+	for {
+		line, err := in.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(line, "Sec-WebSocket-Accept: s3pPLMBiTxaQ9kYGzzhZRbK+xOo=") {
+			accepted = true
+		}
+		if line == "\r\n" {
+			break
+		}
+	}
+	if !accepted {
+		t.Fatal("handshake did not accept the key")
+	}
+
+	return conn, in
+}
+
+/*
+ * Read an unmasked frame from the server
+ */
+func readServerFrame(t *testing.T, in *bufio.Reader) (byte, []byte) {
+
+	var head [2]byte
+	if _, err := io.ReadFull(in, head[:]); err != nil {
+		t.Fatal(err)
+	}
+	n := uint64(head[1] & 0x7F)
+	switch n {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(in, ext[:])
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(in, ext[:])
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(in, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	return head[0] & 0x0F, payload
+}
+
+/*
+ * Write a masked frame, as clients must
+ */
+func writeClientFrame(conn net.Conn, opcode byte, payload []byte) error {
+
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := conn.Write(frame)
+	return err
+}
+
+func TestUpgradeRejectsPlainRequests(t *testing.T) {
+
+	s, _ := newTestServer(t)
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/api/runs/1/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Errorf("status = %v, want 400", resp.StatusCode)
+	}
+}
@@ -0,0 +1,178 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"proj3/phys"
+)
+
+// Size of the header block of a Gadget snapshot
+const gadgetHeaderSize = 256
+
+// Number of particle types of a Gadget snapshot
+const gadgetTypes = 6
+
+// Offsets of the fields of the header block
+const (
+	gadgetNumPart  = 0   // int32 number of particles of each type in this file
+	gadgetMass     = 24  // float64 mass of every particle of each type, or 0 if they are in the mass block
+	gadgetTime     = 72  // float64 time, or scale factor of a cosmological run
+	gadgetNumTotal = 96  // uint32 number of particles of each type in all files
+	gadgetNumFiles = 124 // int32 number of files the snapshot is split into
+	gadgetBoxSize  = 128 // float64 side of the periodic box
+)
+
+/*
+ * Read a block of a Gadget snapshot, checking the sizes around it
+ *
+ * name: name of the block, for errors
+ * sizes: sizes the block may be
+ *
+ * return: the contents of the block
+ */
+func readGadgetBlock(in io.Reader, order binary.ByteOrder, name string, sizes ...int) ([]byte, error) {
+
+	var marker [4]byte
+	if _, err := io.ReadFull(in, marker[:]); err != nil {
+		return nil, fmt.Errorf("Gadget %v block: %v", name, err)
+	}
+	size := int(order.Uint32(marker[:]))
+	ok := false
+	for _, s := range sizes {
+		ok = ok || size == s
+	}
+	if !ok {
+		return nil, fmt.Errorf("Gadget %v block is %v bytes, not %v", name, size, sizes)
+	}
+
+	buf := make([]byte, size+4)
+	if _, err := io.ReadFull(in, buf); err != nil {
+		return nil, fmt.Errorf("Gadget %v block: %v", name, err)
+	}
+	if int(order.Uint32(buf[size:])) != size {
+		return nil, fmt.Errorf("Gadget %v block does not end with its size", name)
+	}
+
+	return buf[:size], nil
+}
+
+/*
+ * Read the header, positions, velocities, Ids and masses of a single file Gadget-2 snapshot in format 1
+ * Particles of every type are read as bodies, in order of type. The blocks after the masses are not read
+ */
+func readGadget(in io.Reader, order binary.ByteOrder, p Projection) (Snapshot, error) {
+
+	header, err := readGadgetBlock(in, order, "header", gadgetHeaderSize)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if files := int32(order.Uint32(header[gadgetNumFiles:])); files > 1 {
+		return Snapshot{}, fmt.Errorf("Gadget snapshot is split into %v files. Only single files are read", files)
+	}
+
+	// Types with no mass in the header have theirs in the mass block
+	n, variable := 0, 0
+	var counts [gadgetTypes]int
+	var masses [gadgetTypes]float64
+	for t := 0; t < gadgetTypes; t++ {
+		counts[t] = int(int32(order.Uint32(header[gadgetNumPart+4*t:])))
+		masses[t] = math.Float64frombits(order.Uint64(header[gadgetMass+8*t:]))
+		if counts[t] < 0 {
+			return Snapshot{}, fmt.Errorf("Gadget snapshot has %v particles of type %v", counts[t], t)
+		}
+		n += counts[t]
+		if masses[t] == 0 {
+			variable += counts[t]
+		}
+	}
+
+	pos, err := readGadgetBlock(in, order, "position", 12*n)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	vel, err := readGadgetBlock(in, order, "velocity", 12*n)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	ids, err := readGadgetBlock(in, order, "id", 4*n, 8*n)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	var mass []byte
+	if variable > 0 {
+		if mass, err = readGadgetBlock(in, order, "mass", 4*variable); err != nil {
+			return Snapshot{}, err
+		}
+	}
+
+	s := Snapshot{Time: math.Float64frombits(order.Uint64(header[gadgetTime:])),
+		BoxSize: math.Float64frombits(order.Uint64(header[gadgetBoxSize:])), Bodies: make([]phys.Body, 0, n)}
+	floats := func(buf []byte, i int) []float32 {
+		return []float32{math.Float32frombits(order.Uint32(buf[12*i:])),
+			math.Float32frombits(order.Uint32(buf[12*i+4:])), math.Float32frombits(order.Uint32(buf[12*i+8:]))}
+	}
+	for t := 0; t < gadgetTypes; t++ {
+		for k := 0; k < counts[t]; k++ {
+			i := len(s.Bodies)
+			m := float32(masses[t])
+			if masses[t] == 0 {
+				m = math.Float32frombits(order.Uint32(mass))
+				mass = mass[4:]
+			}
+			id := int(order.Uint32(ids[4*i:]))
+			if len(ids) == 8*n {
+				id = int(order.Uint64(ids[8*i:]))
+			}
+			s.Bodies = append(s.Bodies, phys.NewBody(m, id, p.project(floats(pos, i)), p.project(floats(vel, i))))
+		}
+	}
+
+	return s, nil
+}
+
+/*
+ * Write a little-endian Gadget-2 snapshot in format 1 with every body as type 1, their masses in the mass block
+ * and 32 bit Ids
+ */
+func writeGadget(out io.Writer, s Snapshot) error {
+
+	n := len(s.Bodies)
+	header := make([]byte, gadgetHeaderSize)
+	binary.LittleEndian.PutUint32(header[gadgetNumPart+4:], uint32(n))
+	binary.LittleEndian.PutUint64(header[gadgetTime:], math.Float64bits(s.Time))
+	binary.LittleEndian.PutUint32(header[gadgetNumTotal+4:], uint32(n))
+	binary.LittleEndian.PutUint32(header[gadgetNumFiles:], 1)
+	binary.LittleEndian.PutUint64(header[gadgetBoxSize:], math.Float64bits(s.BoxSize))
+
+	pos := make([]float32, 0, 3*n)
+	vel := make([]float32, 0, 3*n)
+	ids := make([]uint32, n)
+	mass := make([]float32, n)
+	for i := range s.Bodies {
+		b := &s.Bodies[i]
+		if b.Id < 0 || uint64(b.Id) > math.MaxUint32 {
+			return fmt.Errorf("body %v does not fit a Gadget Id", b.Id)
+		}
+		p, v := lift(b.Position), lift(b.Velocity)
+		pos = append(pos, p[:]...)
+		vel = append(vel, v[:]...)
+		ids[i], mass[i] = uint32(b.Id), b.Mass
+	}
+
+	for _, block := range []interface{}{header, pos, vel, ids, mass} {
+		size := uint32(binary.Size(block))
+		if err := binary.Write(out, binary.LittleEndian, size); err != nil {
+			return err
+		}
+		if err := binary.Write(out, binary.LittleEndian, block); err != nil {
+			return err
+		}
+		if err := binary.Write(out, binary.LittleEndian, size); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
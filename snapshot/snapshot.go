@@ -0,0 +1,190 @@
+package snapshot
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"io"
+	"proj3/phys"
+)
+
+// Format is a snapshot format of another N-body code
+type Format int
+
+// Snapshot formats
+const (
+	Tipsy  Format = iota // TIPSY standard binary, with every body written as dark matter
+	Gadget               // Gadget-2 format 1, with every body written as type 1
+)
+
+var formatNames = []string{"tipsy", "gadget"}
+
+/*
+ * Return the name of the format
+ */
+func (f Format) String() string {
+	if f < 0 || int(f) >= len(formatNames) {
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+	return formatNames[f]
+}
+
+/*
+ * Return the format with the given name
+ */
+func ParseFormat(name string) (Format, error) {
+	for i, n := range formatNames {
+		if n == name {
+			return Format(i), nil
+		}
+	}
+	return Tipsy, fmt.Errorf("unknown snapshot format %q", name)
+}
+
+// Projection picks the two axes of a 3D snapshot that become x and y
+type Projection int
+
+// Projections onto the planes of two axes
+const (
+	XY Projection = iota // Drop z
+	XZ                   // Drop y
+	YZ                   // Drop x
+)
+
+var projectionNames = []string{"xy", "xz", "yz"}
+
+/*
+ * Return the name of the projection
+ */
+func (p Projection) String() string {
+	if p < 0 || int(p) >= len(projectionNames) {
+		return fmt.Sprintf("Projection(%d)", int(p))
+	}
+	return projectionNames[p]
+}
+
+/*
+ * Return the projection with the given name
+ */
+func ParseProjection(name string) (Projection, error) {
+	for i, n := range projectionNames {
+		if n == name {
+			return Projection(i), nil
+		}
+	}
+	return XY, fmt.Errorf("unknown projection %q", name)
+}
+
+/*
+ * Return the 2D vector of a 3D one
+ */
+func (p Projection) project(v []float32) rl.Vector2 {
+	switch p {
+	case XZ:
+		return rl.NewVector2(v[0], v[2])
+	case YZ:
+		return rl.NewVector2(v[1], v[2])
+	}
+	return rl.NewVector2(v[0], v[1])
+}
+
+// Snapshot is the state of a run at one time, as exchanged with other N-body codes
+type Snapshot struct {
+	Time    float64     // Time of the snapshot
+	BoxSize float64     // Side of the periodic box of a Gadget snapshot. 0 if unknown or not periodic
+	Bodies  []phys.Body // Bodies in the order of the file. TIPSY has no Ids, so they are numbered in order
+}
+
+// ErrUnknown is returned when reading something that is neither a TIPSY nor a Gadget snapshot
+var ErrUnknown = errors.New("not a TIPSY or Gadget snapshot")
+
+// Number of bytes Detect looks at
+const DetectSize = 32
+
+/*
+ * Return the format and byte order of a snapshot from its first DetectSize bytes
+ * Gadget snapshots start with the 256 byte size of their header, and TIPSY snapshots with a header whose
+ * number of bodies is the sum of its gas, dark matter and stars. Either may be big or little-endian
+ *
+ * return: the format and byte order, or ErrUnknown
+ */
+func Detect(head []byte) (Format, binary.ByteOrder, error) {
+
+	if len(head) < DetectSize {
+		return Tipsy, nil, ErrUnknown
+	}
+
+	orders := []binary.ByteOrder{binary.LittleEndian, binary.BigEndian}
+	for _, order := range orders {
+		if order.Uint32(head) == gadgetHeaderSize {
+			return Gadget, order, nil
+		}
+	}
+	for _, order := range orders {
+		var h tipsyHeader
+		h.decode(head, order)
+		if h.Dim >= 2 && h.Dim <= 3 && h.Gas >= 0 && h.Dark >= 0 && h.Star >= 0 &&
+			int64(h.Gas)+int64(h.Dark)+int64(h.Star) == int64(h.Bodies) {
+			return Tipsy, order, nil
+		}
+	}
+
+	return Tipsy, nil, ErrUnknown
+}
+
+/*
+ * Read a TIPSY or Gadget snapshot, detecting its format
+ *
+ * in: Reader to read the snapshot from
+ * p: projection of the 3D positions and velocities onto the plane
+ *
+ * return: the snapshot and its format, or ErrUnknown if it is neither
+ */
+func Read(in io.Reader, p Projection) (Snapshot, Format, error) {
+
+	buf, ok := in.(*bufio.Reader)
+	if !ok {
+		buf = bufio.NewReader(in)
+	}
+	head, _ := buf.Peek(DetectSize)
+	format, order, err := Detect(head)
+	if err != nil {
+		return Snapshot{}, format, err
+	}
+
+	var s Snapshot
+	if format == Gadget {
+		s, err = readGadget(buf, order, p)
+	} else {
+		s, err = readTipsy(buf, order, p)
+	}
+	return s, format, err
+}
+
+/*
+ * Write a snapshot in a format, with z positions and velocities of 0
+ */
+func Write(out io.Writer, format Format, s Snapshot) error {
+
+	buf := bufio.NewWriter(out)
+	var err error
+	if format == Gadget {
+		err = writeGadget(buf, s)
+	} else {
+		err = writeTipsy(buf, s)
+	}
+	if err != nil {
+		return err
+	}
+
+	return buf.Flush()
+}
+
+/*
+ * Return the 3D form of a 2D vector, with a z of 0
+ */
+func lift(v rl.Vector2) [3]float32 {
+	return [3]float32{v.X, v.Y, 0}
+}
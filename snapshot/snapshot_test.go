@@ -0,0 +1,128 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"proj3/phys"
+	"strings"
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+func TestRoundTrip(t *testing.T) {
+
+	bodies := []phys.Body{phys.NewBody(1.5, 4, rl.NewVector2(10, 20), rl.NewVector2(-1, 0.25)),
+		phys.NewBody(2, 9, rl.NewVector2(30.5, 0), rl.NewVector2(0, 3))}
+
+	for _, format := range []Format{Tipsy, Gadget} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, Snapshot{Time: 2.5, BoxSize: 100, Bodies: bodies}); err != nil {
+			t.Fatal(err)
+		}
+
+		s, detected, err := Read(&buf, XY)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		if detected != format || s.Time != 2.5 || len(s.Bodies) != 2 {
+			t.Fatalf("%v read as %v at %v with %v bodies", format, detected, s.Time, len(s.Bodies))
+		}
+		for i, b := range s.Bodies {
+			want := bodies[i]
+			if format == Tipsy {
+				want.Id = i
+			}
+			if b != want {
+				t.Errorf("%v body %v = %+v, want %+v", format, i, b, want)
+			}
+		}
+		if format == Gadget && s.BoxSize != 100 {
+			t.Errorf("Gadget box = %v", s.BoxSize)
+		}
+	}
+}
+
+func TestReadTipsy(t *testing.T) {
+
+	// A little-endian snapshot with one gas particle and one star, projected onto y and z
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, struct {
+		Time                              float64
+		Bodies, Dim, Gas, Dark, Star, Pad int32
+	}{0.5, 2, 3, 1, 0, 1, 0})
+	_ = binary.Write(&buf, binary.LittleEndian, []float32{3, 1, 2, 3, 4, 5, 6, 0, 0, 0, 0, 0})
+	_ = binary.Write(&buf, binary.LittleEndian, []float32{7, 8, 9, 10, 11, 12, 13, 0, 0, 0, 0})
+
+	data := buf.Bytes()
+	s, format, err := Read(bytes.NewReader(data), YZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != Tipsy || s.Time != 0.5 || len(s.Bodies) != 2 {
+		t.Fatalf("read %v at %v with %v bodies", format, s.Time, len(s.Bodies))
+	}
+	gas, star := s.Bodies[0], s.Bodies[1]
+	if gas.Mass != 3 || gas.Position != rl.NewVector2(2, 3) || gas.Velocity != rl.NewVector2(5, 6) || gas.Id != 0 {
+		t.Errorf("gas = %+v", gas)
+	}
+	if star.Mass != 7 || star.Position != rl.NewVector2(9, 10) || star.Velocity != rl.NewVector2(12, 13) || star.Id != 1 {
+		t.Errorf("star = %+v", star)
+	}
+
+	// A snapshot cut short is an error rather than fewer bodies
+	if _, _, err := Read(bytes.NewReader(data[:len(data)-4]), XY); err == nil || err == ErrUnknown {
+		t.Errorf("truncated snapshot: %v", err)
+	}
+}
+
+func TestReadGadget(t *testing.T) {
+
+	// A big-endian snapshot with two gas particles of fixed mass and one star with its mass in the mass block
+	header := make([]byte, gadgetHeaderSize)
+	binary.BigEndian.PutUint32(header[gadgetNumPart:], 2)
+	binary.BigEndian.PutUint32(header[gadgetNumPart+16:], 1)
+	binary.BigEndian.PutUint64(header[gadgetMass:], math.Float64bits(0.5))
+	binary.BigEndian.PutUint64(header[gadgetTime:], math.Float64bits(1))
+	var buf bytes.Buffer
+	for _, block := range []interface{}{header, []float32{1, 2, 3, 4, 5, 6, 7, 8, 9}, make([]float32, 9),
+		[]uint64{11, 12, 13}, []float32{4}} {
+		size := uint32(binary.Size(block))
+		_ = binary.Write(&buf, binary.BigEndian, size)
+		_ = binary.Write(&buf, binary.BigEndian, block)
+		_ = binary.Write(&buf, binary.BigEndian, size)
+	}
+
+	s, format, err := Read(bytes.NewReader(buf.Bytes()), XZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != Gadget || len(s.Bodies) != 3 {
+		t.Fatalf("read %v with %v bodies", format, len(s.Bodies))
+	}
+	if b := s.Bodies[1]; b.Mass != 0.5 || b.Id != 12 || b.Position != rl.NewVector2(4, 6) {
+		t.Errorf("second gas particle = %+v", b)
+	}
+	if b := s.Bodies[2]; b.Mass != 4 || b.Id != 13 || b.Position != rl.NewVector2(7, 9) {
+		t.Errorf("star = %+v", b)
+	}
+
+	if _, _, err := Read(bytes.NewReader(buf.Bytes()[:buf.Len()-10]), XY); err == nil {
+		t.Error("Read accepted a truncated snapshot")
+	}
+}
+
+func TestDetect(t *testing.T) {
+
+	json := `{"Command":"ADD","Id":0,"Mass":1.7220643,"Position":[596,19],"Velocity":[0,0]}`
+	if _, _, err := Detect([]byte(json)); err != ErrUnknown {
+		t.Errorf("JSON input detected as a snapshot")
+	}
+	if _, _, err := Read(strings.NewReader(json), XY); err != ErrUnknown {
+		t.Errorf("Read of JSON input: %v", err)
+	}
+	if _, err := ParseProjection("zx"); err == nil {
+		t.Error("ParseProjection accepted zx")
+	}
+}
@@ -0,0 +1,97 @@
+package snapshot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"proj3/phys"
+)
+
+// Size of the header of a TIPSY snapshot, including its padding
+const tipsyHeaderSize = 32
+
+// Number of float32 in each kind of TIPSY body. Each starts with the mass, position and velocity
+const (
+	tipsyGas  = 12 // Then density, temperature, smoothing length, metals and potential
+	tipsyDark = 9  // Then softening and potential
+	tipsyStar = 11 // Then metals, formation time, softening and potential
+)
+
+// Header of a TIPSY snapshot
+type tipsyHeader struct {
+	Time   float64
+	Bodies int32 // Number of bodies of every kind
+	Dim    int32 // Number of dimensions, 3 for nearly every snapshot
+	Gas    int32
+	Dark   int32
+	Star   int32
+}
+
+/*
+ * Read the header from its bytes
+ */
+func (h *tipsyHeader) decode(buf []byte, order binary.ByteOrder) {
+	h.Time = math.Float64frombits(order.Uint64(buf))
+	h.Bodies = int32(order.Uint32(buf[8:]))
+	h.Dim = int32(order.Uint32(buf[12:]))
+	h.Gas = int32(order.Uint32(buf[16:]))
+	h.Dark = int32(order.Uint32(buf[20:]))
+	h.Star = int32(order.Uint32(buf[24:]))
+}
+
+/*
+ * Read a TIPSY snapshot. Gas, dark matter and stars are all read as bodies, in that order
+ */
+func readTipsy(in io.Reader, order binary.ByteOrder, p Projection) (Snapshot, error) {
+
+	buf := make([]byte, tipsyHeaderSize)
+	if _, err := io.ReadFull(in, buf); err != nil {
+		return Snapshot{}, err
+	}
+	var h tipsyHeader
+	h.decode(buf, order)
+
+	s := Snapshot{Time: h.Time, Bodies: make([]phys.Body, 0, h.Bodies)}
+	for _, kind := range []struct{ count, size int32 }{{h.Gas, tipsyGas}, {h.Dark, tipsyDark}, {h.Star, tipsyStar}} {
+		record := make([]float32, kind.size)
+		for i := int32(0); i < kind.count; i++ {
+			if err := binary.Read(in, order, record); err != nil {
+				return s, fmt.Errorf("TIPSY body %v of %v: %v", len(s.Bodies), h.Bodies, err)
+			}
+			s.Bodies = append(s.Bodies, phys.NewBody(record[0], len(s.Bodies), p.project(record[1:4]), p.project(record[4:7])))
+		}
+	}
+
+	return s, nil
+}
+
+/*
+ * Write a big-endian TIPSY snapshot with every body as dark matter, with no softening or potential
+ */
+func writeTipsy(out io.Writer, s Snapshot) error {
+
+	n := int32(len(s.Bodies))
+	buf := make([]byte, tipsyHeaderSize)
+	binary.BigEndian.PutUint64(buf, math.Float64bits(s.Time))
+	binary.BigEndian.PutUint32(buf[8:], uint32(n))
+	binary.BigEndian.PutUint32(buf[12:], 3)
+	binary.BigEndian.PutUint32(buf[20:], uint32(n))
+	if _, err := out.Write(buf); err != nil {
+		return err
+	}
+
+	record := make([]float32, tipsyDark)
+	for i := range s.Bodies {
+		b := &s.Bodies[i]
+		pos, vel := lift(b.Position), lift(b.Velocity)
+		record[0] = b.Mass
+		copy(record[1:4], pos[:])
+		copy(record[4:7], vel[:])
+		if err := binary.Write(out, binary.BigEndian, record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
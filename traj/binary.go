@@ -0,0 +1,523 @@
+package traj
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"io"
+	"math"
+	"os"
+	"proj3/phys"
+)
+
+// Binary trajectory format. Every number is little-endian
+//
+//   offset     size   field
+//   0          4      magic "NBTR"
+//   4          2      version, 2
+//   6          2      bytes per number, 4 for float32 or 8 for float64
+//   8          4      number of bodies N
+//   12         4      number of frames F, including the initial positions. 0 if unknown,
+//                     in which case it is the number of whole frames after the header
+//   16         8      timestep between frames as a float64
+//   24         8      width of the box as a float64. 0 if unknown
+//   32         8      height of the box as a float64. 0 if unknown
+//   40         4      length L of the config
+//   44         4      fields of each body, with bit i set for Field i. Bit 0, the position, is always set
+//   48         L      config, a JSON object of the other settings of the run. May be empty
+//   48+L       8N     Id of each body as an int64
+//   48+L+8N           F frames, each the C columns of the fields of every body in the order of the Ids,
+//                     starting with x and y. Bodies no longer in the run are NaN
+//
+// Every frame is the same size, so frame k starts at 48+L+8N + k*N*C*(bytes per number).
+// Version 1 has no fields, so its config starts at 44 and each body has only x and y
+
+// Magic starts every binary trajectory
+const Magic = "NBTR"
+
+// Version of the binary format written
+const Version = 2
+
+// Size of the fixed part of the header, and of the header of version 1 with no fields
+const (
+	headerSize   = 48
+	headerSizeV1 = 44
+)
+
+// Offset of the number of frames in the header
+const framesOffset = 12
+
+// ErrNotBinary is returned when reading something that is not a binary trajectory
+var ErrNotBinary = errors.New("not a binary trajectory")
+
+// Header describes a trajectory. Writers of formats other than binary use only the parts they need
+type Header struct {
+	Precision int     // Bytes per number, 4 or 8
+	Frames    int     // Number of frames, including the initial positions
+	Dt        float64 // Timestep between frames
+	Width     float64 // Width of the box. 0 if unknown
+	Height    float64 // Height of the box. 0 if unknown
+	Config    string  // JSON object of the other settings of the run. May be empty
+	Ids       []int   // Id of each body, in the order of the frames
+	Fields    []Field // Fields of each body, in order. Empty for the default of the format
+}
+
+/*
+ * Return the number of bytes before the first frame
+ */
+func (h *Header) size() int64 {
+	return headerSize + int64(len(h.Config)) + 8*int64(len(h.Ids))
+}
+
+/*
+ * Return the number of bytes in each frame
+ */
+func (h *Header) frameSize() int64 {
+	return int64(len(Columns(h.Fields))) * int64(h.Precision) * int64(len(h.Ids))
+}
+
+// Writer writes a binary trajectory a frame at a time
+type Writer struct {
+	out    io.Writer
+	header Header
+	index  map[int]int // Place of each Id in a frame
+	frame  []byte      // One frame, reused for every frame
+	values []float64   // Columns of one body, reused for every body
+	frames int         // Number of frames written
+	start  int64       // Offset of the header in out, or -1 if out can not seek
+}
+
+/*
+ * Return a writer of a binary trajectory, having written its header
+ * The number of frames in the header is ignored. It is filled in by Close if out can seek,
+ * and is otherwise left 0 for readers to count the frames
+ *
+ * out: where to write the trajectory
+ * header: settings of the run and Ids of its bodies
+ */
+func NewWriter(out io.Writer, header Header) (*Writer, error) {
+
+	if header.Precision != 4 && header.Precision != 8 {
+		return nil, fmt.Errorf("precision must be 4 or 8 bytes. Not [%v]", header.Precision)
+	}
+	if len(header.Fields) == 0 {
+		header.Fields = []Field{Position}
+	}
+	if err := checkFields(header.Fields); err != nil {
+		return nil, err
+	}
+
+	w := &Writer{out: out, header: header, index: make(map[int]int, len(header.Ids)),
+		frame: make([]byte, header.frameSize()), start: -1}
+	for i, id := range header.Ids {
+		if _, ok := w.index[id]; ok {
+			return nil, fmt.Errorf("body %v appears more than once", id)
+		}
+		w.index[id] = i
+	}
+	if s, ok := out.(io.Seeker); ok {
+		if start, err := s.Seek(0, io.SeekCurrent); err == nil {
+			w.start = start
+		}
+	}
+
+	buf := make([]byte, header.size())
+	copy(buf, Magic)
+	binary.LittleEndian.PutUint16(buf[4:], Version)
+	binary.LittleEndian.PutUint16(buf[6:], uint16(header.Precision))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(header.Ids)))
+	binary.LittleEndian.PutUint64(buf[16:], math.Float64bits(header.Dt))
+	binary.LittleEndian.PutUint64(buf[24:], math.Float64bits(header.Width))
+	binary.LittleEndian.PutUint64(buf[32:], math.Float64bits(header.Height))
+	binary.LittleEndian.PutUint32(buf[40:], uint32(len(header.Config)))
+	var mask uint32
+	for _, f := range header.Fields {
+		mask |= 1 << uint(f)
+	}
+	binary.LittleEndian.PutUint32(buf[44:], mask)
+	copy(buf[headerSize:], header.Config)
+	for i, id := range header.Ids {
+		binary.LittleEndian.PutUint64(buf[headerSize+len(header.Config)+8*i:], uint64(int64(id)))
+	}
+
+	if _, err := out.Write(buf); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+/*
+ * Write a frame of the fields of the bodies. Bodies of the header missing from the slice are written as NaN
+ *
+ * states: the bodies with the quantities found from the forces on them, in any order
+ */
+func (w *Writer) WriteFrame(states []State) error {
+
+	w.clear()
+	for i := range states {
+		place, ok := w.index[states[i].Id]
+		if !ok {
+			return fmt.Errorf("body %v is not in the header", states[i].Id)
+		}
+		w.values = states[i].Values(w.header.Fields, w.values[:0])
+		for c, v := range w.values {
+			putFloat(w.frame[(place*len(w.values)+c)*w.header.Precision:], w.header.Precision, v)
+		}
+	}
+
+	return w.write()
+}
+
+/*
+ * Write a frame of positions in the order of the Ids of the header. NaN marks bodies no longer in the run,
+ * and the other fields are NaN
+ */
+func (w *Writer) WritePositions(positions []rl.Vector2) error {
+
+	if len(positions) != len(w.header.Ids) {
+		return fmt.Errorf("a frame must have %v positions. Not [%v]", len(w.header.Ids), len(positions))
+	}
+	w.clear()
+	columns := len(Columns(w.header.Fields))
+	for i := range positions {
+		offset := columns * w.header.Precision * i
+		putFloat(w.frame[offset:], w.header.Precision, float64(positions[i].X))
+		putFloat(w.frame[offset+w.header.Precision:], w.header.Precision, float64(positions[i].Y))
+	}
+
+	return w.write()
+}
+
+/*
+ * Fill the frame with NaN
+ */
+func (w *Writer) clear() {
+	for i := 0; i < len(w.frame); i += w.header.Precision {
+		putFloat(w.frame[i:], w.header.Precision, math.NaN())
+	}
+}
+
+/*
+ * Write the frame out
+ */
+func (w *Writer) write() error {
+	if _, err := w.out.Write(w.frame); err != nil {
+		return err
+	}
+	w.frames++
+	return nil
+}
+
+/*
+ * Fill in the number of frames in the header if the output can seek. The output is not closed
+ */
+func (w *Writer) Close() error {
+
+	s, ok := w.out.(io.Seeker)
+	if !ok || w.start < 0 {
+		return nil
+	}
+
+	end, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Seek(w.start+framesOffset, io.SeekStart); err != nil {
+		return err
+	}
+	var count [4]byte
+	binary.LittleEndian.PutUint32(count[:], uint32(w.frames))
+	if _, err := w.out.Write(count[:]); err != nil {
+		return err
+	}
+	_, err = s.Seek(end, io.SeekStart)
+	return err
+}
+
+// Reader reads the frames of a binary trajectory in any order
+type Reader struct {
+	Header Header
+	in     io.ReaderAt
+	data   int64     // Offset of the first frame
+	closer io.Closer // File opened by Open. nil otherwise
+}
+
+/*
+ * Return a reader of a binary trajectory, having read its header
+ *
+ * in: the trajectory
+ * size: Integer - number of bytes in the trajectory
+ *
+ * return: the reader, or ErrNotBinary if in does not start with Magic
+ */
+func NewReader(in io.ReaderAt, size int64) (*Reader, error) {
+
+	buf := make([]byte, headerSize)
+	n, err := in.ReadAt(buf, 0)
+	if n < len(Magic) || string(buf[:len(Magic)]) != Magic {
+		return nil, ErrNotBinary
+	}
+
+	// Version 1 has only the positions
+	fixed := int64(headerSize)
+	fields := []Field{Position}
+	switch version := binary.LittleEndian.Uint16(buf[4:]); {
+	case n < headerSizeV1:
+		return nil, fmt.Errorf("binary trajectory header is truncated: %v", err)
+	case version == 1:
+		fixed = headerSizeV1
+	case version != Version:
+		return nil, fmt.Errorf("unknown binary trajectory version %v", version)
+	case n < headerSize:
+		return nil, fmt.Errorf("binary trajectory header is truncated: %v", err)
+	default:
+		mask := binary.LittleEndian.Uint32(buf[44:])
+		if mask&1 == 0 || mask >= 1<<uint(len(fieldNames)) {
+			return nil, fmt.Errorf("binary trajectory has unknown fields %b", mask)
+		}
+		for f := Velocity; int(f) < len(fieldNames); f++ {
+			if mask&(1<<uint(f)) != 0 {
+				fields = append(fields, f)
+			}
+		}
+	}
+	h := Header{Precision: int(binary.LittleEndian.Uint16(buf[6:])),
+		Frames: int(binary.LittleEndian.Uint32(buf[framesOffset:])),
+		Dt:     math.Float64frombits(binary.LittleEndian.Uint64(buf[16:])),
+		Width:  math.Float64frombits(binary.LittleEndian.Uint64(buf[24:])),
+		Height: math.Float64frombits(binary.LittleEndian.Uint64(buf[32:])), Fields: fields}
+	if h.Precision != 4 && h.Precision != 8 {
+		return nil, fmt.Errorf("precision must be 4 or 8 bytes. Not [%v]", h.Precision)
+	}
+
+	// Check the lengths against the size before allocating anything
+	bodies := int64(binary.LittleEndian.Uint32(buf[8:]))
+	config := int64(binary.LittleEndian.Uint32(buf[40:]))
+	data := fixed + config + 8*bodies
+	if data > size {
+		return nil, fmt.Errorf("binary trajectory header is truncated")
+	}
+
+	rest := make([]byte, data-fixed)
+	if _, err := in.ReadAt(rest, fixed); err != nil {
+		return nil, err
+	}
+	h.Config = string(rest[:config])
+	h.Ids = make([]int, bodies)
+	for i := range h.Ids {
+		h.Ids[i] = int(int64(binary.LittleEndian.Uint64(rest[config+8*int64(i):])))
+	}
+
+	if frameSize := h.frameSize(); frameSize > 0 {
+		whole := int((size - data) / frameSize)
+		if h.Frames == 0 {
+			h.Frames = whole
+		} else if h.Frames > whole {
+			return nil, fmt.Errorf("binary trajectory has %v whole frames, not the %v of its header", whole, h.Frames)
+		}
+	}
+
+	return &Reader{Header: h, in: in, data: data}, nil
+}
+
+/*
+ * Open a binary trajectory file. The reader must be closed
+ *
+ * return: the reader, or ErrNotBinary if the file is not a binary trajectory
+ */
+func Open(path string) (*Reader, error) {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r, err := NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+/*
+ * Close the file of a reader returned by Open
+ */
+func (r *Reader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+/*
+ * Return the columns of the fields of every body at a frame, one body after another in the order of the Ids.
+ * NaN marks bodies no longer in the run. 64-bit frames are returned as they were written
+ *
+ * k: Integer - frame to read, where 0 is the initial state
+ */
+func (r *Reader) Values(k int) ([]float64, error) {
+
+	if k < 0 || k >= r.Header.Frames {
+		return nil, fmt.Errorf("frame must be at least 0 and less than %v. Not [%v]", r.Header.Frames, k)
+	}
+
+	size := r.Header.frameSize()
+	buf := make([]byte, size)
+	if _, err := r.in.ReadAt(buf, r.data+int64(k)*size); err != nil {
+		return nil, err
+	}
+
+	values := make([]float64, int(size)/r.Header.Precision)
+	for i := range values {
+		if r.Header.Precision == 4 {
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+		} else {
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(buf[8*i:]))
+		}
+	}
+
+	return values, nil
+}
+
+/*
+ * Return the positions of every body at a frame, in the order of the Ids. NaN marks bodies no longer in the run
+ * The positions are the float32 vectors of the simulation, whatever the precision of the frame
+ *
+ * k: Integer - frame to read, where 0 is the initial positions
+ */
+func (r *Reader) Frame(k int) ([]rl.Vector2, error) {
+
+	values, err := r.Values(k)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := len(Columns(r.Header.Fields))
+	positions := make([]rl.Vector2, len(r.Header.Ids))
+	for i := range positions {
+		positions[i] = rl.NewVector2(float32(values[columns*i]), float32(values[columns*i+1]))
+	}
+
+	return positions, nil
+}
+
+/*
+ * Return the bodies still in the run at a frame, as Trajectory.Bodies does with the timestep of the header
+ *
+ * k: Integer - frame to return the bodies at
+ * initial: bodies the run started with by Id. May be nil
+ */
+func (r *Reader) Bodies(k int, initial map[int]phys.Body) ([]phys.Body, error) {
+
+	current, err := r.Frame(k)
+	if err != nil {
+		return nil, err
+	}
+	var previous []rl.Vector2
+	if k > 0 {
+		if previous, err = r.Frame(k - 1); err != nil {
+			return nil, err
+		}
+	}
+
+	dt := float32(r.Header.Dt)
+	bodies := make([]phys.Body, 0, len(current))
+	for i, id := range r.Header.Ids {
+		if absent(current[i]) {
+			continue
+		}
+
+		mass := float32(1)
+		vel := rl.NewVector2(0, 0)
+		if b, ok := initial[id]; ok {
+			mass, vel = b.Mass, b.Velocity
+		}
+		if previous != nil {
+			vel = rl.NewVector2((current[i].X-previous[i].X)/dt, (current[i].Y-previous[i].Y)/dt)
+		}
+
+		bodies = append(bodies, phys.NewBody(mass, id, current[i], vel))
+	}
+
+	return bodies, nil
+}
+
+/*
+ * Read every frame into a trajectory. Each body's positions end at its first NaN
+ */
+func (r *Reader) Trajectory() (*Trajectory, error) {
+
+	t := &Trajectory{Ids: make([]int, 0, len(r.Header.Ids)), Positions: make([][]rl.Vector2, len(r.Header.Ids))}
+	gone := make([]bool, len(r.Header.Ids))
+	for k := 0; k < r.Header.Frames; k++ {
+		frame, err := r.Frame(k)
+		if err != nil {
+			return nil, err
+		}
+		for i := range frame {
+			if gone[i] || absent(frame[i]) {
+				gone[i] = true
+				continue
+			}
+			t.Positions[i] = append(t.Positions[i], frame[i])
+		}
+	}
+
+	// Bodies without an initial position are left out
+	positions := t.Positions[:0]
+	for i, id := range r.Header.Ids {
+		if len(t.Positions[i]) > 0 {
+			t.Ids = append(t.Ids, id)
+			positions = append(positions, t.Positions[i])
+		}
+	}
+	t.Positions = positions
+
+	return t, nil
+}
+
+/*
+ * Write the trajectory in the binary format
+ *
+ * out: where to write the trajectory
+ * header: settings of the run. Its Ids and number of frames are taken from the trajectory
+ */
+func (t *Trajectory) WriteBinary(out io.Writer, header Header) error {
+
+	header.Ids = t.Ids
+	w, err := NewWriter(out, header)
+	if err != nil {
+		return err
+	}
+
+	nan := rl.NewVector2(float32(math.NaN()), float32(math.NaN()))
+	frame := make([]rl.Vector2, len(t.Ids))
+	for k := 0; k < t.Steps(); k++ {
+		for i := range t.Positions {
+			frame[i] = nan
+			if k < len(t.Positions[i]) {
+				frame[i] = t.Positions[i][k]
+			}
+		}
+		if err := w.WritePositions(frame); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+/*
+ * Return whether a position marks a body no longer in the run
+ */
+func absent(pos rl.Vector2) bool {
+	return math.IsNaN(float64(pos.X)) || math.IsNaN(float64(pos.Y))
+}
@@ -0,0 +1,271 @@
+package traj
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+
+	tr, err := ReadJSON(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, precision := range []int{4, 8} {
+		var buf bytes.Buffer
+		if err := tr.WriteBinary(&buf, Header{Precision: precision, Dt: 0.5, Width: 100, Height: 50,
+			Config: `{"Solver":"tree"}`}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The frames of a writer that can not seek are counted from the size
+		r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := r.Header
+		if h.Precision != precision || h.Frames != 3 || h.Dt != 0.5 || h.Width != 100 || h.Height != 50 ||
+			h.Config != `{"Solver":"tree"}` || len(h.Ids) != 2 || h.Ids[1] != 1 {
+			t.Errorf("header = %+v", h)
+		}
+		if size := int64(headerSize+len(h.Config)+8*2) + 3*2*2*int64(precision); int64(buf.Len()) != size {
+			t.Errorf("%v byte trajectory, want %v", buf.Len(), size)
+		}
+
+		// Body 1 was absorbed after the first step
+		last, err := r.Frame(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if last[0].X != 3 || last[0].Y != 4 || !math.IsNaN(float64(last[1].X)) {
+			t.Errorf("last frame = %v, want [3, 4] and NaN", last)
+		}
+		if _, err := r.Frame(3); err == nil {
+			t.Error("Frame accepted a frame past the end")
+		}
+
+		back, err := r.Trajectory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if err := back.WriteJSON(&out); err != nil {
+			t.Fatal(err)
+		}
+		if strings.TrimSpace(out.String()) != recorded {
+			t.Errorf("round trip = %v, want %v", out.String(), recorded)
+		}
+
+		bodies, err := r.Bodies(2, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(bodies) != 1 || bodies[0].Velocity.X != 4 || bodies[0].Velocity.Y != 4 {
+			t.Errorf("bodies at the end = %+v", bodies)
+		}
+	}
+}
+
+func TestBinaryFile(t *testing.T) {
+
+	tr, err := ReadJSON(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "traj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Writing to a file fills in the number of frames
+	path := filepath.Join(dir, "out.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.WriteBinary(f, Header{Precision: 4, Dt: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	data, _ := ioutil.ReadFile(path)
+	if frames := data[framesOffset]; frames != 3 {
+		t.Errorf("header holds %v frames, want 3", frames)
+	}
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Frames != 3 {
+		t.Errorf("Frames = %v, want 3", r.Header.Frames)
+	}
+	r.Close()
+
+	// A truncated trajectory has fewer frames than its header
+	if err := ioutil.WriteFile(path, data[:len(data)-1], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err == nil || err == ErrNotBinary {
+		t.Errorf("Open of a truncated trajectory = %v", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(recorded), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(path); err != ErrNotBinary {
+		t.Errorf("Open of JSON = %v, want ErrNotBinary", err)
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Precision: 4, Ids: []int{3, 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr, _ := ReadJSON(strings.NewReader(`[null,null,null,{"Id":3,"Position":[[1,2]]}]`))
+	if err := w.WriteFrame(States(tr.Bodies(0, nil, 1))); err != nil {
+		t.Fatal(err)
+	}
+	tr.Ids[0] = 5
+	if err := w.WriteFrame(States(tr.Bodies(0, nil, 1))); err == nil {
+		t.Error("WriteFrame accepted a body missing from the header")
+	}
+
+	if _, err := NewWriter(&buf, Header{Precision: 2}); err == nil {
+		t.Error("NewWriter accepted a precision of 2 bytes")
+	}
+	if _, err := NewWriter(&buf, Header{Precision: 4, Ids: []int{1, 1}}); err == nil {
+		t.Error("NewWriter accepted a repeated Id")
+	}
+}
+
+func TestBinaryFields(t *testing.T) {
+
+	tr, err := ReadJSON(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	states := States(tr.Bodies(0, nil, 1))
+	for i := range states {
+		states[i].Mass, states[i].Potential, states[i].Interactions = float32(i+1), -float32(i), 3*i
+	}
+
+	fields := []Field{Position, Mass, Potential, Interactions}
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Precision: 4, Ids: tr.Ids, Fields: fields})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFrame(states); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Header.Fields) != len(fields) {
+		t.Fatalf("fields = %v, want %v", r.Header.Fields, fields)
+	}
+	values, err := r.Values(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range states {
+		want := states[i].Values(fields, nil)
+		for c := range want {
+			if got := values[i*len(want)+c]; got != want[c] {
+				t.Fatalf("body %v column %v = %v, want %v", i, c, got, want[c])
+			}
+		}
+	}
+
+	if _, err := NewWriter(&buf, Header{Precision: 4, Fields: []Field{Mass, Position}}); err == nil {
+		t.Error("NewWriter accepted fields out of order")
+	}
+}
+
+func TestBinaryPrecision(t *testing.T) {
+
+	var sizes [2]int
+	var frames [2][]byte
+	for i, precision := range []int{4, 8} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, Header{Precision: precision, Ids: []int{0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteFrame([]State{{}}); err != nil {
+			t.Fatal(err)
+		}
+		sizes[i], frames[i] = buf.Len(), buf.Bytes()
+	}
+	if sizes[1]-sizes[0] != 2*4 {
+		t.Errorf("64-bit frame is %v bytes larger than 32-bit, want 8", sizes[1]-sizes[0])
+	}
+
+	// A value no float32 holds is read back from a 64-bit frame as it was written
+	data := frames[1]
+	putFloat(data[len(data)-16:], 8, 0.1)
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := r.Values(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0] != 0.1 || values[1] != 0 {
+		t.Errorf("values = %v, want [0.1 0]", values)
+	}
+}
+
+func TestBinaryVersion1(t *testing.T) {
+
+	tr, err := ReadJSON(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tr.WriteBinary(&buf, Header{Precision: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Version 1 is version 2 with position only and no field mask
+	data := buf.Bytes()
+	old := append(append([]byte(nil), data[:headerSizeV1]...), data[headerSize:]...)
+	old[4] = 1
+
+	r, err := NewReader(bytes.NewReader(old), int64(len(old)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header.Frames != 3 || len(r.Header.Fields) != 1 {
+		t.Errorf("header = %+v", r.Header)
+	}
+	back, err := r.Trajectory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := back.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != recorded {
+		t.Errorf("version 1 trajectory = %v, want %v", out.String(), recorded)
+	}
+}
@@ -0,0 +1,93 @@
+package traj
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// CSVWriter writes a row of the step, time, id and columns of the fields for each body at every time-step.
+// Bodies no longer in the run have no rows
+type CSVWriter struct {
+	buf    *bufio.Writer
+	out    *csv.Writer
+	dt     float32
+	fields []Field
+	frames int       // Number of frames written
+	order  []int     // Indices of the bodies of a frame in order of Id, reused for every frame
+	row    []string  // One row, reused for every row
+	values []float64 // Columns of one body, reused for every body
+}
+
+/*
+ * Return a writer of a CSV trajectory, having written its column names
+ *
+ * out: where to write the trajectory
+ * header: settings of the run. Only the timestep and fields are used, and the fields default to TableFields
+ */
+func NewCSVWriter(out io.Writer, header Header) (*CSVWriter, error) {
+
+	if len(header.Fields) == 0 {
+		header.Fields = TableFields
+	}
+	if err := checkFields(header.Fields); err != nil {
+		return nil, err
+	}
+
+	buf := bufio.NewWriter(out)
+	columns := append([]string{"step", "time", "id"}, Columns(header.Fields)...)
+	w := &CSVWriter{buf: buf, out: csv.NewWriter(buf), dt: float32(header.Dt), fields: header.Fields,
+		row: make([]string, len(columns))}
+	if err := w.out.Write(columns); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+/*
+ * Write a row for each body in order of Id
+ */
+func (w *CSVWriter) WriteFrame(states []State) error {
+
+	w.order = w.order[:0]
+	for i := range states {
+		w.order = append(w.order, i)
+	}
+	sort.Slice(w.order, func(i, j int) bool { return states[w.order[i]].Id < states[w.order[j]].Id })
+
+	// Every value comes from a float32, so the shortest float32 that reads back as it is enough
+	f := func(v float64) string { return strconv.FormatFloat(v, 'g', -1, 32) }
+	step, time := strconv.Itoa(w.frames), f(float64(float32(w.frames)*w.dt))
+	for _, i := range w.order {
+		w.row[0], w.row[1], w.row[2] = step, time, strconv.Itoa(states[i].Id)
+		w.values = states[i].Values(w.fields, w.values[:0])
+		for c, v := range w.values {
+			w.row[3+c] = f(v)
+		}
+		if err := w.out.Write(w.row); err != nil {
+			return err
+		}
+	}
+	w.frames++
+
+	// Each time-step is written out as it is recorded
+	w.out.Flush()
+	if err := w.out.Error(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
+
+/*
+ * Finish writing the rows
+ */
+func (w *CSVWriter) Close() error {
+	w.out.Flush()
+	if err := w.out.Error(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
@@ -0,0 +1,37 @@
+package traj
+
+import (
+	"bytes"
+	"proj3/phys"
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+func TestCSVWriter(t *testing.T) {
+
+	var buf bytes.Buffer
+	w, err := NewCSVWriter(&buf, Header{Dt: 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := []phys.Body{{Id: 3, Position: rl.Vector2{X: 1.5, Y: 2}, Velocity: rl.Vector2{X: -3, Y: 4}, Mass: 5},
+		{Id: 1, Position: rl.Vector2{X: 0.1, Y: 7}, Mass: 10}}
+	if err := w.WriteFrame(States(bodies)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFrame(States(bodies[:1])); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "step,time,id,x,y,vx,vy,mass\n" +
+		"0,0,1,0.1,7,0,0,10\n" +
+		"0,0,3,1.5,2,-3,4,5\n" +
+		"1,0.5,3,1.5,2,-3,4,5\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%v\nwant\n%v", buf.String(), want)
+	}
+}
@@ -0,0 +1,143 @@
+package traj
+
+import (
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/phys"
+	"strings"
+)
+
+// Field is a quantity recorded for each body at every time-step of a trajectory
+type Field int
+
+// Fields in the order they are recorded in. The position is always recorded, as every reader needs it
+const (
+	Position     Field = iota // x and y
+	Velocity                  // vx and vy
+	Acceleration              // ax and ay, of the forces the body was last moved with
+	Mass                      // mass
+	Radius                    // radius
+	Potential                 // potential energy of the body with every other body
+	Interactions              // number of tree nodes the forces on the body are calculated from
+)
+
+var fieldNames = []string{"position", "velocity", "acceleration", "mass", "radius", "potential", "interactions"}
+
+// Columns of each field in a table
+var fieldColumns = [][]string{{"x", "y"}, {"vx", "vy"}, {"ax", "ay"}, {"mass"}, {"radius"}, {"potential"},
+	{"interactions"}}
+
+// Fields recorded by the CSV and NumPy writers when the header has none
+var TableFields = []Field{Position, Velocity, Mass}
+
+/*
+ * Return the name of the field
+ */
+func (f Field) String() string {
+	if f < 0 || int(f) >= len(fieldNames) {
+		return fmt.Sprintf("Field(%d)", int(f))
+	}
+	return fieldNames[f]
+}
+
+/*
+ * Return the fields of a comma separated list of names, with the position added if it is missing
+ *
+ * return: the fields in the order they are recorded in, without repeats
+ */
+func ParseFields(list string) ([]Field, error) {
+
+	chosen := make([]bool, len(fieldNames))
+	chosen[Position] = true
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for i, n := range fieldNames {
+			if n == name {
+				chosen[i], found = true, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+	}
+
+	fields := make([]Field, 0, len(chosen))
+	for i := range chosen {
+		if chosen[i] {
+			fields = append(fields, Field(i))
+		}
+	}
+	return fields, nil
+}
+
+/*
+ * Return the names of the columns of the fields in a table
+ */
+func Columns(fields []Field) []string {
+	columns := make([]string, 0, 2*len(fields))
+	for _, f := range fields {
+		columns = append(columns, fieldColumns[f]...)
+	}
+	return columns
+}
+
+/*
+ * Check fields start with the position and are in the order they are recorded in, without repeats
+ */
+func checkFields(fields []Field) error {
+	for i, f := range fields {
+		if f < 0 || int(f) >= len(fieldNames) || (i == 0 && f != Position) || (i > 0 && f <= fields[i-1]) {
+			return fmt.Errorf("fields must start with the position and be in order without repeats. Not %v", fields)
+		}
+	}
+	return nil
+}
+
+// State is a body with the quantities found from the forces on it
+type State struct {
+	phys.Body
+	Acceleration rl.Vector2 // Acceleration of the forces the body was last moved with
+	Potential    float32    // Potential energy of the body with every other body
+	Interactions int        // Number of tree nodes the forces on the body are calculated from
+}
+
+/*
+ * Return the states of bodies with none of the quantities found from the forces
+ */
+func States(bodies []phys.Body) []State {
+	states := make([]State, len(bodies))
+	for i := range bodies {
+		states[i].Body = bodies[i]
+	}
+	return states
+}
+
+/*
+ * Append the columns of the fields of the state, as float64 so 64-bit writers lose nothing
+ *
+ * values: slice to append to
+ */
+func (s *State) Values(fields []Field, values []float64) []float64 {
+
+	for _, f := range fields {
+		switch f {
+		case Position:
+			values = append(values, float64(s.Position.X), float64(s.Position.Y))
+		case Velocity:
+			values = append(values, float64(s.Velocity.X), float64(s.Velocity.Y))
+		case Acceleration:
+			values = append(values, float64(s.Acceleration.X), float64(s.Acceleration.Y))
+		case Mass:
+			values = append(values, float64(s.Mass))
+		case Radius:
+			values = append(values, float64(s.Radius))
+		case Potential:
+			values = append(values, float64(s.Potential))
+		case Interactions:
+			values = append(values, float64(s.Interactions))
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,24 @@
+package traj
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+
+	fields, err := ParseFields("potential, mass,velocity,mass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Field{Position, Velocity, Mass, Potential}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+	if columns := Columns(fields); !reflect.DeepEqual(columns, []string{"x", "y", "vx", "vy", "mass", "potential"}) {
+		t.Errorf("columns = %v", columns)
+	}
+
+	if _, err := ParseFields("position,speed"); err == nil {
+		t.Error("ParseFields accepted an unknown field")
+	}
+}
@@ -0,0 +1,261 @@
+package traj
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// NPYWriter writes a NumPy .npy array of the state of every body at each time-step, shaped
+// (frames, bodies, columns) with the bodies in the order of the Ids of the header and the columns of its fields.
+// Bodies no longer in the run are NaN
+type NPYWriter struct {
+	out     io.Writer
+	header  Header
+	columns int         // Number of columns of each body
+	index   map[int]int // Place of each Id in a frame
+	frame   []byte      // One frame, reused for every frame
+	values  []float64   // Columns of one body, reused for every body
+	frames  int         // Number of frames written
+}
+
+/*
+ * Return a writer of a .npy array, having written its header
+ * The shape is written first, so the number of frames must be known. Frames not written by Close are NaN
+ *
+ * out: where to write the array
+ * header: precision, number of frames, Ids and fields of the bodies. The fields default to TableFields
+ */
+func NewNPYWriter(out io.Writer, header Header) (*NPYWriter, error) {
+
+	if header.Precision != 4 && header.Precision != 8 {
+		return nil, fmt.Errorf("precision must be 4 or 8 bytes. Not [%v]", header.Precision)
+	}
+	if header.Frames <= 0 {
+		return nil, fmt.Errorf("frames must be greater than 0. Not [%v]", header.Frames)
+	}
+	if len(header.Fields) == 0 {
+		header.Fields = TableFields
+	}
+	if err := checkFields(header.Fields); err != nil {
+		return nil, err
+	}
+
+	columns := len(Columns(header.Fields))
+	w := &NPYWriter{out: out, header: header, columns: columns, index: make(map[int]int, len(header.Ids)),
+		frame: make([]byte, len(header.Ids)*columns*header.Precision)}
+	for i, id := range header.Ids {
+		if _, ok := w.index[id]; ok {
+			return nil, fmt.Errorf("body %v appears more than once", id)
+		}
+		w.index[id] = i
+	}
+
+	if err := writeNPYHeader(out, floatDescr(header.Precision), header.Frames, len(header.Ids), columns); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+/*
+ * Write the state of the bodies at the next time-step. Bodies of the header missing from the slice are NaN
+ */
+func (w *NPYWriter) WriteFrame(states []State) error {
+
+	if w.frames == w.header.Frames {
+		return fmt.Errorf("the array only has room for %v frames", w.header.Frames)
+	}
+
+	for i := 0; i < len(w.frame)/w.header.Precision; i++ {
+		putFloat(w.frame[i*w.header.Precision:], w.header.Precision, math.NaN())
+	}
+	for i := range states {
+		place, ok := w.index[states[i].Id]
+		if !ok {
+			return fmt.Errorf("body %v is not in the header", states[i].Id)
+		}
+		w.values = states[i].Values(w.header.Fields, w.values[:0])
+		for c, v := range w.values {
+			putFloat(w.frame[(place*w.columns+c)*w.header.Precision:], w.header.Precision, v)
+		}
+	}
+
+	if _, err := w.out.Write(w.frame); err != nil {
+		return err
+	}
+	w.frames++
+	return nil
+}
+
+/*
+ * Fill the frames not written with NaN, so the array matches its shape
+ */
+func (w *NPYWriter) Close() error {
+
+	for w.frames < w.header.Frames {
+		if err := w.WriteFrame(nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NPZWriter writes a NumPy .npz archive of a trajectory holding the arrays
+//
+//	state   (frames, bodies, columns) as written by NPYWriter
+//	ids     (bodies,) Id of each body, as int64
+//	time    (frames,) time of each frame
+//	fields  (columns,) names of the columns of state
+type NPZWriter struct {
+	archive *zip.Writer
+	state   *NPYWriter
+}
+
+/*
+ * Return a writer of a .npz archive, having written every array but the state
+ *
+ * out: where to write the archive
+ * header: precision, timestep, number of frames, Ids and fields of the bodies. The fields default to TableFields
+ */
+func NewNPZWriter(out io.Writer, header Header) (*NPZWriter, error) {
+
+	if header.Precision != 4 && header.Precision != 8 {
+		return nil, fmt.Errorf("precision must be 4 or 8 bytes. Not [%v]", header.Precision)
+	}
+	if len(header.Fields) == 0 {
+		header.Fields = TableFields
+	}
+	archive := zip.NewWriter(out)
+
+	// The arrays are compressed as numpy.savez_compressed does, with the state last as it is not known yet
+	ids, err := archive.CreateHeader(&zip.FileHeader{Name: "ids.npy", Method: zip.Deflate})
+	if err == nil {
+		err = writeNPYHeader(ids, "<i8", len(header.Ids))
+	}
+	for i := 0; err == nil && i < len(header.Ids); i++ {
+		err = binary.Write(ids, binary.LittleEndian, int64(header.Ids[i]))
+	}
+
+	var times io.Writer
+	if err == nil {
+		times, err = archive.CreateHeader(&zip.FileHeader{Name: "time.npy", Method: zip.Deflate})
+	}
+	if err == nil {
+		err = writeNPYHeader(times, floatDescr(header.Precision), header.Frames)
+	}
+	buf := make([]byte, header.Precision)
+	for k := 0; err == nil && k < header.Frames; k++ {
+		putFloat(buf, header.Precision, float64(k)*header.Dt)
+		_, err = times.Write(buf)
+	}
+
+	var fields io.Writer
+	if err == nil {
+		fields, err = archive.CreateHeader(&zip.FileHeader{Name: "fields.npy", Method: zip.Deflate})
+	}
+	// The names are fixed width UTF-32, padded with zeros to the longest
+	columns := Columns(header.Fields)
+	width := 0
+	for _, c := range columns {
+		if len(c) > width {
+			width = len(c)
+		}
+	}
+	if err == nil {
+		err = writeNPYHeader(fields, fmt.Sprintf("<U%v", width), len(columns))
+	}
+	for i := 0; err == nil && i < len(columns); i++ {
+		name := make([]rune, width)
+		copy(name, []rune(columns[i]))
+		err = binary.Write(fields, binary.LittleEndian, name)
+	}
+
+	var state io.Writer
+	if err == nil {
+		state, err = archive.CreateHeader(&zip.FileHeader{Name: "state.npy", Method: zip.Deflate})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewNPYWriter(state, header)
+	if err != nil {
+		return nil, err
+	}
+	return &NPZWriter{archive, w}, nil
+}
+
+/*
+ * Write the state of the bodies at the next time-step, as NPYWriter does
+ */
+func (w *NPZWriter) WriteFrame(states []State) error {
+	return w.state.WriteFrame(states)
+}
+
+/*
+ * Finish the state array and the archive. The output is not closed
+ */
+func (w *NPZWriter) Close() error {
+	if err := w.state.Close(); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
+
+/*
+ * Write the header of a version 1.0 .npy array in C order
+ *
+ * descr: NumPy type of the elements, such as "<f4"
+ * shape: size of each dimension
+ */
+func writeNPYHeader(out io.Writer, descr string, shape ...int) error {
+
+	dims := make([]string, len(shape))
+	for i, n := range shape {
+		dims[i] = fmt.Sprint(n)
+	}
+	tuple := strings.Join(dims, ", ")
+	if len(shape) == 1 {
+		tuple += ","
+	}
+	dict := fmt.Sprintf("{'descr': '%v', 'fortran_order': False, 'shape': (%v), }", descr, tuple)
+
+	// The magic, version, length and dictionary are padded with spaces to a multiple of 64 bytes, ending in a newline
+	const prefix = 10
+	padding := 64 - (prefix+len(dict)+1)%64
+	if padding == 64 {
+		padding = 0
+	}
+	dict += strings.Repeat(" ", padding) + "\n"
+
+	buf := make([]byte, prefix, prefix+len(dict))
+	copy(buf, "\x93NUMPY\x01\x00")
+	binary.LittleEndian.PutUint16(buf[8:], uint16(len(dict)))
+	buf = append(buf, dict...)
+
+	_, err := out.Write(buf)
+	return err
+}
+
+/*
+ * Return the NumPy type of little-endian floats of a precision in bytes
+ */
+func floatDescr(precision int) string {
+	return fmt.Sprintf("<f%v", precision)
+}
+
+/*
+ * Put a float into a buffer, little-endian
+ *
+ * precision: 4 to round it to a float32 or 8 to keep the float64
+ */
+func putFloat(buf []byte, precision int, v float64) {
+	if precision == 4 {
+		binary.LittleEndian.PutUint32(buf, math.Float32bits(float32(v)))
+	} else {
+		binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+	}
+}
@@ -0,0 +1,144 @@
+package traj
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"proj3/phys"
+	"strings"
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// readNPY returns the header dictionary and data of a .npy array
+func readNPY(t *testing.T, npy []byte) (string, []byte) {
+
+	if !bytes.HasPrefix(npy, []byte("\x93NUMPY\x01\x00")) {
+		t.Fatalf("npy starts with %q", npy[:8])
+	}
+	data := 10 + int(binary.LittleEndian.Uint16(npy[8:]))
+	if data%64 != 0 || npy[data-1] != '\n' {
+		t.Errorf("npy data starts at %v", data)
+	}
+
+	return strings.TrimSpace(string(npy[10:data])), npy[data:]
+}
+
+func TestNPYWriter(t *testing.T) {
+
+	bodies := []phys.Body{{Id: 3, Position: rl.Vector2{X: 1, Y: 2}, Velocity: rl.Vector2{X: 3, Y: 4}, Mass: 5},
+		{Id: 1, Position: rl.Vector2{X: 6, Y: 7}, Velocity: rl.Vector2{X: 8, Y: 9}, Mass: 10}}
+
+	for _, precision := range []int{4, 8} {
+		var buf bytes.Buffer
+		w, err := NewNPYWriter(&buf, Header{Precision: precision, Frames: 3, Ids: []int{1, 3}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.WriteFrame(States(bodies)); err != nil {
+			t.Fatal(err)
+		}
+		// Body 1 was absorbed after the first step, and the last frame is never written
+		if err := w.WriteFrame(States(bodies[:1])); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		dict, data := readNPY(t, buf.Bytes())
+		want := "{'descr': '<f" + string(rune('0'+precision)) + "', 'fortran_order': False, 'shape': (3, 2, 5), }"
+		if dict != want {
+			t.Errorf("header = %v, want %v", dict, want)
+		}
+		if len(data) != 3*2*5*precision {
+			t.Fatalf("%v bytes of data, want %v", len(data), 3*2*5*precision)
+		}
+
+		values := make([]float64, 3*2*5)
+		for i := range values {
+			if precision == 4 {
+				values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+			} else {
+				values[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+			}
+		}
+		for i, v := range []float64{6, 7, 8, 9, 10, 1, 2, 3, 4, 5} {
+			if values[i] != v {
+				t.Errorf("first frame = %v", values[:10])
+				break
+			}
+		}
+		if !math.IsNaN(values[10]) || values[15] != 1 || !math.IsNaN(values[20]) || !math.IsNaN(values[29]) {
+			t.Errorf("last frames = %v", values[10:])
+		}
+	}
+
+	var buf bytes.Buffer
+	w, _ := NewNPYWriter(&buf, Header{Precision: 4, Frames: 1, Ids: []int{1, 3}})
+	if err := w.WriteFrame(States(bodies)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFrame(States(bodies)); err == nil {
+		t.Error("WriteFrame wrote more frames than the shape")
+	}
+	if _, err := NewNPYWriter(&buf, Header{Precision: 4, Ids: []int{1}}); err == nil {
+		t.Error("NewNPYWriter accepted no frames")
+	}
+}
+
+func TestNPZWriter(t *testing.T) {
+
+	var buf bytes.Buffer
+	w, err := NewNPZWriter(&buf, Header{Precision: 8, Frames: 2, Dt: 0.1, Ids: []int{2, 7}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFrame(States([]phys.Body{{Id: 7, Mass: 1}, {Id: 2, Mass: 2}})); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrays := make(map[string][]byte)
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		arrays[f.Name], err = ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dict, data := readNPY(t, arrays["ids.npy"])
+	if dict != "{'descr': '<i8', 'fortran_order': False, 'shape': (2,), }" ||
+		binary.LittleEndian.Uint64(data) != 2 || binary.LittleEndian.Uint64(data[8:]) != 7 {
+		t.Errorf("ids = %v %v", dict, data)
+	}
+	dict, data = readNPY(t, arrays["time.npy"])
+	if dict != "{'descr': '<f8', 'fortran_order': False, 'shape': (2,), }" ||
+		math.Float64frombits(binary.LittleEndian.Uint64(data[8:])) != 0.1 {
+		t.Errorf("time = %v %v", dict, data)
+	}
+	dict, data = readNPY(t, arrays["fields.npy"])
+	if dict != "{'descr': '<U4', 'fortran_order': False, 'shape': (5,), }" || len(data) != 5*16 ||
+		data[16] != 'y' || data[32] != 'v' || data[36] != 'x' {
+		t.Errorf("fields = %v %v", dict, data)
+	}
+	dict, data = readNPY(t, arrays["state.npy"])
+	if dict != "{'descr': '<f8', 'fortran_order': False, 'shape': (2, 2, 5), }" || len(data) != 2*2*5*8 ||
+		math.Float64frombits(binary.LittleEndian.Uint64(data[4*8:])) != 2 {
+		t.Errorf("state = %v", dict)
+	}
+}
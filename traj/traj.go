@@ -0,0 +1,133 @@
+package traj
+
+import (
+	"encoding/json"
+	"fmt"
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"io"
+	"proj3/phys"
+)
+
+// Trajectory holds the positions of each body at every recorded time-step
+type Trajectory struct {
+	Ids       []int          // Id of each body
+	Positions [][]rl.Vector2 // Positions of each body, starting with its initial position. Ends early if it was absorbed
+}
+
+// Recorder writes the bodies of a run a time-step at a time, starting with the initial bodies
+type Recorder interface {
+	WriteFrame(states []State) error // Write the bodies still in the run at the next time-step
+	Close() error                    // Finish writing. The output is not closed
+}
+
+// Track of one body in the console output
+type track struct {
+	Id       int
+	Position [][2]float32
+}
+
+/*
+ * Read a trajectory written by a console run, [{"Id": 0, "Position": [[x, y], ...]}, ...]
+ */
+func ReadJSON(in io.Reader) (*Trajectory, error) {
+
+	var tracks []*track
+	if err := json.NewDecoder(in).Decode(&tracks); err != nil {
+		return nil, err
+	}
+
+	t := &Trajectory{}
+	for _, tr := range tracks {
+		// Ids the run did not have are left null
+		if tr == nil {
+			continue
+		}
+		if len(tr.Position) == 0 {
+			return nil, fmt.Errorf("body %v has no positions", tr.Id)
+		}
+
+		positions := make([]rl.Vector2, len(tr.Position))
+		for i, p := range tr.Position {
+			positions[i] = rl.NewVector2(p[0], p[1])
+		}
+		t.Ids = append(t.Ids, tr.Id)
+		t.Positions = append(t.Positions, positions)
+	}
+
+	return t, nil
+}
+
+/*
+ * Return the number of recorded time-steps, including the initial positions
+ */
+func (t *Trajectory) Steps() int {
+	steps := 0
+	for _, p := range t.Positions {
+		if len(p) > steps {
+			steps = len(p)
+		}
+	}
+	return steps
+}
+
+/*
+ * Return the bodies still in the run at a time-step
+ * Masses come from the initial bodies, or are 1 for bodies not among them. Velocities are the change in
+ * position over the last time-step, which is exact for the integrator, or the initial velocity at the start
+ *
+ * step: Integer - time-step to return the bodies at
+ * initial: bodies the run started with by Id. May be nil
+ * dt: timestep of the run
+ *
+ * return: slice of physics bodies
+ */
+func (t *Trajectory) Bodies(step int, initial map[int]phys.Body, dt float32) []phys.Body {
+
+	bodies := make([]phys.Body, 0, len(t.Ids))
+	for i, id := range t.Ids {
+		positions := t.Positions[i]
+		if step >= len(positions) {
+			continue
+		}
+
+		mass := float32(1)
+		vel := rl.NewVector2(0, 0)
+		if b, ok := initial[id]; ok {
+			mass, vel = b.Mass, b.Velocity
+		}
+		if step > 0 {
+			vel = rl.NewVector2((positions[step].X-positions[step-1].X)/dt, (positions[step].Y-positions[step-1].Y)/dt)
+		}
+
+		bodies = append(bodies, phys.NewBody(mass, id, positions[step], vel))
+	}
+
+	return bodies
+}
+
+/*
+ * Write the trajectory as a console run does, as an array indexed by Id with null for Ids it does not have
+ */
+func (t *Trajectory) WriteJSON(out io.Writer) error {
+
+	size := 0
+	for _, id := range t.Ids {
+		if id < 0 {
+			return fmt.Errorf("Ids must not be negative to be written as JSON. Not [%v]", id)
+		}
+		if id >= size {
+			size = id + 1
+		}
+	}
+
+	tracks := make([]*track, size)
+	for i, id := range t.Ids {
+		tr := &track{Id: id, Position: make([][2]float32, len(t.Positions[i]))}
+		for k, p := range t.Positions[i] {
+			tr.Position[k] = [2]float32{p.X, p.Y}
+		}
+		tracks[id] = tr
+	}
+
+	return json.NewEncoder(out).Encode(tracks)
+}
@@ -0,0 +1,49 @@
+package traj
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+	"proj3/phys"
+	"strings"
+	"testing"
+)
+
+const recorded = `[{"Id":0,"Position":[[0,0],[1,2],[3,4]]},{"Id":1,"Position":[[10,10],[10,11]]}]`
+
+func TestReadJSON(t *testing.T) {
+
+	tr, err := ReadJSON(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.Ids) != 2 || tr.Ids[1] != 1 || tr.Steps() != 3 {
+		t.Errorf("Ids = %v, Steps = %v, want 2 bodies over 3 steps", tr.Ids, tr.Steps())
+	}
+	if tr.Positions[0][2] != rl.NewVector2(3, 4) {
+		t.Errorf("last position of body 0 = %v, want [3, 4]", tr.Positions[0][2])
+	}
+
+	if _, err := ReadJSON(strings.NewReader(`[{"Id":0,"Position":[]}]`)); err == nil {
+		t.Error("ReadJSON accepted a body with no positions")
+	}
+}
+
+func TestBodies(t *testing.T) {
+
+	tr, err := ReadJSON(strings.NewReader(recorded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := map[int]phys.Body{0: phys.NewBody(2, 0, rl.NewVector2(0, 0), rl.NewVector2(5, 6))}
+
+	start := tr.Bodies(0, initial, 0.5)
+	if len(start) != 2 || start[0].Mass != 2 || start[0].Velocity != rl.NewVector2(5, 6) || start[1].Mass != 1 {
+		t.Errorf("bodies at the start = %+v", start)
+	}
+
+	// Body 1 was absorbed after the first step
+	end := tr.Bodies(2, initial, 0.5)
+	if len(end) != 1 || end[0].Position != rl.NewVector2(3, 4) || end[0].Velocity != rl.NewVector2(4, 4) {
+		t.Errorf("bodies at the end = %+v", end)
+	}
+}